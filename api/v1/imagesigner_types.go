@@ -33,6 +33,136 @@ type ImageSignerSpec struct {
 	Phone       string `json:"phone,omitempty"`
 	Team        string `json:"team,omitempty"`
 	Description string `json:"description,omitempty"`
+
+	// AccessControl restricts which namespaces and users can sign images with this signer.
+	// If it is not set, every ImageSignRequest can use this signer.
+	AccessControl *SignerAccessControl `json:"accessControl,omitempty"`
+
+	// Approval requires ImageSignRequests to be approved before signing images with this signer
+	Approval *ApprovalPolicy `json:"approval,omitempty"`
+
+	// KeySecret imports existing Docker Content Trust keys instead of generating a new root key.
+	// If HardwareKey is set, only the target keys are imported
+	KeySecret *KeySecretSource `json:"keySecret,omitempty"`
+
+	// HardwareKey uses a root key in a PKCS#11 token instead of generating a new root key.
+	// SignerKey only has the public key of the root key, so target keys of new repositories cannot be created.
+	// Target keys of repositories initialized with the token can be imported by KeySecret.Targets
+	HardwareKey *HardwareKeySource `json:"hardwareKey,omitempty"`
+
+	// SnapshotKeyManagement is how snapshot keys of repositories are managed (default: Server).
+	// Server: notary server manages snapshot keys, as docker initializes repositories.
+	// Signer: snapshot keys are stored in SignerKey per target, and loaded for signing.
+	// Docker cannot initialize a repository with a local snapshot key, so the keys should be imported by KeySecret.Snapshots.
+	// Timestamp keys are always managed by notary server
+	// +kubebuilder:validation:Enum=Server;Signer
+	SnapshotKeyManagement string `json:"snapshotKeyManagement,omitempty"`
+
+	// Notary is a notary server to push trust data to.
+	// If it is not set, docker derives it from the registry. It can be overridden by ImageSignRequest
+	Notary *NotaryServer `json:"notary,omitempty"`
+
+	// WorkerPool keeps pre-started signing pods, so that requests do not wait for a new pod to start
+	WorkerPool *WorkerPoolSpec `json:"workerPool,omitempty"`
+}
+
+// WorkerPoolSpec configures pre-started signing pods of a signer.
+// Pods are pooled per request namespace and pod template (image PVC, registry certs), because they are mounted when the pod starts.
+// Keys are loaded into a pod for each session, and wiped with loaded images after the session
+type WorkerPoolSpec struct {
+	// Size is the number of idle pods kept per request namespace and pod template
+	// +kubebuilder:validation:Minimum=1
+	Size int `json:"size"`
+	// MaxUses is the number of sessions after which a pod is replaced with a new one (default: 10).
+	// Pods are also replaced when a session fails
+	// +kubebuilder:validation:Minimum=1
+	MaxUses int `json:"maxUses,omitempty"`
+	// IdleTTLSeconds is a duration after which pods of a request namespace and pod template are removed,
+	// if no request leases them (default: 600)
+	// +kubebuilder:validation:Minimum=1
+	IdleTTLSeconds int `json:"idleTTLSeconds,omitempty"`
+}
+
+// NotaryServer is a notary server and secrets to access it. Secrets of an ImageSigner are in the operator's namespace,
+// and secrets of a NamespaceImageSigner or an ImageSignRequest are in its own namespace
+type NotaryServer struct {
+	// URL of the notary server (e.g., https://notary.example.com:4443)
+	// +kubebuilder:validation:Pattern=`^https?://`
+	URL string `json:"url"`
+	// CASecret is a secret which has CA bundle of the notary server in ca.crt
+	CASecret string `json:"caSecret,omitempty"`
+	// AuthSecret is a kubernetes.io/basic-auth secret of the notary server, which is used to check the server before signing.
+	// Note that docker authenticates to the notary server with the registry's credentials
+	AuthSecret string `json:"authSecret,omitempty"`
+}
+
+const (
+	SnapshotKeyManagementServer = "Server"
+	SnapshotKeyManagementSigner = "Signer"
+)
+
+// HardwareKeySource refers to a root key in a PKCS#11 token (e.g., HSM, YubiKey, SoftHSM).
+// The operator should be built with 'pkcs11' tag and the module should be present in the operator's image
+type HardwareKeySource struct {
+	// ModulePath is a path of the PKCS#11 module (e.g., /usr/lib/softhsm/libsofthsm2.so)
+	ModulePath string `json:"modulePath"`
+	// Slot is an ID of the slot which has the token. If it is not set, the token is found by TokenLabel
+	Slot *uint `json:"slot,omitempty"`
+	// TokenLabel is a label of the token
+	TokenLabel string `json:"tokenLabel,omitempty"`
+	// Label is a label (CKA_LABEL) of the root key pair in the token
+	Label string `json:"label"`
+	// PinSecret is a secret which has the user PIN of the token
+	PinSecret PinSecretSource `json:"pinSecret"`
+}
+
+// PinSecretSource refers to a PIN in a secret, in the operator's namespace for ImageSigner,
+// or in the namespace of NamespaceImageSigner
+type PinSecretSource struct {
+	// Name of the secret
+	Name string `json:"name"`
+	// Key of the PIN in the secret (default: pin)
+	Key string `json:"key,omitempty"`
+}
+
+// KeySecretSource refers to a secret which has private key files of ~/.docker/trust/private.
+// Each key file is stored as <key ID>.key, as created by
+// 'kubectl create secret generic <name> --from-file=<key ID>.key --from-literal=root_passphrase=<passphrase>'
+type KeySecretSource struct {
+	// Name of the secret. ImageSigner uses the secret in the operator's namespace,
+	// and NamespaceImageSigner uses the secret in its own namespace
+	Name string `json:"name"`
+	// RootPassphraseKey is a key of the root key passphrase in the secret (default: root_passphrase)
+	RootPassphraseKey string `json:"rootPassphraseKey,omitempty"`
+	// TargetPassphraseKey is a key of the repository key passphrase in the secret (default: repository_passphrase)
+	TargetPassphraseKey string `json:"targetPassphraseKey,omitempty"`
+	// Targets maps target names (namespace/registryName/imageName) to key files (<key ID>.key) in the secret
+	Targets map[string]string `json:"targets,omitempty"`
+	// Snapshots maps target names to snapshot key files (<key ID>.key) in the secret.
+	// Snapshot keys are unlocked by the repository passphrase, as docker does
+	Snapshots map[string]string `json:"snapshots,omitempty"`
+}
+
+// SignerAccessControl is an access list for an ImageSigner
+type SignerAccessControl struct {
+	// Namespaces in which ImageSignRequests can use this signer
+	Namespaces []string `json:"namespaces,omitempty"`
+	// NamespaceSelector selects namespaces in which ImageSignRequests can use this signer
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	// ServiceAccounts which can create ImageSignRequests for this signer (format: namespace:name)
+	ServiceAccounts []string `json:"serviceAccounts,omitempty"`
+	// Groups whose members can create ImageSignRequests for this signer
+	Groups []string `json:"groups,omitempty"`
+}
+
+// ApprovalPolicy defines how many approvals are required to sign an image
+type ApprovalPolicy struct {
+	// Approvers is the number of approvals required to sign an image
+	// +kubebuilder:validation:Minimum=1
+	Approvers int `json:"approvers"`
+	// ApproverGroups are groups whose members can approve or reject requests.
+	// If it is empty, every user who has permission to the approve/reject subresource can approve.
+	ApproverGroups []string `json:"approverGroups,omitempty"`
 }
 
 // ImageSignerStatus defines the observed state of ImageSigner
@@ -31,9 +31,33 @@ type ImageSignRequestSpec struct {
 	// Foo is an example field of ImageSignRequest. Edit ImageSignRequest_types.go to remove/update
 	RegistryLogin `json:"registryLogin,omitempty"`
 	// Image example: alpine:3
-	Image   string `json:"image"`
+	Image string `json:"image"`
+	// Repository is a fully-qualified repository to push the signed image to (e.g., docker.io/myteam/app, myreg:5000/app),
+	// for registries without Registry object. The tag of Image is used.
+	// It is normalized as docker does (e.g., myteam/app -> docker.io/myteam/app), and its domain should have a dot or a port
+	Repository string `json:"repository,omitempty"`
+	// CredentialsSecret is a kubernetes.io/dockerconfigjson, kubernetes.io/dockercfg or kubernetes.io/basic-auth secret
+	// to log in to the repository. It is used instead of registryLogin.dcjSecretName.
+	// If it has no credential of the registry, login id of the Registry (only in the request's namespace) and
+	// imagePullSecrets of the default service account are used. The image is pushed anonymously if none of them has the credential
+	CredentialsSecret string `json:"credentialsSecret,omitempty"`
 	PvcName string `json:"pvcName"`
 	Signer  string `json:"signer"`
+	// SignerKind is a kind of the signer. NamespaceImageSigner is looked up in the request's namespace
+	// +kubebuilder:validation:Enum=ImageSigner;NamespaceImageSigner
+	SignerKind string `json:"signerKind,omitempty"`
+	// Delegation is a delegation role (targets/<delegation>) to sign the image with, instead of the targets role.
+	// Its key is created in the signer key if it does not exist, and added to the repository as a signer
+	// (also to targets/releases, as 'docker trust signer add' does).
+	// Once a repository has delegations, images can only be signed with one of the delegations
+	// +kubebuilder:validation:Pattern=`^(targets/)?[a-zA-Z0-9][a-zA-Z0-9_.-]*$`
+	Delegation string `json:"delegation,omitempty"`
+	// Requester is the user who created the request. It is set by the admission webhook and cannot be changed.
+	// The requester cannot approve their own request
+	Requester string `json:"requester,omitempty"`
+	// RequesterGroups are the groups of the requester when the request is created. They are set by the admission webhook,
+	// and used to check the access control of the signer while reconciling
+	RequesterGroups []string `json:"requesterGroups,omitempty"`
 }
 
 type RegistryLogin struct {
@@ -41,6 +65,28 @@ type RegistryLogin struct {
 	Name           string `json:"name"`
 	DcjSecretName  string `json:"dcjSecretName"`
 	CertSecretName string `json:"certSecretName"`
+	// Notary overrides the notary server of the signer
+	Notary *NotaryServer `json:"notary,omitempty"`
+	// TLS is TLS configuration of the registry. CertSecretName is still supported for CA certificates
+	TLS *RegistryTLS `json:"tls,omitempty"`
+}
+
+// RegistryTLS is TLS configuration to access a registry
+type RegistryTLS struct {
+	// SecretName is a secret which has CA bundle and client certificate in the request's namespace
+	SecretName string `json:"secretName,omitempty"`
+	// CAKey is a key of the PEM encoded CA bundle in the secret (default: ca.crt)
+	CAKey string `json:"caKey,omitempty"`
+	// CertKey is a key of the PEM encoded client certificate in the secret (default: tls.crt)
+	CertKey string `json:"certKey,omitempty"`
+	// KeyKey is a key of the PEM encoded client private key in the secret (default: tls.key)
+	KeyKey string `json:"keyKey,omitempty"`
+	// InsecureSkipVerify skips verifying the registry's certificate, for lab registries.
+	// docker treats the registry as an insecure registry, which may also be accessed by plain http
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+	// ServerName is used to verify the registry's certificate, if it differs from the registry host.
+	// docker does not support it, so it is only used by the operator's own client
+	ServerName string `json:"serverName,omitempty"`
 }
 
 // ImageSignRequestStatus defines the observed state of ImageSignRequest
@@ -48,6 +94,19 @@ type ImageSignRequestStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 	*ImageSignResponse `json:"imageSignResponse,omitempty"`
+
+	// Approvals are approvals and rejections added through the approve/reject subresource.
+	// The admission webhook denies changes of the status by users other than the operator,
+	// so update of imagesignrequests/status should not be granted to other users if the webhook is not installed
+	Approvals []Approval `json:"approvals,omitempty"`
+}
+
+// Approval is an approval or a rejection of a request by a user
+type Approval struct {
+	User     string      `json:"user"`
+	Approved bool        `json:"approved"`
+	Reason   string      `json:"reason,omitempty"`
+	Time     metav1.Time `json:"time"`
 }
 
 type ResponseResult string
@@ -55,13 +114,36 @@ type ResponseResult string
 const (
 	ResponseResultSuccess = ResponseResult("Success")
 	ResponseResultFail    = ResponseResult("Fail")
+	// ResponseResultPendingApproval means the request is waiting for approvals
+	ResponseResultPendingApproval = ResponseResult("PendingApproval")
+	// ResponseResultQueued means the request is waiting for a signing session
+	ResponseResultQueued = ResponseResult("Queued")
+)
+
+const (
+	// ResponseReasonAccessDenied is a reason for requests which are not allowed to use the signer
+	ResponseReasonAccessDenied = "SignerAccessDenied"
+	// ResponseReasonRejected is a reason for requests which are rejected by an approver
+	ResponseReasonRejected = "Rejected"
+	// ResponseReasonNoTargetKey is a reason for requests which need a new target key, which cannot be created
+	ResponseReasonNoTargetKey = "NoTargetKey"
+	// ResponseReasonNoSnapshotKey is a reason for requests whose target has no snapshot key, while the signer manages snapshot keys
+	ResponseReasonNoSnapshotKey = "NoSnapshotKey"
+	// ResponseReasonNotaryUnavailable is a reason for requests whose notary server is not reachable
+	ResponseReasonNotaryUnavailable = "NotaryUnavailable"
+	// ResponseReasonNoCredential is a reason for requests whose credential of the registry cannot be read
+	ResponseReasonNoCredential = "NoRegistryCredential"
+	// ResponseReasonRegistryUnavailable is a reason for requests whose registry is not reachable or has invalid TLS configuration
+	ResponseReasonRegistryUnavailable = "RegistryUnavailable"
 )
 
 type ImageSignResponse struct {
-	// Result: Success / Fail
+	// Result: Success / Fail / PendingApproval / Queued
 	Result  ResponseResult `json:"result,omitempty"`
 	Reason  string         `json:"reason,omitempty"`
 	Message string         `json:"message,omitempty"`
+	// QueuePosition is a 1-based position in the signing queue, while the result is Queued
+	QueuePosition int `json:"queuePosition,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -0,0 +1,69 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	SignerKindImageSigner          = "ImageSigner"
+	SignerKindNamespaceImageSigner = "NamespaceImageSigner"
+)
+
+// Signer is implemented by ImageSigner and NamespaceImageSigner
+// +kubebuilder:object:generate=false
+type Signer interface {
+	metav1.Object
+	runtime.Object
+
+	SignerKind() string
+	SignerSpec() *ImageSignerSpec
+	SignerStatus() *ImageSignerStatus
+	// NewKey returns an empty key object of the signer's kind
+	NewKey() Key
+}
+
+// Key is implemented by SignerKey and NamespaceSignerKey
+// +kubebuilder:object:generate=false
+type Key interface {
+	metav1.Object
+	runtime.Object
+
+	KeySpec() *SignerKeySpec
+}
+
+// NewSigner returns an empty signer object of the kind.
+// If kind is empty string, ImageSigner is returned
+func NewSigner(kind string) Signer {
+	if kind == SignerKindNamespaceImageSigner {
+		return &NamespaceImageSigner{}
+	}
+	return &ImageSigner{}
+}
+
+// SignerObjectKey returns the object key of the signer referenced by the request
+func (r *ImageSignRequest) SignerObjectKey() types.NamespacedName {
+	if r.Spec.SignerKind == SignerKindNamespaceImageSigner {
+		return types.NamespacedName{Name: r.Spec.Signer, Namespace: r.Namespace}
+	}
+	return types.NamespacedName{Name: r.Spec.Signer}
+}
+
+// KeyObjectKey returns the object key of the signer's key
+func KeyObjectKey(signer Signer) types.NamespacedName {
+	return types.NamespacedName{Name: signer.GetName(), Namespace: signer.GetNamespace()}
+}
+
+func (s *ImageSigner) SignerKind() string               { return SignerKindImageSigner }
+func (s *ImageSigner) SignerSpec() *ImageSignerSpec     { return &s.Spec }
+func (s *ImageSigner) SignerStatus() *ImageSignerStatus { return &s.Status }
+func (s *ImageSigner) NewKey() Key                      { return &SignerKey{} }
+
+func (s *NamespaceImageSigner) SignerKind() string               { return SignerKindNamespaceImageSigner }
+func (s *NamespaceImageSigner) SignerSpec() *ImageSignerSpec     { return &s.Spec }
+func (s *NamespaceImageSigner) SignerStatus() *ImageSignerStatus { return &s.Status }
+func (s *NamespaceImageSigner) NewKey() Key                      { return &NamespaceSignerKey{} }
+
+func (k *SignerKey) KeySpec() *SignerKeySpec          { return &k.Spec }
+func (k *NamespaceSignerKey) KeySpec() *SignerKeySpec { return &k.Spec }
@@ -32,13 +32,28 @@ type SignerKeySpec struct {
 	Root TrustKey `json:"root,omitempty"`
 	// Targets is {namespace/registryName/imageName: TrustKey{}, ...}
 	Targets map[string]TrustKey `json:"targets,omitempty"`
+	// Delegations is {delegationName: TrustKey{}, ...}, keys of delegation roles (targets/<delegationName>).
+	// A delegation key is shared by all repositories the delegation is added to
+	Delegations map[string]TrustKey `json:"delegations,omitempty"`
+	// Snapshots is {namespace/registryName/imageName: TrustKey{}, ...}, snapshot keys of the targets.
+	// They are used if the signer's snapshot keys are not managed by notary server
+	Snapshots map[string]TrustKey `json:"snapshots,omitempty"`
 }
 
 // TrustKey defines key and value set
 type TrustKey struct {
 	ID         string `json:"id"`
-	Key        string `json:"key"`
-	PassPhrase string `json:"passPhrase"`
+	Key        string `json:"key,omitempty"`
+	PassPhrase string `json:"passPhrase,omitempty"`
+	// PublicKey is a PEM encoded public key, which is set instead of Key if the private key is in a hardware token
+	PublicKey string `json:"publicKey,omitempty"`
+	// CreatedAt is when the key is generated by the operator. It is not set for imported keys
+	CreatedAt *metav1.Time `json:"createdAt,omitempty"`
+}
+
+// IsHardwareKey returns true if the private key is not stored in the TrustKey
+func (k *TrustKey) IsHardwareKey() bool {
+	return len(k.Key) == 0 && len(k.PublicKey) > 0
 }
 
 // SignerKeyStatus defines the observed state of SignerKey
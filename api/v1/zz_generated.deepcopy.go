@@ -23,9 +23,46 @@ package v1
 import (
 	"github.com/operator-framework/operator-lib/status"
 	corev1 "k8s.io/api/core/v1"
-	runtime "k8s.io/apimachinery/pkg/runtime"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Approval) DeepCopyInto(out *Approval) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Approval.
+func (in *Approval) DeepCopy() *Approval {
+	if in == nil {
+		return nil
+	}
+	out := new(Approval)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ApprovalPolicy) DeepCopyInto(out *ApprovalPolicy) {
+	*out = *in
+	if in.ApproverGroups != nil {
+		in, out := &in.ApproverGroups, &out.ApproverGroups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApprovalPolicy.
+func (in *ApprovalPolicy) DeepCopy() *ApprovalPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ApprovalPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CreatePvc) DeepCopyInto(out *CreatePvc) {
 	*out = *in
@@ -61,13 +98,34 @@ func (in *ExistPvc) DeepCopy() *ExistPvc {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HardwareKeySource) DeepCopyInto(out *HardwareKeySource) {
+	*out = *in
+	if in.Slot != nil {
+		in, out := &in.Slot, &out.Slot
+		*out = new(uint)
+		**out = **in
+	}
+	out.PinSecret = in.PinSecret
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HardwareKeySource.
+func (in *HardwareKeySource) DeepCopy() *HardwareKeySource {
+	if in == nil {
+		return nil
+	}
+	out := new(HardwareKeySource)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ImageSignRequest) DeepCopyInto(out *ImageSignRequest) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageSignRequest.
@@ -123,7 +181,12 @@ func (in *ImageSignRequestList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ImageSignRequestSpec) DeepCopyInto(out *ImageSignRequestSpec) {
 	*out = *in
-	out.RegistryLogin = in.RegistryLogin
+	in.RegistryLogin.DeepCopyInto(&out.RegistryLogin)
+	if in.RequesterGroups != nil {
+		in, out := &in.RequesterGroups, &out.RequesterGroups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageSignRequestSpec.
@@ -139,6 +202,18 @@ func (in *ImageSignRequestSpec) DeepCopy() *ImageSignRequestSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ImageSignRequestStatus) DeepCopyInto(out *ImageSignRequestStatus) {
 	*out = *in
+	if in.ImageSignResponse != nil {
+		in, out := &in.ImageSignResponse, &out.ImageSignResponse
+		*out = new(ImageSignResponse)
+		**out = **in
+	}
+	if in.Approvals != nil {
+		in, out := &in.Approvals, &out.Approvals
+		*out = make([]Approval, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageSignRequestStatus.
@@ -151,13 +226,28 @@ func (in *ImageSignRequestStatus) DeepCopy() *ImageSignRequestStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageSignResponse) DeepCopyInto(out *ImageSignResponse) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageSignResponse.
+func (in *ImageSignResponse) DeepCopy() *ImageSignResponse {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageSignResponse)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ImageSigner) DeepCopyInto(out *ImageSigner) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageSigner.
@@ -213,6 +303,36 @@ func (in *ImageSignerList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ImageSignerSpec) DeepCopyInto(out *ImageSignerSpec) {
 	*out = *in
+	if in.AccessControl != nil {
+		in, out := &in.AccessControl, &out.AccessControl
+		*out = new(SignerAccessControl)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Approval != nil {
+		in, out := &in.Approval, &out.Approval
+		*out = new(ApprovalPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.KeySecret != nil {
+		in, out := &in.KeySecret, &out.KeySecret
+		*out = new(KeySecretSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HardwareKey != nil {
+		in, out := &in.HardwareKey, &out.HardwareKey
+		*out = new(HardwareKeySource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Notary != nil {
+		in, out := &in.Notary, &out.Notary
+		*out = new(NotaryServer)
+		**out = **in
+	}
+	if in.WorkerPool != nil {
+		in, out := &in.WorkerPool, &out.WorkerPool
+		*out = new(WorkerPoolSpec)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageSignerSpec.
@@ -228,6 +348,11 @@ func (in *ImageSignerSpec) DeepCopy() *ImageSignerSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ImageSignerStatus) DeepCopyInto(out *ImageSignerStatus) {
 	*out = *in
+	if in.SignerKeyState != nil {
+		in, out := &in.SignerKeyState, &out.SignerKeyState
+		*out = new(SignerKeyState)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageSignerStatus.
@@ -255,6 +380,35 @@ func (in *Ingress) DeepCopy() *Ingress {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeySecretSource) DeepCopyInto(out *KeySecretSource) {
+	*out = *in
+	if in.Targets != nil {
+		in, out := &in.Targets, &out.Targets
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Snapshots != nil {
+		in, out := &in.Snapshots, &out.Snapshots
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeySecretSource.
+func (in *KeySecretSource) DeepCopy() *KeySecretSource {
+	if in == nil {
+		return nil
+	}
+	out := new(KeySecretSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LoadBalancer) DeepCopyInto(out *LoadBalancer) {
 	*out = *in
@@ -270,6 +424,154 @@ func (in *LoadBalancer) DeepCopy() *LoadBalancer {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespaceImageSigner) DeepCopyInto(out *NamespaceImageSigner) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceImageSigner.
+func (in *NamespaceImageSigner) DeepCopy() *NamespaceImageSigner {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceImageSigner)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NamespaceImageSigner) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespaceImageSignerList) DeepCopyInto(out *NamespaceImageSignerList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]NamespaceImageSigner, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceImageSignerList.
+func (in *NamespaceImageSignerList) DeepCopy() *NamespaceImageSignerList {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceImageSignerList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NamespaceImageSignerList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespaceSignerKey) DeepCopyInto(out *NamespaceSignerKey) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceSignerKey.
+func (in *NamespaceSignerKey) DeepCopy() *NamespaceSignerKey {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceSignerKey)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NamespaceSignerKey) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespaceSignerKeyList) DeepCopyInto(out *NamespaceSignerKeyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]NamespaceSignerKey, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceSignerKeyList.
+func (in *NamespaceSignerKeyList) DeepCopy() *NamespaceSignerKeyList {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceSignerKeyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NamespaceSignerKeyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NotaryServer) DeepCopyInto(out *NotaryServer) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotaryServer.
+func (in *NotaryServer) DeepCopy() *NotaryServer {
+	if in == nil {
+		return nil
+	}
+	out := new(NotaryServer)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PinSecretSource) DeepCopyInto(out *PinSecretSource) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PinSecretSource.
+func (in *PinSecretSource) DeepCopy() *PinSecretSource {
+	if in == nil {
+		return nil
+	}
+	out := new(PinSecretSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Registry) DeepCopyInto(out *Registry) {
 	*out = *in
@@ -369,6 +671,16 @@ func (in *RegistryList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RegistryLogin) DeepCopyInto(out *RegistryLogin) {
 	*out = *in
+	if in.Notary != nil {
+		in, out := &in.Notary, &out.Notary
+		*out = new(NotaryServer)
+		**out = **in
+	}
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(RegistryTLS)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegistryLogin.
@@ -465,6 +777,56 @@ func (in *RegistryStatus) DeepCopy() *RegistryStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RegistryTLS) DeepCopyInto(out *RegistryTLS) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegistryTLS.
+func (in *RegistryTLS) DeepCopy() *RegistryTLS {
+	if in == nil {
+		return nil
+	}
+	out := new(RegistryTLS)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SignerAccessControl) DeepCopyInto(out *SignerAccessControl) {
+	*out = *in
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ServiceAccounts != nil {
+		in, out := &in.ServiceAccounts, &out.ServiceAccounts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SignerAccessControl.
+func (in *SignerAccessControl) DeepCopy() *SignerAccessControl {
+	if in == nil {
+		return nil
+	}
+	out := new(SignerAccessControl)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SignerKey) DeepCopyInto(out *SignerKey) {
 	*out = *in
@@ -527,12 +889,26 @@ func (in *SignerKeyList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SignerKeySpec) DeepCopyInto(out *SignerKeySpec) {
 	*out = *in
-	out.Root = in.Root
+	in.Root.DeepCopyInto(&out.Root)
 	if in.Targets != nil {
 		in, out := &in.Targets, &out.Targets
 		*out = make(map[string]TrustKey, len(*in))
 		for key, val := range *in {
-			(*out)[key] = val
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.Delegations != nil {
+		in, out := &in.Delegations, &out.Delegations
+		*out = make(map[string]TrustKey, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.Snapshots != nil {
+		in, out := &in.Snapshots, &out.Snapshots
+		*out = make(map[string]TrustKey, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
 		}
 	}
 }
@@ -547,6 +923,22 @@ func (in *SignerKeySpec) DeepCopy() *SignerKeySpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SignerKeyState) DeepCopyInto(out *SignerKeyState) {
+	*out = *in
+	in.CreatedAt.DeepCopyInto(&out.CreatedAt)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SignerKeyState.
+func (in *SignerKeyState) DeepCopy() *SignerKeyState {
+	if in == nil {
+		return nil
+	}
+	out := new(SignerKeyState)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SignerKeyStatus) DeepCopyInto(out *SignerKeyStatus) {
 	*out = *in
@@ -565,6 +957,10 @@ func (in *SignerKeyStatus) DeepCopy() *SignerKeyStatus {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TrustKey) DeepCopyInto(out *TrustKey) {
 	*out = *in
+	if in.CreatedAt != nil {
+		in, out := &in.CreatedAt, &out.CreatedAt
+		*out = (*in).DeepCopy()
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TrustKey.
@@ -576,3 +972,18 @@ func (in *TrustKey) DeepCopy() *TrustKey {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkerPoolSpec) DeepCopyInto(out *WorkerPoolSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkerPoolSpec.
+func (in *WorkerPoolSpec) DeepCopy() *WorkerPoolSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkerPoolSpec)
+	in.DeepCopyInto(out)
+	return out
+}
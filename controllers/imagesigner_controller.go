@@ -20,53 +20,115 @@ import (
 	"context"
 
 	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	tmaxiov1 "github.com/tmax-cloud/image-signing-operator/api/v1"
+	"github.com/tmax-cloud/image-signing-operator/pkg/audit"
 	"github.com/tmax-cloud/image-signing-operator/pkg/controller"
+	"github.com/tmax-cloud/image-signing-operator/pkg/tracing"
 	"github.com/tmax-cloud/image-signing-operator/pkg/trust"
 )
 
 // ImageSignerReconciler reconciles a ImageSigner object
 type ImageSignerReconciler struct {
 	client.Client
-	Log    logr.Logger
-	Scheme *runtime.Scheme
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// Pool is drained when the worker pool of a signer is changed, or the signer is deleted
+	Pool *controller.WorkerPool
 }
 
 // +kubebuilder:rbac:groups=tmax.io,resources=imagesigners,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=tmax.io,resources=imagesigners/status,verbs=get;update;patch
 
 func (r *ImageSignerReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
-	_ = context.Background()
+	ctx, span := tracing.Start(context.Background(), "ImageSignerReconciler.Reconcile", tracing.AttributeSigner.String(req.NamespacedName.String()))
+	defer span.End()
 	log := r.Log.WithValues("imagesigner", req.NamespacedName)
 
 	// get image signer
 	signer := &tmaxiov1.ImageSigner{}
 	if err := r.Get(context.TODO(), req.NamespacedName, signer); err != nil {
+		if errors.IsNotFound(err) && r.Pool != nil {
+			signer.Name, signer.Namespace = req.Name, req.Namespace
+			r.Pool.Drain(signer)
+		}
 		log.Error(err, "")
 		return ctrl.Result{}, nil
 	}
+	if r.Pool != nil {
+		r.Pool.Sync(signer)
+	}
+
+	return reconcileSigner(ctx, r.Client, r.Scheme, r.Recorder, log, signer)
+}
 
-	if signer.Status.SignerKeyState != nil && signer.Status.Created {
+// reconcileSigner creates root key of ImageSigner or NamespaceImageSigner
+func reconcileSigner(ctx context.Context, c client.Client, scheme *runtime.Scheme, recorder record.EventRecorder, log logr.Logger, signer tmaxiov1.Signer) (ctrl.Result, error) {
+	status := signer.SignerStatus()
+	if status.SignerKeyState != nil && status.Created {
 		return ctrl.Result{}, nil
 	}
 
-	defer updateSignerStatus(r.Client, signer)
+	defer updateSignerStatus(c, signer)
 
 	// check if signer key is exist
-	signerKey := &tmaxiov1.SignerKey{}
-	r.Get(context.TODO(), types.NamespacedName{Name: signer.Name}, signerKey)
-	if len(signerKey.Name) > 0 {
+	signerKey := signer.NewKey()
+	c.Get(context.TODO(), tmaxiov1.KeyObjectKey(signer), signerKey)
+	if len(signerKey.GetName()) > 0 {
 		log.Info("signer key is already exist")
+		// e.g., the key is restored from a backup archive
+		makeSignerStatus(signer, true, "", "", &signerKey.KeySpec().Root)
+		return ctrl.Result{}, nil
+	}
+
+	// if hardware key is given, the root key is in the PKCS#11 token
+	if signer.SignerSpec().HardwareKey != nil {
+		log.Info("import hardware key")
+		rootKey, err := controller.ImportHardwareKey(c, signer, scheme)
+		if err != nil {
+			log.Error(err, "import hardware key failed")
+			makeSignerStatus(signer, false, err.Error(), "", nil)
+			recorder.Event(signer, corev1.EventTypeWarning, controller.EventReasonKeyCreationFailed, "cannot import hardware key: "+err.Error())
+			return ctrl.Result{}, nil
+		}
+
+		makeSignerStatus(signer, true, "", "", rootKey)
+		recorder.Eventf(signer, corev1.EventTypeNormal, controller.EventReasonKeysImported, "root key %s is imported from hardware token", rootKey.ID)
+		recordSignerKey(signer, audit.EventKeyImported, rootKey, "hardware token")
+		return ctrl.Result{}, nil
+	}
+
+	// if key secret is given, import existing keys instead of generating new one
+	if signer.SignerSpec().KeySecret != nil {
+		log.Info("import keys")
+		rootKey, err := controller.ImportKeys(c, signer, scheme)
+		if err != nil {
+			log.Error(err, "import keys failed")
+			makeSignerStatus(signer, false, err.Error(), "", nil)
+			recorder.Event(signer, corev1.EventTypeWarning, controller.EventReasonKeyCreationFailed, "cannot import keys: "+err.Error())
+			return ctrl.Result{}, nil
+		}
+
+		makeSignerStatus(signer, true, "", "", rootKey)
+		recorder.Eventf(signer, corev1.EventTypeNormal, controller.EventReasonKeysImported, "root key %s is imported from key secret", rootKey.ID)
+		recordSignerKey(signer, audit.EventKeyImported, rootKey, "key secret")
 		return ctrl.Result{}, nil
 	}
 
 	// if signer key is not exist, create root key
-	signCtl := controller.NewSigningController(r.Client, signer, "", "", "")
+	signCtl := controller.NewSigningController(c, signer, nil, signer.GetNamespace())
+	signCtl.Context = ctx
 	phrase := trust.NewTrustPass()
 	phrase.AssignNewRootPass()
 	cmdOpt := &controller.CommandOpt{
@@ -76,6 +138,7 @@ func (r *ImageSignerReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error)
 	if err := signCtl.Start(cmdOpt); err != nil {
 		log.Error(err, "dind container start failed")
 		makeSignerStatus(signer, false, err.Error(), "", nil)
+		recorder.Event(signer, corev1.EventTypeWarning, controller.EventReasonKeyCreationFailed, "cannot start signing pod: "+err.Error())
 		signCtl.Close()
 		return ctrl.Result{}, nil
 	}
@@ -87,23 +150,58 @@ func (r *ImageSignerReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error)
 	}
 	log.Info("dind is running")
 
-	rootKey, err := signCtl.CreateRootKey(phrase, signer, r.Scheme)
+	rootKey, err := signCtl.CreateRootKey(phrase, signer, scheme)
 	if err != nil {
 		makeSignerStatus(signer, false, err.Error(), "", nil)
+		recorder.Event(signer, corev1.EventTypeWarning, controller.EventReasonKeyCreationFailed, "cannot generate root key: "+err.Error())
 		return ctrl.Result{}, nil
 	}
 
 	makeSignerStatus(signer, true, "", "", rootKey)
-	if signer.Status.SignerKeyState == nil {
+	recorder.Eventf(signer, corev1.EventTypeNormal, controller.EventReasonRootKeyGenerated, "root key %s is generated", rootKey.ID)
+	recordSignerKey(signer, audit.EventKeyGenerated, rootKey, "")
+	if status.SignerKeyState == nil {
 		log.Info("SignerKeyState is nil!!!!")
 	}
 
 	return ctrl.Result{}, nil
 }
 
+// recordSignerKey records an audit entry of the root key of the signer
+func recordSignerKey(signer tmaxiov1.Signer, eventType audit.EventType, rootKey *tmaxiov1.TrustKey, message string) {
+	audit.Record(audit.Entry{
+		Type:    eventType,
+		User:    audit.UserOperator,
+		Signer:  audit.SignerName(signer.SignerKind(), signer.GetNamespace(), signer.GetName()),
+		Role:    string(trust.TrustRoleRoot),
+		KeyID:   rootKey.ID,
+		Message: message,
+	})
+}
+
 func (r *ImageSignerReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&tmaxiov1.ImageSigner{}).
 		Owns(&tmaxiov1.SignerKey{}).
+		Watches(&source.Kind{Type: &tmaxiov1.SignerKey{}}, recordKeyRotations(r.Recorder)).
 		Complete(r)
 }
+
+// recordKeyRotations records events of keys which are rotated in updated signer keys. It does not enqueue requests
+func recordKeyRotations(recorder record.EventRecorder) handler.EventHandler {
+	return handler.Funcs{
+		UpdateFunc: func(e event.UpdateEvent, _ workqueue.RateLimitingInterface) {
+			old, ok := e.ObjectOld.(tmaxiov1.Key)
+			if !ok {
+				return
+			}
+			key, ok := e.ObjectNew.(tmaxiov1.Key)
+			if !ok {
+				return
+			}
+			for _, message := range controller.KeyRotations(old, key) {
+				recorder.Event(key, corev1.EventTypeNormal, controller.EventReasonKeyRotated, message)
+			}
+		},
+	}
+}
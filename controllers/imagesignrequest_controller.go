@@ -18,89 +18,350 @@ package controllers
 
 import (
 	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/codes"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	authorization "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	crcontroller "sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	tmaxiov1 "github.com/tmax-cloud/image-signing-operator/api/v1"
-	"github.com/tmax-cloud/image-signing-operator/internal/utils"
+	"github.com/tmax-cloud/image-signing-operator/pkg/access"
+	"github.com/tmax-cloud/image-signing-operator/pkg/audit"
 	"github.com/tmax-cloud/image-signing-operator/pkg/controller"
+	"github.com/tmax-cloud/image-signing-operator/pkg/metrics"
+	"github.com/tmax-cloud/image-signing-operator/pkg/reference"
+	"github.com/tmax-cloud/image-signing-operator/pkg/registry"
+	"github.com/tmax-cloud/image-signing-operator/pkg/scheduler"
+	"github.com/tmax-cloud/image-signing-operator/pkg/tracing"
 	"github.com/tmax-cloud/image-signing-operator/pkg/trust"
 )
 
+// QueuedRequeueInterval is an interval of queued requests to check the signing queue again
+const QueuedRequeueInterval = 10 * time.Second
+
 // ImageSignRequestReconciler reconciles a ImageSignRequest object
 type ImageSignRequestReconciler struct {
 	client.Client
 	Log    logr.Logger
 	Scheme *runtime.Scheme
+	// Scheduler limits concurrent signing sessions. If it is nil, sessions are not limited
+	Scheduler *scheduler.Scheduler
+	// MaxConcurrentReconciles is the number of reconcile workers
+	MaxConcurrentReconciles int
+	// Pool has pre-started pods of signers which have a worker pool
+	Pool     *controller.WorkerPool
+	Recorder record.EventRecorder
+	// AuthClient reviews if the requester is allowed to use the signer
+	AuthClient authorization.SubjectAccessReviewsGetter
 }
 
 // +kubebuilder:rbac:groups=tmax.io,resources=imagesignrequests,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=tmax.io,resources=imagesignrequests/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=get
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups="",resources=pods/exec,verbs=create
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// Secrets are only read cluster-wide: registry credentials and TLS secrets of requests are in their namespaces,
+// and secrets of NamespaceImageSigners are in the namespaces of the signers.
+// Secrets are written only in the operator namespace, which key_secret_role.yaml allows
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get
+// +kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=get;create;update
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
 
 func (r *ImageSignRequestReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
-	_ = context.Background()
+	ctx, span := tracing.Start(context.Background(), "ImageSignRequestReconciler.Reconcile", tracing.AttributeRequest.String(req.NamespacedName.String()))
+	defer span.End()
 	log := r.Log.WithValues("imagesignrequest", req.NamespacedName)
 
 	// get image sign request
 	log.Info("get image sign request")
 	signReq := &tmaxiov1.ImageSignRequest{}
 	if err := r.Get(context.TODO(), req.NamespacedName, signReq); err != nil {
+		if errors.IsNotFound(err) && r.Scheduler != nil {
+			r.Scheduler.Forget(req.NamespacedName)
+		}
 		log.Error(err, "")
 		return ctrl.Result{}, nil
 	}
+	span.SetAttributes(tracing.AttributeRequestUID.String(string(signReq.UID)))
+	ctx = tracing.WithRequestUID(ctx, string(signReq.UID))
 
-	if signReq.Status.ImageSignResponse != nil {
+	if signReq.Status.ImageSignResponse != nil &&
+		signReq.Status.Result != tmaxiov1.ResponseResultPendingApproval &&
+		signReq.Status.Result != tmaxiov1.ResponseResultQueued {
 		return ctrl.Result{}, nil
 	}
 
 	defer response(r.Client, signReq)
+	defer func() {
+		if resp := signReq.Status.ImageSignResponse; resp != nil && resp.Result == tmaxiov1.ResponseResultFail {
+			span.SetStatus(codes.Error, resp.Reason)
+		}
+	}()
+	defer metrics.ObserveResponse(signReq)
+
+	var signCtl *controller.SigningController
+	defer func() { r.recordFailure(signReq, signCtl) }()
 
 	// get image signer
 	log.Info("get image signer")
-	signer := &tmaxiov1.ImageSigner{}
-	if err := r.Get(context.TODO(), types.NamespacedName{Name: signReq.Spec.Signer}, signer); err != nil {
+	signer := tmaxiov1.NewSigner(signReq.Spec.SignerKind)
+	if err := r.Get(context.TODO(), signReq.SignerObjectKey(), signer); err != nil {
 		log.Error(err, "")
 		makeResponse(signReq, false, err.Error(), "")
 		return ctrl.Result{}, nil
 	}
 
+	// check if request namespace is allowed to use the signer
+	if err := access.CheckNamespace(r.Client, signer, req.Namespace); err != nil {
+		log.Error(err, "access denied")
+		makeResponse(signReq, false, tmaxiov1.ResponseReasonAccessDenied, err.Error())
+		return ctrl.Result{}, nil
+	}
+
+	// check if the requester is allowed to use the signer, which is not checked on admission if the webhook is not installed
+	requester := authenticationv1.UserInfo{Username: signReq.Spec.Requester, Groups: signReq.Spec.RequesterGroups}
+	if err := access.CheckUser(r.AuthClient, signer, requester); err != nil {
+		log.Error(err, "access denied")
+		makeResponse(signReq, false, tmaxiov1.ResponseReasonAccessDenied, err.Error())
+		return ctrl.Result{}, nil
+	}
+
+	// check if request is approved
+	if approval := signer.SignerSpec().Approval; approval != nil {
+		approved, rejection := countApprovals(signReq.Status.Approvals, signReq.Spec.Requester)
+		if rejection != nil {
+			log.Info("request is rejected", "user", rejection.User)
+			makeResponse(signReq, false, tmaxiov1.ResponseReasonRejected, fmt.Sprintf("rejected by %s: %s", rejection.User, rejection.Reason))
+			return ctrl.Result{}, nil
+		}
+		if approved < approval.Approvers {
+			log.Info("waiting for approvals", "approved", approved, "required", approval.Approvers)
+			makePendingResponse(signReq, fmt.Sprintf("%d/%d approvals", approved, approval.Approvers))
+			return ctrl.Result{}, nil
+		}
+	}
+
 	// get sign key
 	log.Info("get sign key")
-	signerKey := &tmaxiov1.SignerKey{}
-	if err := r.Get(context.TODO(), types.NamespacedName{Name: signReq.Spec.Signer}, signerKey); err != nil {
+	signerKey := signer.NewKey()
+	if err := r.Get(context.TODO(), tmaxiov1.KeyObjectKey(signer), signerKey); err != nil {
 		log.Error(err, "")
 		makeResponse(signReq, false, err.Error(), "")
 		return ctrl.Result{}, nil
 	}
 
+	// parse image
+	image, err := reference.Parse(signReq.Spec.Image)
+	if err != nil {
+		log.Error(err, "invalid image")
+		makeResponse(signReq, false, err.Error(), "")
+		return ctrl.Result{}, nil
+	}
+	targetName, err := buildTargetName(signReq, image)
+	if err != nil {
+		log.Error(err, "invalid repository")
+		makeResponse(signReq, false, err.Error(), "")
+		return ctrl.Result{}, nil
+	}
+
 	// get trust key
 	log.Info("get trust key")
-	rootKey := signerKey.Spec.Root
+	rootKey := signerKey.KeySpec().Root
 	var targetKey tmaxiov1.TrustKey
 
+	// keys of repositories of hardware root keys are resolved when the repository is initialized
+	hardwareRoot := rootKey.IsHardwareKey()
 	addedTargetKey := false
-	targetName := buildTargetName(signReq)
-	if _, ok := signerKey.Spec.Targets[targetName]; ok {
-		targetKey = signerKey.Spec.Targets[targetName]
-	} else {
+	if _, ok := signerKey.KeySpec().Targets[targetName]; ok {
+		targetKey = signerKey.KeySpec().Targets[targetName]
+	} else if !hardwareRoot {
 		phrase := trust.NewTrustPass()
 		phrase.AssignNewTargetPass()
 		targetKey.PassPhrase = phrase[trust.DctEnvKeyTarget]
 		addedTargetKey = true
 	}
 
+	// get snapshot key, if the signer manages snapshot keys instead of notary server
+	var snapshotKey *tmaxiov1.TrustKey
+	if signer.SignerSpec().SnapshotKeyManagement == tmaxiov1.SnapshotKeyManagementSigner && !hardwareRoot {
+		key, ok := signerKey.KeySpec().Snapshots[targetName]
+		if !ok {
+			log.Info("there is no snapshot key", "target", targetName)
+			makeResponse(signReq, false, tmaxiov1.ResponseReasonNoSnapshotKey,
+				fmt.Sprintf("signer %s manages snapshot keys, but there is no snapshot key of %s. Import it by keySecret.snapshots", signer.GetName(), targetName))
+			return ctrl.Result{}, nil
+		}
+		// docker unlocks both target and snapshot keys by the repository passphrase
+		if key.PassPhrase != targetKey.PassPhrase {
+			makeResponse(signReq, false, tmaxiov1.ResponseReasonNoSnapshotKey,
+				fmt.Sprintf("snapshot key of %s should have the same passphrase as its target key", targetName))
+			return ctrl.Result{}, nil
+		}
+		snapshotKey = &key
+	}
+
+	// get delegation key
+	delegation := trust.DelegationName(signReq.Spec.Delegation)
+	var delegationKey *tmaxiov1.TrustKey
+	if len(delegation) > 0 {
+		if err := trust.ValidateDelegationName(delegation); err != nil {
+			makeResponse(signReq, false, err.Error(), "")
+			return ctrl.Result{}, nil
+		}
+		if key, ok := signerKey.KeySpec().Delegations[delegation]; ok {
+			delegationKey = &key
+		}
+	}
+
+	// check notary server before signing
+	notaryServer, err := controller.GetNotaryServer(r.Client, signer, signReq)
+	if err != nil {
+		log.Error(err, "cannot get notary server")
+		makeResponse(signReq, false, tmaxiov1.ResponseReasonNotaryUnavailable, err.Error())
+		return ctrl.Result{}, nil
+	}
+	if notaryServer != nil {
+		if err := notaryServer.CheckHealth(); err != nil {
+			log.Error(err, "notary server is not available")
+			makeResponse(signReq, false, tmaxiov1.ResponseReasonNotaryUnavailable, err.Error())
+			return ctrl.Result{}, nil
+		}
+	}
+
+	// resolve the repository to push the image to
+	resolver, err := registry.NewResolver(r.Client, signReq)
+	if err != nil {
+		log.Error(err, "cannot resolve registry")
+		makeResponse(signReq, false, err.Error(), "")
+		return ctrl.Result{}, nil
+	}
+	host, err := registry.RepositoryHost(resolver, image.Path)
+	if err != nil {
+		log.Error(err, "cannot resolve registry")
+		makeResponse(signReq, false, err.Error(), "")
+		return ctrl.Result{}, nil
+	}
+	credential, err := registry.NewCredentialChain(r.Client, signReq).Resolve(host)
+	if err != nil {
+		log.Error(err, "cannot read registry credential")
+		makeResponse(signReq, false, tmaxiov1.ResponseReasonNoCredential, err.Error())
+		return ctrl.Result{}, nil
+	}
+
+	// check registry before signing
+	registryTLS, err := controller.GetRegistryTLS(r.Client, signReq)
+	if err != nil {
+		log.Error(err, "cannot get registry tls")
+		makeResponse(signReq, false, tmaxiov1.ResponseReasonRegistryUnavailable, err.Error())
+		return ctrl.Result{}, nil
+	}
+	if registryTLS != nil {
+		if _, err := registryTLS.Config(); err != nil {
+			log.Error(err, "registry tls is not valid")
+			makeResponse(signReq, false, tmaxiov1.ResponseReasonRegistryUnavailable, err.Error())
+			return ctrl.Result{}, nil
+		}
+		// the operator may not reach registries which signing pods can reach, so that the result is only logged
+		if err := registry.Ping(host, registryTLS); err != nil {
+			log.Info("registry is not reachable from the operator", "host", host, "error", err.Error())
+		}
+	}
+
+	// wait for a signing session
+	var lease *scheduler.Lease
+	if r.Scheduler != nil {
+		var position int
+		lease, position = r.Scheduler.Acquire(scheduler.Session{
+			Request:    req.NamespacedName,
+			Signer:     path.Join(signer.SignerKind(), signer.GetNamespace(), signer.GetName()),
+			Repository: targetName,
+		})
+		if lease == nil {
+			log.Info("waiting for a signing session", "position", position)
+			makeQueuedResponse(signReq, position)
+			return ctrl.Result{RequeueAfter: QueuedRequeueInterval}, nil
+		}
+		defer lease.Release()
+	}
+
+	// dind cannot use the root key in the token, so the repository is initialized by the operator
+	gun := ""
+	initRepository := false
+	if hardwareRoot {
+		if gun, err = resolver.Repository(image.Path); err != nil {
+			makeResponse(signReq, false, err.Error(), "")
+			return ctrl.Result{}, nil
+		}
+		newTargetKey, newSnapshotKey, newRepository, err := controller.PrepareHardwareRepository(r.Client, signer, signerKey, notaryServer, gun, targetName)
+		if err != nil {
+			log.Error(err, "cannot prepare repository with hardware root key")
+			makeResponse(signReq, false, tmaxiov1.ResponseReasonNoTargetKey, err.Error())
+			return ctrl.Result{}, nil
+		}
+		if _, ok := signerKey.KeySpec().Targets[targetName]; newRepository && !ok {
+			r.recordKeyAdded(signReq, signerKey, controller.EventReasonTargetKeyAdded, fmt.Sprintf("key of target %s is added", targetName))
+			audit.Record(auditEntry(signReq, signer, audit.EventKeyGenerated, string(trust.TrustRoleTarget), targetName, newTargetKey.ID))
+		}
+		targetKey, snapshotKey = *newTargetKey, newSnapshotKey
+		initRepository = newRepository
+	}
+
 	//
-	signCtl := controller.NewSigningController(r.Client, signer, signReq.Spec.RegistryLogin.Name, signReq.Spec.RegistryLogin.Namespace, req.Namespace)
+	signCtl = controller.NewSigningController(r.Client, signer, resolver, req.Namespace)
+	signCtl.Pool = r.Pool
+	signCtl.Context = ctx
+	signCtl.Log = signCtl.Log.WithValues("imagesignrequest", req.NamespacedName, "uid", signReq.UID)
+	signCtl.OnEvent = func(reason, message string) {
+		r.Recorder.Event(signReq, corev1.EventTypeNormal, reason, message)
+	}
+	if len(delegation) > 0 && delegationKey == nil {
+		// store the new key before it is added to the repository, so that it is not lost even if signing fails
+		log.Info("create delegation key", "delegation", delegation)
+		newKey, err := signCtl.CreateDelegationKey(delegation)
+		if err != nil {
+			makeResponse(signReq, false, err.Error(), "")
+			return ctrl.Result{}, nil
+		}
+		if err := signCtl.AddDelegationKey(signerKey, delegation, newKey); err != nil {
+			makeResponse(signReq, false, err.Error(), "")
+			return ctrl.Result{}, nil
+		}
+		r.recordKeyAdded(signReq, signerKey, controller.EventReasonDelegationKeyAdded, fmt.Sprintf("key of delegation %s is added", delegation))
+		audit.Record(auditEntry(signReq, signer, audit.EventKeyGenerated, trust.DelegationRolePrefix+delegation, targetName, newKey.ID))
+		delegationKey = newKey
+	}
+
 	cmdOpt := &controller.CommandOpt{
 		RootKey:                 &rootKey,
 		TargetKey:               &targetKey,
-		RegistryLoginSecret:     signReq.Spec.RegistryLogin.DcjSecretName,
+		RegistryCredential:      credential,
+		RegistryHost:            host,
+		RegistryTLS:             registryTLS,
+		RegistryCerts:           controller.RegistryCertsFiles(signReq, host, registryTLS),
 		RegistryLoginCertSecret: signReq.Spec.RegistryLogin.CertSecretName,
 		ImagePvc:                signReq.Spec.PvcName,
+		DelegationName:          delegation,
+		DelegationKey:           delegationKey,
+		SnapshotKey:             snapshotKey,
+		NotaryServer:            notaryServer,
 	}
 
 	log.Info("dind start")
@@ -117,9 +378,39 @@ func (r *ImageSignRequestReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 	}
 	log.Info("dind is running")
 
-	log.Info("sign image")
-	imageName, imageTag := utils.ParseImage(signReq.Spec.Image)
-	if err := signCtl.SignImage(imageName, imageTag); err != nil {
+	// only one session writes trust data of a repository at a time. The repository is locked after the pod is running,
+	// so that other sessions of the repository do not wait for the start of the pod
+	if lease != nil {
+		locked, err := lease.LockRepository()
+		if err != nil {
+			log.Error(err, "cannot lock repository")
+			makeResponse(signReq, false, err.Error(), "")
+			return ctrl.Result{}, nil
+		}
+		if !locked {
+			log.Info("repository is locked by another session", "repository", targetName)
+			makeQueuedResponse(signReq, 1)
+			return ctrl.Result{RequeueAfter: QueuedRequeueInterval}, nil
+		}
+	}
+
+	if initRepository {
+		if err := controller.InitHardwareRepository(r.Client, signer, notaryServer, gun, &targetKey, snapshotKey); err != nil {
+			log.Error(err, "cannot initialize repository with hardware root key")
+			makeResponse(signReq, false, tmaxiov1.ResponseReasonNoTargetKey, err.Error())
+			return ctrl.Result{}, nil
+		}
+	}
+
+	var digest string
+	if len(delegation) > 0 {
+		log.Info("sign image", "delegation", delegation)
+		digest, err = signCtl.SignImageWithDelegation(image, delegation, delegationKey)
+	} else {
+		log.Info("sign image")
+		digest, err = signCtl.SignImage(image)
+	}
+	if err != nil {
 		makeResponse(signReq, false, err.Error(), "")
 		return ctrl.Result{}, nil
 	}
@@ -128,31 +419,110 @@ func (r *ImageSignRequestReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 		log.Info("add target key to signerkey")
 		phrase := trust.NewTrustPass()
 		phrase[trust.DctEnvKeyTarget] = targetKey.PassPhrase
-		if err := signCtl.AddTargetKey(
+		newKey, err := signCtl.AddTargetKey(
 			signerKey,
-			buildTargetName(signReq),
+			targetName,
 			phrase,
-		); err != nil {
+		)
+		if err != nil {
 			makeResponse(signReq, false, err.Error(), "")
 			return ctrl.Result{}, nil
 		}
+		r.recordKeyAdded(signReq, signerKey, controller.EventReasonTargetKeyAdded, fmt.Sprintf("key of target %s is added", targetName))
+		audit.Record(auditEntry(signReq, signer, audit.EventKeyGenerated, string(trust.TrustRoleTarget), targetName, newKey.ID))
+		targetKey = *newKey
+	}
+
+	// the image is signed by the delegation key, or by the target key
+	signed := auditEntry(signReq, signer, audit.EventImageSigned, string(trust.TrustRoleTarget), targetName, targetKey.ID)
+	if len(delegation) > 0 {
+		signed.Role, signed.KeyID = trust.DelegationRolePrefix+delegation, delegationKey.ID
 	}
+	signed.Image, signed.Digest = image.String(), digest
+	audit.Record(signed)
 
 	makeResponse(signReq, true, "", "")
 	return ctrl.Result{}, nil
 }
 
 func (r *ImageSignRequestReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	b := ctrl.NewControllerManagedBy(mgr).
 		For(&tmaxiov1.ImageSignRequest{}).
-		Complete(r)
+		WithOptions(crcontroller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles})
+
+	// queued requests try again as soon as a session is released
+	if r.Scheduler != nil {
+		released := make(chan event.GenericEvent)
+		r.Scheduler.OnRelease = func(waiting []types.NamespacedName) {
+			go func() {
+				for _, req := range waiting {
+					signReq := &tmaxiov1.ImageSignRequest{ObjectMeta: metav1.ObjectMeta{Name: req.Name, Namespace: req.Namespace}}
+					released <- event.GenericEvent{Meta: signReq, Object: signReq}
+				}
+			}()
+		}
+		b = b.Watches(&source.Channel{Source: released}, &handler.EnqueueRequestForObject{})
+	}
+
+	return b.Complete(r)
+}
+
+// auditEntry returns an audit entry of the operator, for the request
+func auditEntry(signReq *tmaxiov1.ImageSignRequest, signer tmaxiov1.Signer, eventType audit.EventType, role, target, keyID string) audit.Entry {
+	return audit.Entry{
+		Type:    eventType,
+		User:    audit.UserOperator,
+		Signer:  audit.SignerName(signer.SignerKind(), signer.GetNamespace(), signer.GetName()),
+		Request: signReq.Namespace + "/" + signReq.Name,
+		Role:    role,
+		Target:  target,
+		KeyID:   keyID,
+	}
+}
+
+// recordFailure records an event of the failed request with the step where it failed
+func (r *ImageSignRequestReconciler) recordFailure(signReq *tmaxiov1.ImageSignRequest, signCtl *controller.SigningController) {
+	res := signReq.Status.ImageSignResponse
+	if res == nil || res.Result != tmaxiov1.ResponseResultFail {
+		return
+	}
+
+	step := controller.StepValidate
+	if signCtl != nil && len(signCtl.Step) > 0 {
+		step = signCtl.Step
+	}
+
+	message := fmt.Sprintf("failed to %s: %s", step, res.Reason)
+	if len(res.Message) > 0 {
+		message += ": " + res.Message
+	}
+	r.Recorder.Event(signReq, corev1.EventTypeWarning, controller.EventReasonSignFailed, message)
+}
+
+// recordKeyAdded records an event of the key added by the request, on both the request and the signer key
+func (r *ImageSignRequestReconciler) recordKeyAdded(signReq *tmaxiov1.ImageSignRequest, signerKey tmaxiov1.Key, reason, message string) {
+	r.Recorder.Event(signReq, corev1.EventTypeNormal, reason, message)
+	r.Recorder.Event(signerKey, corev1.EventTypeNormal, reason, fmt.Sprintf("%s by request %s/%s", message, signReq.Namespace, signReq.Name))
 }
 
-func buildTargetName(signReq *tmaxiov1.ImageSignRequest) string {
-	imageName, _ := utils.ParseImage(signReq.Spec.Image)
+// buildTargetName returns the name of the target key.
+// Normalized repository is used for the external registry. Its first component is a domain, which has a dot or a port
+// (e.g., docker.io/team/app), so that it is not the same as <namespace>/<registry>/<image> of registries in the cluster
+func buildTargetName(signReq *tmaxiov1.ImageSignRequest, image *reference.Reference) (string, error) {
+	if len(signReq.Spec.Repository) > 0 {
+		repo, err := reference.ParseRepository(signReq.Spec.Repository)
+		if err != nil {
+			return "", err
+		}
+		if !strings.ContainsAny(repo.Domain, ".:") {
+			return "", fmt.Errorf("repository %s should have a domain with a dot or a port (e.g., %s:80)", signReq.Spec.Repository, repo.Domain)
+		}
+		return repo.Name(), nil
+	}
+
 	return trust.BuildTargetName(
 		signReq.Spec.RegistryLogin.Name,
 		signReq.Spec.RegistryLogin.Namespace,
-		imageName,
-	)
+		image.Path,
+	), nil
 }
@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"testing"
+
+	tmaxiov1 "github.com/tmax-cloud/image-signing-operator/api/v1"
+	"github.com/tmax-cloud/image-signing-operator/pkg/reference"
+)
+
+func TestBuildTargetName(t *testing.T) {
+	image := &reference.Reference{Path: "app", Tag: "v1"}
+
+	tc := map[string]struct {
+		repository string
+		login      tmaxiov1.RegistryLogin
+		expected   string
+		expectErr  bool
+	}{
+		"registryLogin": {login: tmaxiov1.RegistryLogin{Name: "reg", Namespace: "team"}, expected: "team/reg/app"},
+		"dockerHub":     {repository: "team/app", expected: "docker.io/team/app"},
+		"official":      {repository: "app", expected: "docker.io/library/app"},
+		"external":      {repository: "myreg:5000/team/app", expected: "myreg:5000/team/app"},
+		// localhost/reg/app would be the same as registry reg in namespace localhost
+		"noDotOrPort": {repository: "localhost/reg/app", expectErr: true},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			signReq := &tmaxiov1.ImageSignRequest{}
+			signReq.Spec.Repository = c.repository
+			signReq.Spec.RegistryLogin = c.login
+
+			targetName, err := buildTargetName(signReq, image)
+			if c.expectErr {
+				if err == nil {
+					t.Fatalf("expected error, got %s", targetName)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if targetName != c.expected {
+				t.Errorf("expected %s, got %s", c.expected, targetName)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	tmaxiov1 "github.com/tmax-cloud/image-signing-operator/api/v1"
+	"github.com/tmax-cloud/image-signing-operator/pkg/controller"
+	"github.com/tmax-cloud/image-signing-operator/pkg/tracing"
+)
+
+// NamespaceImageSignerReconciler reconciles a NamespaceImageSigner object
+type NamespaceImageSignerReconciler struct {
+	client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// Pool is drained when the worker pool of a signer is changed, or the signer is deleted
+	Pool *controller.WorkerPool
+}
+
+// +kubebuilder:rbac:groups=tmax.io,resources=namespaceimagesigners,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=tmax.io,resources=namespaceimagesigners/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=tmax.io,resources=namespacesignerkeys,verbs=get;list;watch;create;update;patch;delete
+
+func (r *NamespaceImageSignerReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx, span := tracing.Start(context.Background(), "NamespaceImageSignerReconciler.Reconcile", tracing.AttributeSigner.String(req.NamespacedName.String()))
+	defer span.End()
+	log := r.Log.WithValues("namespaceimagesigner", req.NamespacedName)
+
+	// get namespace image signer
+	signer := &tmaxiov1.NamespaceImageSigner{}
+	if err := r.Get(context.TODO(), req.NamespacedName, signer); err != nil {
+		if errors.IsNotFound(err) && r.Pool != nil {
+			signer.Name, signer.Namespace = req.Name, req.Namespace
+			r.Pool.Drain(signer)
+		}
+		log.Error(err, "")
+		return ctrl.Result{}, nil
+	}
+	if r.Pool != nil {
+		r.Pool.Sync(signer)
+	}
+
+	return reconcileSigner(ctx, r.Client, r.Scheme, r.Recorder, log, signer)
+}
+
+func (r *NamespaceImageSignerReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&tmaxiov1.NamespaceImageSigner{}).
+		Owns(&tmaxiov1.NamespaceSignerKey{}).
+		Watches(&source.Kind{Type: &tmaxiov1.NamespaceSignerKey{}}, recordKeyRotations(r.Recorder)).
+		Complete(r)
+}
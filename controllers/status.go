@@ -2,13 +2,14 @@ package controllers
 
 import (
 	"context"
+	"fmt"
 
 	tmaxiov1 "github.com/tmax-cloud/image-signing-operator/api/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func updateSignerStatus(c client.Client, signer *tmaxiov1.ImageSigner) error {
+func updateSignerStatus(c client.Client, signer tmaxiov1.Signer) error {
 	if err := c.Status().Update(context.TODO(), signer); err != nil {
 		return err
 	}
@@ -16,16 +17,17 @@ func updateSignerStatus(c client.Client, signer *tmaxiov1.ImageSigner) error {
 	return nil
 }
 
-func makeSignerStatus(signer *tmaxiov1.ImageSigner, created bool, reason, message string, key *tmaxiov1.TrustKey) {
-	signer.Status.SignerKeyState = &tmaxiov1.SignerKeyState{}
+func makeSignerStatus(signer tmaxiov1.Signer, created bool, reason, message string, key *tmaxiov1.TrustKey) {
+	status := signer.SignerStatus()
+	status.SignerKeyState = &tmaxiov1.SignerKeyState{}
 	if created {
-		signer.Status.Created = true
-		signer.Status.CreatedAt = metav1.Now()
-		signer.Status.RootKeyID = key.ID
+		status.Created = true
+		status.CreatedAt = metav1.Now()
+		status.RootKeyID = key.ID
 	} else {
-		signer.Status.Created = false
-		signer.Status.Reason = reason
-		signer.Status.Message = message
+		status.Created = false
+		status.Reason = reason
+		status.Message = message
 	}
 }
 
@@ -47,3 +49,32 @@ func makeResponse(signReq *tmaxiov1.ImageSignRequest, result bool, reason, messa
 	signReq.Status.Reason = reason
 	signReq.Status.Message = message
 }
+
+func makePendingResponse(signReq *tmaxiov1.ImageSignRequest, message string) {
+	signReq.Status.ImageSignResponse = &tmaxiov1.ImageSignResponse{}
+	signReq.Status.Result = tmaxiov1.ResponseResultPendingApproval
+	signReq.Status.Message = message
+}
+
+func makeQueuedResponse(signReq *tmaxiov1.ImageSignRequest, position int) {
+	signReq.Status.ImageSignResponse = &tmaxiov1.ImageSignResponse{}
+	signReq.Status.Result = tmaxiov1.ResponseResultQueued
+	signReq.Status.Message = fmt.Sprintf("waiting for a signing session, position %d", position)
+	signReq.Status.QueuePosition = position
+}
+
+// countApprovals returns the number of approvals and the first rejection, ignoring reviews of the requester
+func countApprovals(approvals []tmaxiov1.Approval, requester string) (int, *tmaxiov1.Approval) {
+	approved := 0
+	for i, a := range approvals {
+		if a.User == requester {
+			continue
+		}
+		if !a.Approved {
+			return approved, &approvals[i]
+		}
+		approved++
+	}
+
+	return approved, nil
+}
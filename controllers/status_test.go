@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"testing"
+
+	tmaxiov1 "github.com/tmax-cloud/image-signing-operator/api/v1"
+)
+
+func TestCountApprovals(t *testing.T) {
+	tc := map[string]struct {
+		approvals  []tmaxiov1.Approval
+		approved   int
+		rejectedBy string
+	}{
+		"approved": {
+			approvals: []tmaxiov1.Approval{{User: "bob", Approved: true}, {User: "carol", Approved: true}},
+			approved:  2,
+		},
+		"rejected": {
+			approvals:  []tmaxiov1.Approval{{User: "bob", Approved: true}, {User: "carol", Approved: false}},
+			approved:   1,
+			rejectedBy: "carol",
+		},
+		"selfApproval": {
+			approvals: []tmaxiov1.Approval{{User: "alice", Approved: true}, {User: "bob", Approved: true}},
+			approved:  1,
+		},
+		"selfRejection": {
+			approvals: []tmaxiov1.Approval{{User: "alice", Approved: false}},
+			approved:  0,
+		},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			approved, rejection := countApprovals(c.approvals, "alice")
+			if approved != c.approved {
+				t.Errorf("expected %d approvals, got %d", c.approved, approved)
+			}
+			rejectedBy := ""
+			if rejection != nil {
+				rejectedBy = rejection.User
+			}
+			if rejectedBy != c.rejectedBy {
+				t.Errorf("expected rejection by %q, got %q", c.rejectedBy, rejectedBy)
+			}
+		})
+	}
+}
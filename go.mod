@@ -1,13 +1,21 @@
 module github.com/tmax-cloud/image-signing-operator
 
-go 1.13
+go 1.15
 
 require (
 	github.com/go-logr/logr v0.1.0
 	github.com/gorilla/mux v1.8.0
+	github.com/miekg/pkcs11 v1.1.1
 	github.com/onsi/ginkgo v1.12.1
 	github.com/onsi/gomega v1.10.1
 	github.com/operator-framework/operator-lib v0.1.0
+	github.com/prometheus/client_golang v1.8.0
+	go.opentelemetry.io/otel v1.0.1
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.0.1
+	go.opentelemetry.io/otel/sdk v1.0.1
+	go.opentelemetry.io/otel/trace v1.0.1
+	go.uber.org/zap v1.16.0
+	golang.org/x/crypto v0.0.0-20201002170205-7f63de1d35b0
 	k8s.io/api v0.18.8
 	k8s.io/apimachinery v0.18.8
 	k8s.io/client-go v0.18.8
@@ -39,8 +39,11 @@ func ExecCmd(podName, containerName, namespace string,
 		Stderr:  true,
 		TTY:     true,
 	}
+	// input is streamed as it is, without echo of a terminal
 	if stdin == nil {
 		option.Stdin = false
+	} else {
+		option.TTY = false
 	}
 	req.VersionedParams(
 		option,
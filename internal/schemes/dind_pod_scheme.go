@@ -1,6 +1,7 @@
 package schemes
 
 import (
+	"sort"
 	"strings"
 
 	corev1 "k8s.io/api/core/v1"
@@ -86,29 +87,82 @@ func WithLifeCycle(cmd []string) PodOption {
 	}
 }
 
-func WithDcjSecret(secretName string) PodOption {
+// WithLabels adds labels to the pod
+func WithLabels(labels map[string]string) PodOption {
 	return func(pod *corev1.Pod) {
-		if len(secretName) == 0 {
+		for k, v := range labels {
+			pod.Labels[k] = v
+		}
+	}
+}
+
+// WithArgs adds arguments of dockerd
+func WithArgs(args []string) PodOption {
+	return func(pod *corev1.Pod) {
+		if len(args) == 0 {
 			return
 		}
+		pod.Spec.Containers[0].Args = append(pod.Spec.Containers[0].Args, args...)
+	}
+}
 
-		const (
-			VolName = "dockerconfigjson"
-			VolPath = "/home/dockremap"
-		)
+// SecretFiles are keys of a secret which are mounted as files in a directory
+type SecretFiles struct {
+	SecretName string
+	Dir        string
+	// Items are file names of the keys
+	Items map[string]string
+}
+
+// String identifies the files, e.g., for pods which are pooled by their spec
+func (f *SecretFiles) String() string {
+	if f == nil {
+		return ""
+	}
+	keys := make([]string, 0, len(f.Items))
+	for key := range f.Items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	items := make([]string, 0, len(keys))
+	for _, key := range keys {
+		items = append(items, key+"="+f.Items[key])
+	}
+	return f.SecretName + ":" + f.Dir + ":" + strings.Join(items, ",")
+}
+
+// WithSecretFiles mounts the keys of the secret in the directory, read-only
+func WithSecretFiles(volName string, files *SecretFiles) PodOption {
+	return func(pod *corev1.Pod) {
+		if files == nil || len(files.Items) == 0 {
+			return
+		}
+		keys := make([]string, 0, len(files.Items))
+		for key := range files.Items {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		items := make([]corev1.KeyToPath, 0, len(keys))
+		for _, key := range keys {
+			items = append(items, corev1.KeyToPath{Key: key, Path: files.Items[key]})
+		}
 
 		pod.Spec.Containers[0].VolumeMounts = append(pod.Spec.Containers[0].VolumeMounts,
 			corev1.VolumeMount{
-				Name:      VolName,
-				MountPath: VolPath,
+				Name:      volName,
+				MountPath: files.Dir,
+				ReadOnly:  true,
 			},
 		)
 		pod.Spec.Volumes = append(pod.Spec.Volumes,
 			corev1.Volume{
-				Name: VolName,
+				Name: volName,
 				VolumeSource: corev1.VolumeSource{
 					Secret: &corev1.SecretVolumeSource{
-						SecretName: secretName,
+						SecretName: files.SecretName,
+						Items:      items,
 					},
 				},
 			},
@@ -2,13 +2,14 @@ package schemes
 
 import (
 	apiv1 "github.com/tmax-cloud/image-signing-operator/api/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-func SignerKey(signer *apiv1.ImageSigner) *apiv1.SignerKey {
-	return &apiv1.SignerKey{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: signer.Name,
-		},
-	}
+// SignerKey returns a key object for the signer.
+// SignerKey for ImageSigner, NamespaceSignerKey in the same namespace for NamespaceImageSigner
+func SignerKey(signer apiv1.Signer) apiv1.Key {
+	key := signer.NewKey()
+	key.SetName(signer.GetName())
+	key.SetNamespace(signer.GetNamespace())
+
+	return key
 }
@@ -52,6 +52,20 @@ func OperatorServiceName() string {
 	return svcName
 }
 
+// OperatorUserName returns the user name of the service account of the operator,
+// which is given by OPERATOR_SERVICE_ACCOUNT (default: default)
+func OperatorUserName() (string, error) {
+	ns, err := Namespace()
+	if err != nil {
+		return "", err
+	}
+	sa := os.Getenv("OPERATOR_SERVICE_ACCOUNT")
+	if sa == "" {
+		sa = "default"
+	}
+	return fmt.Sprintf("system:serviceaccount:%s:%s", ns, sa), nil
+}
+
 func FileExists(path string) bool {
 	info, err := os.Stat(path)
 	if os.IsNotExist(err) {
@@ -10,6 +10,17 @@ import (
 
 type HandleFunc func(http.ResponseWriter, *http.Request)
 
+// Resource describes the resource served by a handler, for discovery and OpenAPI documents
+type Resource struct {
+	// Kind is the kind of the resource, or of the object the subresource handler serves
+	Kind string
+	// Body and Response are zero values of the request body and the response, used to generate their schemas
+	Body     interface{}
+	Response interface{}
+	// Watch is true if the list handler streams changes with ?watch=true
+	Watch bool
+}
+
 type RouterWrapper struct {
 	Router *mux.Router
 
@@ -17,6 +28,9 @@ type RouterWrapper struct {
 	Methods []string
 	Handler HandleFunc
 
+	// Resource is nil if the handler is not advertised in discovery (e.g., discovery documents themselves)
+	Resource *Resource
+
 	Children []*RouterWrapper
 	Parent   *RouterWrapper
 }
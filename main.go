@@ -17,19 +17,29 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"github.com/tmax-cloud/image-signing-operator/pkg/apiserver"
 	"os"
 
+	uzap "go.uber.org/zap"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	tmaxiov1 "github.com/tmax-cloud/image-signing-operator/api/v1"
 	"github.com/tmax-cloud/image-signing-operator/controllers"
+	"github.com/tmax-cloud/image-signing-operator/internal/utils"
+	"github.com/tmax-cloud/image-signing-operator/pkg/audit"
+	"github.com/tmax-cloud/image-signing-operator/pkg/controller"
+	"github.com/tmax-cloud/image-signing-operator/pkg/metrics"
+	"github.com/tmax-cloud/image-signing-operator/pkg/scheduler"
+	"github.com/tmax-cloud/image-signing-operator/pkg/tracing"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -48,13 +58,45 @@ func init() {
 func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
+	var maxSigningSessions, maxSigningSessionsPerSigner int
+	var auditSink, auditTarget string
+	var tracingEndpoint string
+	var tracingInsecure bool
+	var debug bool
+	var apiCertSecret string
 	flag.StringVar(&metricsAddr, "metrics-addr", ":18080", "The address the metric endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.IntVar(&maxSigningSessions, "max-signing-sessions", 4,
+		"The maximum number of concurrent signing sessions. Each session runs a privileged dind pod.")
+	flag.IntVar(&maxSigningSessionsPerSigner, "max-signing-sessions-per-signer", 0,
+		"The maximum number of concurrent signing sessions of each signer. Zero means no limit other than max-signing-sessions.")
+	flag.StringVar(&auditSink, "audit-sink", audit.SinkStdout,
+		"The sink of the audit log of key accesses and signatures: file, stdout, webhook or none. "+
+			"Entries are chained by HMAC with the key in the image-signing-audit secret of the operator namespace.")
+	flag.StringVar(&auditTarget, "audit-target", "",
+		"The file path of the file sink, or the URL of the webhook sink.")
+	flag.StringVar(&tracingEndpoint, "tracing-endpoint", "",
+		"The OTLP/HTTP endpoint (host:port) to which traces are exported. Tracing is disabled if it is empty.")
+	flag.BoolVar(&tracingInsecure, "tracing-insecure", false, "Export traces without TLS.")
+	flag.BoolVar(&debug, "debug", false,
+		"Log debug messages, including output of commands in signing pods. Keys and passphrases are redacted from the output.")
+	flag.StringVar(&apiCertSecret, "api-cert-secret", "",
+		"The TLS secret in the operator namespace (e.g., issued by cert-manager) serving the extension api server. "+
+			"If it is empty, a self-signed certificate is generated and rotated before it expires.")
 	flag.Parse()
 
-	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
+	logLevel := uzap.NewAtomicLevelAt(uzap.InfoLevel)
+	if debug {
+		logLevel.SetLevel(uzap.DebugLevel)
+	}
+	ctrl.SetLogger(zap.New(zap.UseDevMode(true), zap.Level(&logLevel)))
+
+	if maxSigningSessions < 1 {
+		setupLog.Info("max-signing-sessions should be positive, 1 is used", "max-signing-sessions", maxSigningSessions)
+		maxSigningSessions = 1
+	}
 
 	// test
 	os.Setenv("OPERATOR_NAMESPACE", "reg-test")
@@ -63,6 +105,13 @@ func main() {
 		os.Setenv("OPERATOR_NAMESPACE", "registry-system")
 	}
 
+	shutdownTracing, err := tracing.Setup(context.Background(), tracingEndpoint, tracingInsecure)
+	if err != nil {
+		setupLog.Error(err, "unable to set up tracing")
+		os.Exit(1)
+	}
+	defer shutdownTracing(context.Background())
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:             scheme,
 		MetricsBindAddress: metricsAddr,
@@ -75,26 +124,97 @@ func main() {
 		os.Exit(1)
 	}
 
+	// the audit secret is read before the cache is started, and leases of repositories are written by other replicas,
+	// so they are read without the cache
+	directClient, err := client.New(mgr.GetConfig(), client.Options{Scheme: mgr.GetScheme()})
+	if err != nil {
+		setupLog.Error(err, "unable to create client")
+		os.Exit(1)
+	}
+
+	// identity distinguishes replicas, which share leases of repositories and the chain of the audit log
+	identity, err := os.Hostname()
+	if err != nil {
+		setupLog.Error(err, "unable to get hostname")
+		os.Exit(1)
+	}
+
+	sink, err := audit.NewSink(auditSink, auditTarget)
+	if err != nil {
+		setupLog.Error(err, "unable to create audit sink")
+		os.Exit(1)
+	}
+	if sink != nil {
+		store, key, err := audit.LoadSecret(directClient, os.Getenv("OPERATOR_NAMESPACE"), audit.DefaultSecretName)
+		if err != nil {
+			setupLog.Error(err, "unable to load audit key")
+			os.Exit(1)
+		}
+		auditLog, err := audit.New(sink, key, store, identity)
+		if err != nil {
+			setupLog.Error(err, "unable to open audit log")
+			os.Exit(1)
+		}
+		defer auditLog.Close()
+		audit.SetDefault(auditLog)
+	}
+
+	workerPool := controller.NewWorkerPool(mgr.GetClient(), mgr.GetScheme())
+	if err := mgr.Add(workerPool); err != nil {
+		setupLog.Error(err, "unable to add worker pool")
+		os.Exit(1)
+	}
 	if err = (&controllers.ImageSignerReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("ImageSigner"),
-		Scheme: mgr.GetScheme(),
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("ImageSigner"),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("imagesigner-controller"),
+		Pool:     workerPool,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ImageSigner")
 		os.Exit(1)
 	}
+	signingScheduler := scheduler.New(maxSigningSessions, maxSigningSessionsPerSigner)
+	signingScheduler.Locks = scheduler.NewRepositoryLocks(directClient, os.Getenv("OPERATOR_NAMESPACE"), identity)
+	if err := metrics.RegisterQueue(ctrlmetrics.Registry, signingScheduler.Waiting, signingScheduler.Running); err != nil {
+		setupLog.Error(err, "unable to register metrics")
+		os.Exit(1)
+	}
+	ctrlmetrics.Registry.MustRegister(metrics.NewInventoryCollector(mgr.GetClient()))
+
+	authClient, err := utils.AuthClient()
+	if err != nil {
+		setupLog.Error(err, "unable to create auth client")
+		os.Exit(1)
+	}
 	if err = (&controllers.ImageSignRequestReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("ImageSignRequest"),
-		Scheme: mgr.GetScheme(),
+		Client:    mgr.GetClient(),
+		Log:       ctrl.Log.WithName("controllers").WithName("ImageSignRequest"),
+		Scheme:    mgr.GetScheme(),
+		Scheduler: signingScheduler,
+		// one more worker keeps queue positions up to date while all sessions are running
+		MaxConcurrentReconciles: maxSigningSessions + 1,
+		Pool:                    workerPool,
+		Recorder:                mgr.GetEventRecorderFor("imagesignrequest-controller"),
+		AuthClient:              authClient,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ImageSignRequest")
 		os.Exit(1)
 	}
+	if err = (&controllers.NamespaceImageSignerReconciler{
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("NamespaceImageSigner"),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("namespaceimagesigner-controller"),
+		Pool:     workerPool,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "NamespaceImageSigner")
+		os.Exit(1)
+	}
 	// +kubebuilder:scaffold:builder
 
 	// API Server
-	apiServer := apiserver.New()
+	apiServer := apiserver.New(apiCertSecret)
 	go apiServer.Start()
 
 	setupLog.Info("starting manager")
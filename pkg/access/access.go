@@ -0,0 +1,130 @@
+package access
+
+import (
+	"context"
+	"fmt"
+
+	apiv1 "github.com/tmax-cloud/image-signing-operator/api/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	authorization "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// VerbUse is a verb for using an ImageSigner to sign images
+	VerbUse = "use"
+	// SignerResource is a resource name of ImageSigner
+	SignerResource = "imagesigners"
+	// NamespaceSignerResource is a resource name of NamespaceImageSigner
+	NamespaceSignerResource = "namespaceimagesigners"
+
+	serviceAccountPrefix = "system:serviceaccount:"
+)
+
+// CheckNamespace returns error if ImageSignRequests in the namespace are not allowed to use the signer
+// NamespaceImageSigner can only be used in its own namespace
+func CheckNamespace(c client.Client, signer apiv1.Signer, namespace string) error {
+	if len(signer.GetNamespace()) > 0 && signer.GetNamespace() != namespace {
+		return fmt.Errorf("namespace %s is not allowed to use signer %s/%s", namespace, signer.GetNamespace(), signer.GetName())
+	}
+
+	ac := signer.SignerSpec().AccessControl
+	if ac == nil || (len(ac.Namespaces) == 0 && ac.NamespaceSelector == nil) {
+		return nil
+	}
+
+	for _, ns := range ac.Namespaces {
+		if ns == namespace {
+			return nil
+		}
+	}
+
+	if ac.NamespaceSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(ac.NamespaceSelector)
+		if err != nil {
+			return err
+		}
+
+		ns := &corev1.Namespace{}
+		if err := c.Get(context.TODO(), types.NamespacedName{Name: namespace}, ns); err != nil {
+			return err
+		}
+		if selector.Matches(labels.Set(ns.Labels)) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("namespace %s is not allowed to use signer %s", namespace, signer.GetName())
+}
+
+// CheckUser returns error if the user is not allowed to use the signer.
+// The user is allowed if one of the service accounts or the groups of the access list matches,
+// or if SubjectAccessReview allows "use" verb on the signer.
+func CheckUser(authCli authorization.SubjectAccessReviewsGetter, signer apiv1.Signer, user authenticationv1.UserInfo) error {
+	ac := signer.SignerSpec().AccessControl
+	if ac == nil {
+		return nil
+	}
+	if len(user.Username) == 0 {
+		return fmt.Errorf("user is unknown, so cannot use signer %s", signer.GetName())
+	}
+
+	for _, sa := range ac.ServiceAccounts {
+		if user.Username == serviceAccountPrefix+sa {
+			return nil
+		}
+	}
+
+	for _, group := range ac.Groups {
+		for _, g := range user.Groups {
+			if g == group {
+				return nil
+			}
+		}
+	}
+
+	extra := map[string]authorizationv1.ExtraValue{}
+	for k, v := range user.Extra {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+
+	r := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   user.Username,
+			UID:    user.UID,
+			Groups: user.Groups,
+			Extra:  extra,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: signer.GetNamespace(),
+				Name:      signer.GetName(),
+				Group:     apiv1.GroupVersion.Group,
+				Version:   apiv1.GroupVersion.Version,
+				Resource:  resourceName(signer),
+				Verb:      VerbUse,
+			},
+		},
+	}
+
+	result, err := authCli.SubjectAccessReviews().Create(context.TODO(), r, metav1.CreateOptions{})
+	if err != nil {
+		return err
+	}
+
+	if result.Status.Allowed {
+		return nil
+	}
+
+	return fmt.Errorf("user %s is not allowed to use signer %s", user.Username, signer.GetName())
+}
+
+func resourceName(signer apiv1.Signer) string {
+	if signer.SignerKind() == apiv1.SignerKindNamespaceImageSigner {
+		return NamespaceSignerResource
+	}
+	return SignerResource
+}
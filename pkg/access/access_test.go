@@ -0,0 +1,147 @@
+package access
+
+import (
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	apiv1 "github.com/tmax-cloud/image-signing-operator/api/v1"
+)
+
+func newTestSigner(namespace string, ac *apiv1.SignerAccessControl) apiv1.Signer {
+	meta := metav1.ObjectMeta{Name: "signer", Namespace: namespace}
+	spec := apiv1.ImageSignerSpec{AccessControl: ac}
+	if len(namespace) > 0 {
+		return &apiv1.NamespaceImageSigner{ObjectMeta: meta, Spec: spec}
+	}
+	return &apiv1.ImageSigner{ObjectMeta: meta, Spec: spec}
+}
+
+func TestCheckNamespace(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	cli := fake.NewFakeClientWithScheme(scheme,
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team", Labels: map[string]string{"signing": "enabled"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "dev"}},
+	)
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{"signing": "enabled"}}
+
+	tc := map[string]struct {
+		signer    apiv1.Signer
+		namespace string
+		allowed   bool
+	}{
+		"noAccessControl":         {signer: newTestSigner("", nil), namespace: "dev", allowed: true},
+		"emptyAccessControl":      {signer: newTestSigner("", &apiv1.SignerAccessControl{ServiceAccounts: []string{"team:ci"}}), namespace: "dev", allowed: true},
+		"listed":                  {signer: newTestSigner("", &apiv1.SignerAccessControl{Namespaces: []string{"dev", "prod"}}), namespace: "dev", allowed: true},
+		"notListed":               {signer: newTestSigner("", &apiv1.SignerAccessControl{Namespaces: []string{"prod"}}), namespace: "dev"},
+		"selected":                {signer: newTestSigner("", &apiv1.SignerAccessControl{NamespaceSelector: selector}), namespace: "team", allowed: true},
+		"notSelected":             {signer: newTestSigner("", &apiv1.SignerAccessControl{NamespaceSelector: selector}), namespace: "dev"},
+		"listedOrSelected":        {signer: newTestSigner("", &apiv1.SignerAccessControl{Namespaces: []string{"dev"}, NamespaceSelector: selector}), namespace: "dev", allowed: true},
+		"selectedNamespaceAbsent": {signer: newTestSigner("", &apiv1.SignerAccessControl{NamespaceSelector: selector}), namespace: "none"},
+		"ownNamespace":            {signer: newTestSigner("team", nil), namespace: "team", allowed: true},
+		"otherNamespace":          {signer: newTestSigner("team", nil), namespace: "dev"},
+		"otherNamespaceListed":    {signer: newTestSigner("team", &apiv1.SignerAccessControl{Namespaces: []string{"dev"}}), namespace: "dev"},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			err := CheckNamespace(cli, c.signer, c.namespace)
+			if c.allowed && err != nil {
+				t.Fatalf("expected to be allowed, got %v", err)
+			}
+			if !c.allowed && err == nil {
+				t.Fatal("expected to be denied")
+			}
+		})
+	}
+}
+
+func TestCheckUser(t *testing.T) {
+	ac := &apiv1.SignerAccessControl{ServiceAccounts: []string{"team:ci"}, Groups: []string{"signers"}}
+
+	tc := map[string]struct {
+		signer apiv1.Signer
+		user   authenticationv1.UserInfo
+		// sar is the result of SubjectAccessReview, which is not requested if it is nil
+		sar     *bool
+		allowed bool
+	}{
+		"noAccessControl": {signer: newTestSigner("", nil), user: authenticationv1.UserInfo{Username: "alice"}, allowed: true},
+		"serviceAccount": {
+			signer:  newTestSigner("", ac),
+			user:    authenticationv1.UserInfo{Username: "system:serviceaccount:team:ci"},
+			allowed: true,
+		},
+		"otherServiceAccount": {
+			signer: newTestSigner("", ac),
+			user:   authenticationv1.UserInfo{Username: "system:serviceaccount:dev:ci"},
+			sar:    boolPtr(false),
+		},
+		"group": {
+			signer:  newTestSigner("", ac),
+			user:    authenticationv1.UserInfo{Username: "alice", Groups: []string{"system:authenticated", "signers"}},
+			allowed: true,
+		},
+		"sarAllowed": {
+			signer:  newTestSigner("team", ac),
+			user:    authenticationv1.UserInfo{Username: "bob", Groups: []string{"system:authenticated"}},
+			sar:     boolPtr(true),
+			allowed: true,
+		},
+		"sarDenied": {
+			signer: newTestSigner("", ac),
+			user:   authenticationv1.UserInfo{Username: "bob"},
+			sar:    boolPtr(false),
+		},
+		"unknownUser": {signer: newTestSigner("", ac)},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			clientset := k8sfake.NewSimpleClientset()
+			var review *authorizationv1.SubjectAccessReview
+			clientset.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				review = action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+				if c.sar == nil {
+					t.Fatal("unexpected SubjectAccessReview")
+				}
+				review.Status.Allowed = *c.sar
+				return true, review, nil
+			})
+
+			err := CheckUser(clientset.AuthorizationV1(), c.signer, c.user)
+			if c.allowed && err != nil {
+				t.Fatalf("expected to be allowed, got %v", err)
+			}
+			if !c.allowed && err == nil {
+				t.Fatal("expected to be denied")
+			}
+			if c.sar == nil {
+				return
+			}
+
+			if review == nil {
+				t.Fatal("expected SubjectAccessReview")
+			}
+			attrs := review.Spec.ResourceAttributes
+			if review.Spec.User != c.user.Username || attrs.Verb != VerbUse || attrs.Name != "signer" ||
+				attrs.Namespace != c.signer.GetNamespace() || attrs.Resource != resourceName(c.signer) {
+				t.Fatalf("unexpected SubjectAccessReview %+v, %+v", review.Spec, attrs)
+			}
+		})
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
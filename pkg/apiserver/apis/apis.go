@@ -1,13 +1,13 @@
 package apis
 
 import (
-	"fmt"
 	"net/http"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/tmax-cloud/image-signing-operator/internal/utils"
 	"github.com/tmax-cloud/image-signing-operator/internal/wrapper"
+	"github.com/tmax-cloud/image-signing-operator/pkg/apiserver/discovery"
 )
 
 const (
@@ -18,7 +18,8 @@ const (
 var AddApiFuncs []func(*wrapper.RouterWrapper) error
 
 func AddApis(parent *wrapper.RouterWrapper) error {
-	apiWrapper := wrapper.New("/apis", nil, apisHandler)
+	apiWrapper := wrapper.New("/apis", nil, nil)
+	apiWrapper.Handler = apisHandler(apiWrapper)
 	if err := parent.Add(apiWrapper); err != nil {
 		return err
 	}
@@ -32,25 +33,39 @@ func AddApis(parent *wrapper.RouterWrapper) error {
 	return nil
 }
 
-func apisHandler(w http.ResponseWriter, _ *http.Request) {
-	groupVersion := metav1.GroupVersionForDiscovery{
-		GroupVersion: fmt.Sprintf("%s/%s", ApiGroup, ApiVersion),
-		Version:      ApiVersion,
-	}
+// apisHandler returns the groups of the apis registered under the api wrapper.
+// The first registered version of a group is its preferred version
+func apisHandler(apiWrapper *wrapper.RouterWrapper) wrapper.HandleFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		apiGroupList := &metav1.APIGroupList{}
+		apiGroupList.Kind = "APIGroupList"
+		apiGroupList.Groups = []metav1.APIGroup{}
+
+		index := map[string]int{}
+		for _, gv := range discovery.GroupVersions(apiWrapper) {
+			groupVersion := metav1.GroupVersionForDiscovery{
+				GroupVersion: gv.String(),
+				Version:      gv.Version,
+			}
+
+			i, exist := index[gv.Group]
+			if !exist {
+				i = len(apiGroupList.Groups)
+				index[gv.Group] = i
 
-	group := metav1.APIGroup{}
-	group.Kind = "APIGroup"
-	group.Name = ApiGroup
-	group.PreferredVersion = groupVersion
-	group.Versions = append(group.Versions, groupVersion)
-	group.ServerAddressByClientCIDRs = append(group.ServerAddressByClientCIDRs, metav1.ServerAddressByClientCIDR{
-		ClientCIDR:    "0.0.0.0/0",
-		ServerAddress: "",
-	})
-
-	apiGroupList := &metav1.APIGroupList{}
-	apiGroupList.Kind = "APIGroupList"
-	apiGroupList.Groups = append(apiGroupList.Groups, group)
-
-	_ = utils.RespondJSON(w, apiGroupList)
+				group := metav1.APIGroup{}
+				group.Kind = "APIGroup"
+				group.Name = gv.Group
+				group.PreferredVersion = groupVersion
+				group.ServerAddressByClientCIDRs = append(group.ServerAddressByClientCIDRs, metav1.ServerAddressByClientCIDR{
+					ClientCIDR:    "0.0.0.0/0",
+					ServerAddress: "",
+				})
+				apiGroupList.Groups = append(apiGroupList.Groups, group)
+			}
+			apiGroupList.Groups[i].Versions = append(apiGroupList.Groups[i].Versions, groupVersion)
+		}
+
+		_ = utils.RespondJSON(w, apiGroupList)
+	}
 }
@@ -3,18 +3,27 @@ package v1
 import (
 	"fmt"
 	tmaxiov1 "github.com/tmax-cloud/image-signing-operator/api/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	authorization "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	"k8s.io/client-go/rest"
 	"net/http"
 	"os"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 
 	"github.com/tmax-cloud/image-signing-operator/internal/utils"
 	"github.com/tmax-cloud/image-signing-operator/internal/wrapper"
+	"github.com/tmax-cloud/image-signing-operator/pkg/apiserver/auth"
+	"github.com/tmax-cloud/image-signing-operator/pkg/apiserver/discovery"
 )
 
 const (
@@ -22,14 +31,31 @@ const (
 	ApiVersion = "v1"
 	SignerKind = "imagesigners"
 
+	NamespaceSignerKind = "namespaceimagesigners"
+
 	ResourceParamKey = "resourceName"
 )
 
 var log = logf.Log.WithName("signer-apis")
 var authClient *authorization.AuthorizationV1Client
 var k8sClient client.Client
+var k8sScheme *runtime.Scheme
+var k8sConfig *rest.Config
+var k8sMapper meta.RESTMapper
+
+// newUserClient returns a client which impersonates the user
+var newUserClient = func(user authenticationv1.UserInfo) (client.Client, error) {
+	cfg := rest.CopyConfig(k8sConfig)
+	extra := map[string][]string{}
+	for k, v := range user.Extra {
+		extra[k] = v
+	}
+	cfg.Impersonate = rest.ImpersonationConfig{UserName: user.Username, Groups: user.Groups, Extra: extra}
+	return client.New(cfg, client.Options{Scheme: k8sScheme, Mapper: k8sMapper})
+}
 
-func Initiate() {
+// Initiate sets clients of the apis. Requests are authenticated by the authenticator
+func Initiate(authn *auth.Authenticator) {
 	// Auth Client
 	authCli, err := utils.AuthClient()
 	if err != nil {
@@ -37,21 +63,48 @@ func Initiate() {
 		os.Exit(1)
 	}
 	authClient = authCli
+	authenticator = authn
+	authorizer = auth.NewAuthorizer(authCli.SubjectAccessReviews(), auth.DefaultAllowedTTL, auth.DefaultDeniedTTL)
 
 	// K8s Client
 	opt := client.Options{Scheme: runtime.NewScheme()}
 	utilruntime.Must(tmaxiov1.AddToScheme(opt.Scheme))
+	utilruntime.Must(corev1.AddToScheme(opt.Scheme))
 
-	cli, err := utils.Client(opt)
+	cfg, err := config.GetConfig()
+	if err != nil {
+		log.Error(err, "")
+		os.Exit(1)
+	}
+	// the mapper is shared by clients impersonating users
+	opt.Mapper, err = apiutil.NewDynamicRESTMapper(cfg)
+	if err != nil {
+		log.Error(err, "")
+		os.Exit(1)
+	}
+	cli, err := client.New(cfg, opt)
 	if err != nil {
 		log.Error(err, "")
 		os.Exit(1)
 	}
 	k8sClient = cli
+	k8sScheme = opt.Scheme
+	k8sConfig = cfg
+	k8sMapper = opt.Mapper
+
+	// Signers are cached for lists and watches, as long as the server runs
+	signerInfos = newSignerCache(getSignerKey)
+	go func() {
+		if err := signerInfos.start(cfg, opt.Scheme, opt.Mapper, make(chan struct{})); err != nil {
+			log.Error(err, "cannot start cache of signers")
+			os.Exit(1)
+		}
+	}()
 }
 
 func AddV1Apis(parent *wrapper.RouterWrapper) error {
-	versionWrapper := wrapper.New(fmt.Sprintf("/%s/%s", ApiGroup, ApiVersion), nil, versionHandler)
+	versionWrapper := wrapper.New(fmt.Sprintf("/%s/%s", ApiGroup, ApiVersion), nil, nil)
+	versionWrapper.Handler = versionHandler(versionWrapper)
 	if err := parent.Add(versionWrapper); err != nil {
 		return err
 	}
@@ -60,21 +113,35 @@ func AddV1Apis(parent *wrapper.RouterWrapper) error {
 		return err
 	}
 
+	if err := AddSignApis(versionWrapper); err != nil {
+		return err
+	}
+
+	if err := AddSignRequestApis(versionWrapper); err != nil {
+		return err
+	}
+
+	if err := AddSignerKeyApis(versionWrapper); err != nil {
+		return err
+	}
+
+	if err := AddAuditLogApis(versionWrapper); err != nil {
+		return err
+	}
+
 	return nil
 }
 
-func versionHandler(w http.ResponseWriter, _ *http.Request) {
-	apiResourceList := &metav1.APIResourceList{}
-	apiResourceList.Kind = "APIResourceList"
-	apiResourceList.GroupVersion = fmt.Sprintf("%s/%s", ApiGroup, ApiVersion)
-	apiResourceList.APIVersion = ApiVersion
-
-	apiResourceList.APIResources = []metav1.APIResource{
-		{
-			Name:       fmt.Sprintf("%s/keys", SignerKind),
-			Namespaced: true,
-		},
-	}
+// versionHandler returns the resources of the apis registered under the version wrapper
+func versionHandler(versionWrapper *wrapper.RouterWrapper) wrapper.HandleFunc {
+	gv := schema.GroupVersion{Group: ApiGroup, Version: ApiVersion}
+	return func(w http.ResponseWriter, _ *http.Request) {
+		apiResourceList := &metav1.APIResourceList{}
+		apiResourceList.Kind = "APIResourceList"
+		apiResourceList.GroupVersion = gv.String()
+		apiResourceList.APIVersion = ApiVersion
+		apiResourceList.APIResources = discovery.APIResources(discovery.Routes(versionWrapper), gv)
 
-	_ = utils.RespondJSON(w, apiResourceList)
+		_ = utils.RespondJSON(w, apiResourceList)
+	}
 }
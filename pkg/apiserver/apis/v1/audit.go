@@ -0,0 +1,116 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tmax-cloud/image-signing-operator/internal/utils"
+	"github.com/tmax-cloud/image-signing-operator/internal/wrapper"
+	"github.com/tmax-cloud/image-signing-operator/pkg/audit"
+)
+
+const (
+	AuditLogKind = "auditlogs"
+
+	AuditLogApiEntries = "entries"
+
+	DefaultAuditLogLimit = 100
+)
+
+// AuditLogResponse is a response of the audit log query.
+// Verified is whether the log is chained without tampering, from its first entry or its checkpoint
+type AuditLogResponse struct {
+	Verified bool          `json:"verified"`
+	Error    string        `json:"error,omitempty"`
+	Entries  []audit.Entry `json:"entries"`
+}
+
+func AddAuditLogApis(parent *wrapper.RouterWrapper) error {
+	auditWrapper := wrapper.New(fmt.Sprintf("/%s", AuditLogKind), nil, nil)
+	if err := parent.Add(auditWrapper); err != nil {
+		return err
+	}
+
+	auditWrapper.Router.Use(Authorize)
+
+	entriesWrapper := wrapper.New(fmt.Sprintf("/%s", AuditLogApiEntries), []string{"GET"}, auditLogEntriesHandler)
+	entriesWrapper.Resource = &wrapper.Resource{Kind: "AuditLogResponse", Response: &AuditLogResponse{}}
+	if err := auditWrapper.Add(entriesWrapper); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// auditLogEntriesHandler returns the latest entries matching the query (type, user, signer, request, since), up to the limit
+func auditLogEntriesHandler(w http.ResponseWriter, req *http.Request) {
+	l := audit.Default()
+	if l == nil {
+		_ = utils.RespondError(w, http.StatusNotFound, "audit log is disabled")
+		return
+	}
+
+	query := req.URL.Query()
+	limit := DefaultAuditLogLimit
+	if s := query.Get("limit"); len(s) > 0 {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			_ = utils.RespondError(w, http.StatusBadRequest, "limit should be a positive integer")
+			return
+		}
+		limit = n
+	}
+	var since time.Time
+	if s := query.Get("since"); len(s) > 0 {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			_ = utils.RespondError(w, http.StatusBadRequest, "since should be in RFC3339")
+			return
+		}
+		since = t
+	}
+
+	entries, anchor := l.Entries()
+	resp := &AuditLogResponse{Verified: true, Entries: []audit.Entry{}}
+	if err := l.Err(); err != nil {
+		resp.Verified = false
+		resp.Error = err.Error()
+	} else if err := l.Verify(entries, anchor); err != nil {
+		resp.Verified = false
+		resp.Error = err.Error()
+	}
+
+	match := func(e *audit.Entry) bool {
+		return (len(query.Get("type")) == 0 || string(e.Type) == query.Get("type")) &&
+			(len(query.Get("user")) == 0 || e.User == query.Get("user")) &&
+			(len(query.Get("signer")) == 0 || e.Signer == query.Get("signer")) &&
+			(len(query.Get("request")) == 0 || e.Request == query.Get("request")) &&
+			!e.Time.Before(since)
+	}
+	for i := len(entries) - 1; i >= 0 && len(resp.Entries) < limit; i-- {
+		if match(&entries[i]) {
+			resp.Entries = append(resp.Entries, entries[i])
+		}
+	}
+
+	_ = utils.RespondJSON(w, resp)
+}
+
+// recordKeyAccess records an audit entry of the key accessed by the user of the request.
+// Keys should not be returned if the access cannot be recorded
+func recordKeyAccess(req *http.Request, eventType audit.EventType, kind, namespace, name, message string) error {
+	l := audit.Default()
+	if l == nil {
+		return nil
+	}
+
+	user, _ := requestUser(req)
+	return l.Record(audit.Entry{
+		Type:    eventType,
+		User:    user.Username,
+		Signer:  audit.SignerName(kind, namespace, name),
+		Message: message,
+	})
+}
@@ -1,33 +1,44 @@
 package v1
 
 import (
-	"context"
 	"fmt"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/trace"
+	authenticationv1 "k8s.io/api/authentication/v1"
 	authorization "k8s.io/api/authorization/v1"
 
 	"github.com/tmax-cloud/image-signing-operator/internal/utils"
+	"github.com/tmax-cloud/image-signing-operator/internal/wrapper"
+	"github.com/tmax-cloud/image-signing-operator/pkg/apiserver/auth"
+	"github.com/tmax-cloud/image-signing-operator/pkg/tracing"
 )
 
-const (
-	UserHeader   = "X-Remote-User"
-	GroupHeader  = "X-Remote-Group"
-	ExtrasHeader = "X-Remote-Extra-"
-)
+var authenticator *auth.Authenticator
+var authorizer *auth.Authorizer
 
+// Authorize authenticates the front proxy and the user of the request, and reviews the user's access to the resource.
+// The user is set to the request context
 func Authorize(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		if err := authorize(req); err != nil {
+		user, err := authenticator.Authenticate(req)
+		if err != nil {
 			_ = utils.RespondError(w, http.StatusUnauthorized, err.Error())
 			return
 		}
-
-		if err := reviewAccess(w, req); err != nil {
-			_ = utils.RespondError(w, http.StatusUnauthorized, err.Error())
+		req = req.WithContext(auth.WithUser(req.Context(), user))
+		trace.SpanFromContext(req.Context()).SetAttributes(tracing.AttributeUser.String(user.Username))
+
+		if err := reviewAccess(req); err != nil {
+			if auth.IsForbidden(err) {
+				_ = utils.RespondError(w, http.StatusForbidden, err.Error())
+			} else {
+				log.Error(err, "cannot review access")
+				_ = utils.RespondError(w, http.StatusInternalServerError, err.Error())
+			}
 			return
 		}
 
@@ -35,96 +46,77 @@ func Authorize(h http.Handler) http.Handler {
 	})
 }
 
-func authorize(req *http.Request) error {
-	if req.TLS == nil || len(req.TLS.PeerCertificates) == 0 {
-		return fmt.Errorf("is not https or there is no peer certificate")
-	}
-	return nil
+// authorized returns a handler which authorizes requests, for handlers which are not under a router using Authorize
+func authorized(h wrapper.HandleFunc) wrapper.HandleFunc {
+	return Authorize(http.HandlerFunc(h)).ServeHTTP
 }
 
-func reviewAccess(w http.ResponseWriter, req *http.Request) error {
-	userName, err := getUserName(req.Header)
-	if err != nil {
-		return err
-	}
-
-	userGroups, err := getUserGroup(req.Header)
-	if err != nil {
-		return err
-	}
-
-	userExtras := getUserExtras(req.Header)
-
-	// URL : /apis/registry.tmax.io/v1/ImageSigner/<resource name>/keys
-	subPaths := strings.Split(req.URL.Path, "/")
-	if len(subPaths) != 7 {
-		return fmt.Errorf("URL should be in form of '/apis/registry.tmax.io/v1/ImageSigner/<resource name>/keys'")
+func reviewAccess(req *http.Request) error {
+	// URL : /apis/registry.tmax.io/v1/[namespaces/<namespace>/]<resource>[/<resource name>][/<subresource>]
+	// Resource name is omitted for the apis on the resource collection (e.g., signerkeys/export)
+	subPaths := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	namespace := ""
+	if len(subPaths) > 4 && subPaths[3] == "namespaces" {
+		namespace = subPaths[4]
+		subPaths = append(subPaths[:3], subPaths[5:]...)
 	}
-	resource := subPaths[4]
-	subResource := subPaths[6]
 
 	vars := mux.Vars(req)
-
 	resourceName, nameExist := vars[ResourceParamKey]
-	if !nameExist {
-		_ = utils.RespondError(w, http.StatusBadRequest, "url is malformed")
-		return fmt.Errorf("")
-	}
 
-	r := &authorization.SubjectAccessReview{
-		Spec: authorization.SubjectAccessReviewSpec{
-			User:   userName,
-			Groups: userGroups,
-			Extra:  userExtras,
-			ResourceAttributes: &authorization.ResourceAttributes{
-				Name:        resourceName,
-				Group:       ApiGroup,
-				Version:     ApiVersion,
-				Resource:    resource,
-				Subresource: subResource,
-				Verb:        "get",
-			},
-		},
+	minLen := 4
+	if nameExist {
+		minLen = 5
 	}
-
-	result, err := authClient.SubjectAccessReviews().Create(context.TODO(), r, metav1.CreateOptions{})
-	if err != nil {
-		return err
+	if len(subPaths) != minLen && len(subPaths) != minLen+1 {
+		return fmt.Errorf("URL should be in form of '/apis/registry.tmax.io/v1/[namespaces/<namespace>/]<resource>[/<resource name>][/<subresource>]'")
 	}
-
-	if result.Status.Allowed {
-		return nil
+	resource := subPaths[3]
+	subResource := ""
+	if len(subPaths) > minLen {
+		subResource = subPaths[minLen]
 	}
 
-	return fmt.Errorf(result.Status.Reason)
-}
-
-func getUserName(header http.Header) (string, error) {
-	for k, v := range header {
-		if k == UserHeader {
-			return v[0], nil
+	verb := "get"
+	if !nameExist && len(subResource) == 0 {
+		verb = "list"
+		if watch, _ := strconv.ParseBool(req.URL.Query().Get("watch")); watch {
+			verb = "watch"
 		}
 	}
-	return "", fmt.Errorf("no header %s", UserHeader)
-}
-
-func getUserGroup(header http.Header) ([]string, error) {
-	for k, v := range header {
-		if k == UserHeader {
-			return v, nil
+	if req.Method == http.MethodPost || req.Method == http.MethodPut {
+		verb = "update"
+		if !nameExist {
+			verb = "create"
 		}
 	}
-	return nil, fmt.Errorf("no header %s", GroupHeader)
-}
 
-func getUserExtras(header http.Header) map[string]authorization.ExtraValue {
-	extras := map[string]authorization.ExtraValue{}
+	return reviewUserAccess(req, &authorization.ResourceAttributes{
+		Namespace:   namespace,
+		Name:        resourceName,
+		Group:       ApiGroup,
+		Version:     ApiVersion,
+		Resource:    resource,
+		Subresource: subResource,
+		Verb:        verb,
+	})
+}
 
-	for k, v := range header {
-		if strings.HasPrefix(k, ExtrasHeader) {
-			extras[strings.TrimPrefix(k, ExtrasHeader)] = v
-		}
+// reviewUserAccess returns ForbiddenError if the user of the request is not allowed to access the resource
+func reviewUserAccess(req *http.Request, attributes *authorization.ResourceAttributes) error {
+	user, err := requestUser(req)
+	if err != nil {
+		return err
 	}
 
-	return extras
+	return authorizer.Authorize(req.Context(), user, attributes)
+}
+
+// requestUser returns the user authenticated by Authorize
+func requestUser(req *http.Request) (authenticationv1.UserInfo, error) {
+	user, ok := auth.UserFrom(req.Context())
+	if !ok {
+		return authenticationv1.UserInfo{}, fmt.Errorf("user is not authenticated")
+	}
+	return user, nil
 }
@@ -0,0 +1,308 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	tmaxiov1 "github.com/tmax-cloud/image-signing-operator/api/v1"
+	"github.com/tmax-cloud/image-signing-operator/internal/utils"
+	"github.com/tmax-cloud/image-signing-operator/internal/wrapper"
+	"github.com/tmax-cloud/image-signing-operator/pkg/audit"
+	"github.com/tmax-cloud/image-signing-operator/pkg/backup"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	SignerKeyKind = "signerkeys"
+
+	SignerKeyApiExport  = "export"
+	SignerKeyApiRestore = "restore"
+
+	EntryKindSignerKey          = "SignerKey"
+	EntryKindNamespaceSignerKey = "NamespaceSignerKey"
+
+	RestoreResultRestored = "Restored"
+	RestoreResultSkipped  = "Skipped"
+	RestoreResultFailed   = "Failed"
+)
+
+// ExportBody is a request body of export api.
+// If both SignerKeys and NamespaceSignerKeys are empty, all keys are exported
+type ExportBody struct {
+	Password string `json:"password"`
+	// SignerKeys is a list of SignerKey names
+	SignerKeys []string `json:"signerKeys,omitempty"`
+	// NamespaceSignerKeys is a list of NamespaceSignerKeys in form of <namespace>/<name>
+	NamespaceSignerKeys []string `json:"namespaceSignerKeys,omitempty"`
+}
+
+// RestoreBody is a request body of restore api
+type RestoreBody struct {
+	Password string          `json:"password"`
+	Archive  *backup.Archive `json:"archive"`
+}
+
+// RestoreResult is a result of restoring a key
+type RestoreResult struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	Result    string `json:"result"`
+	Message   string `json:"message,omitempty"`
+}
+
+func AddSignerKeyApis(parent *wrapper.RouterWrapper) error {
+	signerKeyWrapper := wrapper.New(fmt.Sprintf("/%s", SignerKeyKind), nil, nil)
+	if err := parent.Add(signerKeyWrapper); err != nil {
+		return err
+	}
+
+	signerKeyWrapper.Router.Use(Authorize)
+
+	exportWrapper := wrapper.New(fmt.Sprintf("/%s", SignerKeyApiExport), []string{"POST"}, exportHandler)
+	exportWrapper.Resource = &wrapper.Resource{Kind: "Archive", Body: &ExportBody{}, Response: &backup.Archive{}}
+	if err := signerKeyWrapper.Add(exportWrapper); err != nil {
+		return err
+	}
+
+	restoreWrapper := wrapper.New(fmt.Sprintf("/%s", SignerKeyApiRestore), []string{"POST"}, restoreHandler)
+	restoreWrapper.Resource = &wrapper.Resource{Kind: "RestoreResult", Body: &RestoreBody{}, Response: []RestoreResult{}}
+	if err := signerKeyWrapper.Add(restoreWrapper); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func exportHandler(w http.ResponseWriter, req *http.Request) {
+	body := &ExportBody{}
+	if err := json.NewDecoder(req.Body).Decode(body); err != nil {
+		_ = utils.RespondError(w, http.StatusBadRequest, "body is malformed")
+		return
+	}
+
+	if len(body.Password) < backup.MinPasswordLength {
+		_ = utils.RespondError(w, http.StatusBadRequest, fmt.Sprintf("password should be at least %d characters", backup.MinPasswordLength))
+		return
+	}
+
+	keys, err := selectKeys(body)
+	if err != nil {
+		log.Error(err, "cannot get signer keys")
+		if errors.IsNotFound(err) {
+			_ = utils.RespondError(w, http.StatusNotFound, err.Error())
+		} else {
+			_ = utils.RespondError(w, http.StatusBadRequest, err.Error())
+		}
+		return
+	}
+
+	contents := &backup.Contents{ExportedAt: metav1.Now()}
+	for _, key := range keys {
+		entry, err := newEntry(key)
+		if err != nil {
+			log.Error(err, "cannot get signer of the key")
+			_ = utils.RespondError(w, http.StatusInternalServerError, fmt.Sprintf("cannot get signer of key %s", key.GetName()))
+			return
+		}
+		contents.Keys = append(contents.Keys, *entry)
+	}
+
+	archive, err := backup.Encrypt(contents, body.Password)
+	if err != nil {
+		log.Error(err, "cannot encrypt archive")
+		_ = utils.RespondError(w, http.StatusInternalServerError, "cannot encrypt archive")
+		return
+	}
+
+	for _, entry := range contents.Keys {
+		if err := recordKeyAccess(req, audit.EventKeyExported, entrySignerKind(entry.Kind), entry.Namespace, entry.Name, ""); err != nil {
+			log.Error(err, "cannot record audit entry")
+			_ = utils.RespondError(w, http.StatusInternalServerError, "cannot record key access")
+			return
+		}
+	}
+
+	_ = utils.RespondJSON(w, archive)
+}
+
+func restoreHandler(w http.ResponseWriter, req *http.Request) {
+	body := &RestoreBody{}
+	if err := json.NewDecoder(req.Body).Decode(body); err != nil || body.Archive == nil {
+		_ = utils.RespondError(w, http.StatusBadRequest, "body is malformed")
+		return
+	}
+
+	contents, err := backup.Decrypt(body.Archive, body.Password)
+	if err != nil {
+		_ = utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var results []RestoreResult
+	for _, entry := range contents.Keys {
+		result := RestoreResult{Kind: entry.Kind, Name: entry.Name, Namespace: entry.Namespace, Result: RestoreResultRestored}
+		restored, err := restoreEntry(&entry)
+		if err != nil {
+			log.Error(err, "cannot restore key", "kind", entry.Kind, "name", entry.Name, "namespace", entry.Namespace)
+			result.Result = RestoreResultFailed
+			result.Message = err.Error()
+		} else if !restored {
+			result.Result = RestoreResultSkipped
+			result.Message = "key already exists"
+		} else if err := recordKeyAccess(req, audit.EventKeyRestored, entrySignerKind(entry.Kind), entry.Namespace, entry.Name, ""); err != nil {
+			log.Error(err, "cannot record audit entry")
+		}
+		results = append(results, result)
+	}
+
+	_ = utils.RespondJSON(w, results)
+}
+
+// entrySignerKind returns the kind of the signer of the archive entry
+func entrySignerKind(entryKind string) string {
+	if entryKind == EntryKindNamespaceSignerKey {
+		return tmaxiov1.SignerKindNamespaceImageSigner
+	}
+	return tmaxiov1.SignerKindImageSigner
+}
+
+// selectKeys returns keys selected by the body, or all keys if nothing is selected
+func selectKeys(body *ExportBody) ([]tmaxiov1.Key, error) {
+	var keys []tmaxiov1.Key
+
+	if len(body.SignerKeys) == 0 && len(body.NamespaceSignerKeys) == 0 {
+		keyList := &tmaxiov1.SignerKeyList{}
+		if err := k8sClient.List(context.TODO(), keyList); err != nil {
+			return nil, err
+		}
+		for i := range keyList.Items {
+			keys = append(keys, &keyList.Items[i])
+		}
+
+		nsKeyList := &tmaxiov1.NamespaceSignerKeyList{}
+		if err := k8sClient.List(context.TODO(), nsKeyList); err != nil {
+			return nil, err
+		}
+		for i := range nsKeyList.Items {
+			keys = append(keys, &nsKeyList.Items[i])
+		}
+
+		return keys, nil
+	}
+
+	for _, name := range body.SignerKeys {
+		key := &tmaxiov1.SignerKey{}
+		if err := k8sClient.Get(context.TODO(), types.NamespacedName{Name: name}, key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	for _, nsName := range body.NamespaceSignerKeys {
+		s := strings.SplitN(nsName, "/", 2)
+		if len(s) != 2 {
+			return nil, fmt.Errorf("namespace signer key %s should be in form of <namespace>/<name>", nsName)
+		}
+
+		key := &tmaxiov1.NamespaceSignerKey{}
+		if err := k8sClient.Get(context.TODO(), types.NamespacedName{Name: s[1], Namespace: s[0]}, key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// newEntry makes an archive entry of the key, with the spec of its signer (which has the same name)
+func newEntry(key tmaxiov1.Key) (*backup.Entry, error) {
+	entry := &backup.Entry{
+		Name:        key.GetName(),
+		Namespace:   key.GetNamespace(),
+		Labels:      key.GetLabels(),
+		Annotations: key.GetAnnotations(),
+		Spec:        *key.KeySpec(),
+	}
+
+	kind := tmaxiov1.SignerKindImageSigner
+	entry.Kind = EntryKindSignerKey
+	if len(key.GetNamespace()) > 0 {
+		kind = tmaxiov1.SignerKindNamespaceImageSigner
+		entry.Kind = EntryKindNamespaceSignerKey
+	}
+
+	signer := tmaxiov1.NewSigner(kind)
+	if err := k8sClient.Get(context.TODO(), types.NamespacedName{Name: key.GetName(), Namespace: key.GetNamespace()}, signer); err != nil {
+		if errors.IsNotFound(err) {
+			return entry, nil
+		}
+		return nil, err
+	}
+	spec := signer.SignerSpec().DeepCopy()
+	// Keys are already in the archive, they should not be imported again
+	spec.KeySecret = nil
+	entry.Signer = spec
+
+	return entry, nil
+}
+
+// restoreEntry creates the key and then its signer, so that the signer does not generate a new root key.
+// It returns false if the key already exists
+func restoreEntry(entry *backup.Entry) (bool, error) {
+	kind := tmaxiov1.SignerKindImageSigner
+	if entry.Kind == EntryKindNamespaceSignerKey {
+		kind = tmaxiov1.SignerKindNamespaceImageSigner
+	} else if entry.Kind != EntryKindSignerKey {
+		return false, fmt.Errorf("unknown kind %s", entry.Kind)
+	}
+
+	signer := tmaxiov1.NewSigner(kind)
+	signer.SetName(entry.Name)
+	signer.SetNamespace(entry.Namespace)
+
+	key := signer.NewKey()
+	if err := k8sClient.Get(context.TODO(), tmaxiov1.KeyObjectKey(signer), key); err == nil {
+		return false, nil
+	} else if !errors.IsNotFound(err) {
+		return false, err
+	}
+
+	key.SetName(entry.Name)
+	key.SetNamespace(entry.Namespace)
+	key.SetLabels(entry.Labels)
+	key.SetAnnotations(entry.Annotations)
+	*key.KeySpec() = entry.Spec
+	if err := k8sClient.Create(context.TODO(), key); err != nil {
+		return false, err
+	}
+
+	if err := k8sClient.Get(context.TODO(), types.NamespacedName{Name: entry.Name, Namespace: entry.Namespace}, signer); err != nil {
+		if !errors.IsNotFound(err) || entry.Signer == nil {
+			return true, client.IgnoreNotFound(err)
+		}
+
+		*signer.SignerSpec() = *entry.Signer
+		if err := k8sClient.Create(context.TODO(), signer); err != nil {
+			return true, err
+		}
+	}
+
+	// Signer owns the key, as if it were created by the signer
+	original := key.DeepCopyObject().(tmaxiov1.Key)
+	if err := controllerutil.SetOwnerReference(signer, key, k8sScheme); err != nil {
+		return true, err
+	}
+	if err := k8sClient.Patch(context.TODO(), key, client.MergeFrom(original)); err != nil {
+		return true, err
+	}
+
+	return true, nil
+}
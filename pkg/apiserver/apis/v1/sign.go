@@ -0,0 +1,122 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	tmaxiov1 "github.com/tmax-cloud/image-signing-operator/api/v1"
+	"github.com/tmax-cloud/image-signing-operator/internal/utils"
+	"github.com/tmax-cloud/image-signing-operator/internal/wrapper"
+)
+
+const (
+	SignRequestApiSign = "sign"
+)
+
+// SignBody is a request body of sign api
+type SignBody struct {
+	// Name of the ImageSignRequest. A name is generated if it is empty
+	Name string                        `json:"name,omitempty"`
+	Spec tmaxiov1.ImageSignRequestSpec `json:"spec"`
+}
+
+func AddSignApis(parent *wrapper.RouterWrapper) error {
+	signReqsWrapper := wrapper.New(fmt.Sprintf("/namespaces/{%s}/%s", NamespaceParamKey, SignRequestKind), nil, nil)
+	if err := parent.Add(signReqsWrapper); err != nil {
+		return err
+	}
+
+	signReqsWrapper.Router.Use(Authorize)
+
+	signWrapper := wrapper.New(fmt.Sprintf("/%s", SignRequestApiSign), []string{"POST"}, signHandler)
+	signWrapper.Resource = &wrapper.Resource{Kind: "ImageSignRequest", Body: &SignBody{}, Response: &tmaxiov1.ImageSignRequest{}}
+	if err := signReqsWrapper.Add(signWrapper); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// signHandler creates an ImageSignRequest as the user, so that RBAC of ImageSignRequests and the admission webhook
+// (access control of the signer, and the requester) apply to the user instead of the operator.
+// It returns the created request, whose progress can be watched with its events and status
+func signHandler(w http.ResponseWriter, req *http.Request) {
+	namespace, nsExist := mux.Vars(req)[NamespaceParamKey]
+	if !nsExist {
+		_ = utils.RespondError(w, http.StatusBadRequest, "url is malformed")
+		return
+	}
+
+	body := &SignBody{}
+	if err := json.NewDecoder(req.Body).Decode(body); err != nil {
+		_ = utils.RespondError(w, http.StatusBadRequest, "body is malformed")
+		return
+	}
+	if err := validateSignSpec(namespace, &body.Spec); err != nil {
+		_ = utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user, err := requestUser(req)
+	if err != nil {
+		_ = utils.RespondError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	userClient, err := newUserClient(user)
+	if err != nil {
+		log.Error(err, "cannot create client of the user", "user", user.Username)
+		_ = utils.RespondError(w, http.StatusInternalServerError, "cannot create ImageSignRequest")
+		return
+	}
+
+	signReq := &tmaxiov1.ImageSignRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      body.Name,
+			Namespace: namespace,
+		},
+		Spec: body.Spec,
+	}
+	if len(signReq.Name) == 0 {
+		signReq.GenerateName = "sign-"
+	}
+
+	if err := userClient.Create(context.TODO(), signReq); err != nil {
+		log.Error(err, "cannot create image sign request", "user", user.Username)
+		if errors.IsAlreadyExists(err) {
+			_ = utils.RespondError(w, http.StatusConflict, fmt.Sprintf("ImageSignRequest %s/%s already exists", namespace, signReq.Name))
+		} else if errors.IsForbidden(err) {
+			_ = utils.RespondError(w, http.StatusForbidden, err.Error())
+		} else if errors.IsInvalid(err) {
+			_ = utils.RespondError(w, http.StatusUnprocessableEntity, err.Error())
+		} else {
+			_ = utils.RespondError(w, http.StatusInternalServerError, "cannot create ImageSignRequest")
+		}
+		return
+	}
+	log.Info("image sign request is created", "imagesignrequest", namespace+"/"+signReq.Name, "user", user.Username)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(signReq)
+}
+
+// validateSignSpec checks the spec of the request body. Objects of other namespaces cannot be referred to,
+// and the requester is set by the admission webhook
+func validateSignSpec(namespace string, spec *tmaxiov1.ImageSignRequestSpec) error {
+	if len(spec.Image) == 0 || len(spec.Signer) == 0 {
+		return fmt.Errorf("spec.image and spec.signer are required")
+	}
+	if len(spec.Requester) > 0 || len(spec.RequesterGroups) > 0 {
+		return fmt.Errorf("spec.requester and spec.requesterGroups cannot be set")
+	}
+	if len(spec.RegistryLogin.Namespace) > 0 && spec.RegistryLogin.Namespace != namespace {
+		return fmt.Errorf("spec.registryLogin.namespace should be the namespace of the request (%s)", namespace)
+	}
+	return nil
+}
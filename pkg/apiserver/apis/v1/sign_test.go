@@ -0,0 +1,108 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	tmaxiov1 "github.com/tmax-cloud/image-signing-operator/api/v1"
+	"github.com/tmax-cloud/image-signing-operator/pkg/apiserver/auth"
+)
+
+// forbiddenClient denies creating objects, as RBAC or the admission webhook does
+type forbiddenClient struct {
+	client.Client
+}
+
+func (c *forbiddenClient) Create(_ context.Context, obj runtime.Object, _ ...client.CreateOption) error {
+	return errors.NewForbidden(tmaxiov1.GroupVersion.WithResource("imagesignrequests").GroupResource(), "", nil)
+}
+
+func TestSignHandler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := tmaxiov1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	user := authenticationv1.UserInfo{Username: "ci", Groups: []string{"system:authenticated"}}
+	spec := tmaxiov1.ImageSignRequestSpec{Image: "reg/team/app:1", Signer: "signer"}
+
+	tc := map[string]struct {
+		spec      tmaxiov1.ImageSignRequestSpec
+		forbidden bool
+		expected  int
+	}{
+		"created":   {spec: spec, expected: http.StatusCreated},
+		"forbidden": {spec: spec, forbidden: true, expected: http.StatusForbidden},
+		"noSigner":  {spec: tmaxiov1.ImageSignRequestSpec{Image: "reg/team/app:1"}, expected: http.StatusBadRequest},
+		"requester": {
+			spec:     tmaxiov1.ImageSignRequestSpec{Image: "reg/team/app:1", Signer: "signer", Requester: "admin"},
+			expected: http.StatusBadRequest,
+		},
+		"otherNamespaceLogin": {
+			spec: tmaxiov1.ImageSignRequestSpec{Image: "reg/team/app:1", Signer: "signer",
+				RegistryLogin: tmaxiov1.RegistryLogin{Namespace: "other", Name: "reg"}},
+			expected: http.StatusBadRequest,
+		},
+		"sameNamespaceLogin": {
+			spec: tmaxiov1.ImageSignRequestSpec{Image: "reg/team/app:1", Signer: "signer",
+				RegistryLogin: tmaxiov1.RegistryLogin{Namespace: "team", Name: "reg"}},
+			expected: http.StatusCreated,
+		},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			fakeClient := fake.NewFakeClientWithScheme(scheme)
+			var impersonated *authenticationv1.UserInfo
+			newUserClient = func(u authenticationv1.UserInfo) (client.Client, error) {
+				impersonated = &u
+				if c.forbidden {
+					return &forbiddenClient{Client: fakeClient}, nil
+				}
+				return fakeClient, nil
+			}
+
+			b, err := json.Marshal(&SignBody{Spec: c.spec})
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest(http.MethodPost, "/namespaces/team/imagesignrequests/sign", bytes.NewReader(b))
+			req = mux.SetURLVars(req, map[string]string{NamespaceParamKey: "team"})
+			req = req.WithContext(auth.WithUser(req.Context(), user))
+			w := httptest.NewRecorder()
+			signHandler(w, req)
+
+			if w.Code != c.expected {
+				t.Fatalf("expected %d, got %d: %s", c.expected, w.Code, w.Body.String())
+			}
+			if c.expected != http.StatusCreated {
+				return
+			}
+
+			if impersonated == nil || impersonated.Username != user.Username {
+				t.Fatalf("expected the request to be created as %s, got %v", user.Username, impersonated)
+			}
+			created := &tmaxiov1.ImageSignRequest{}
+			if err := json.Unmarshal(w.Body.Bytes(), created); err != nil {
+				t.Fatal(err)
+			}
+			if created.Namespace != "team" || len(created.Annotations) != 0 {
+				t.Fatalf("unexpected request %v", created.ObjectMeta)
+			}
+			list := &tmaxiov1.ImageSignRequestList{}
+			if err := fakeClient.List(context.TODO(), list, client.InNamespace("team")); err != nil || len(list.Items) != 1 {
+				t.Fatalf("expected the request to be created, got %v, %v", list.Items, err)
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	tmaxiov1 "github.com/tmax-cloud/image-signing-operator/api/v1"
 	"github.com/tmax-cloud/image-signing-operator/internal/utils"
 	"github.com/tmax-cloud/image-signing-operator/internal/wrapper"
+	"github.com/tmax-cloud/image-signing-operator/pkg/audit"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
 	"net/http"
@@ -17,6 +18,13 @@ const (
 )
 
 func AddSignerApis(parent *wrapper.RouterWrapper) error {
+	if err := addSignerInfoApis(parent, SignerKind, tmaxiov1.SignerKindImageSigner, ""); err != nil {
+		return err
+	}
+	if err := addSignerInfoApis(parent, NamespaceSignerKind, tmaxiov1.SignerKindNamespaceImageSigner, fmt.Sprintf("/namespaces/{%s}", NamespaceParamKey)); err != nil {
+		return err
+	}
+
 	signerWrapper := wrapper.New(fmt.Sprintf("/%s/{%s}", SignerKind, ResourceParamKey), nil, nil)
 	if err := parent.Add(signerWrapper); err != nil {
 		return err
@@ -24,14 +32,43 @@ func AddSignerApis(parent *wrapper.RouterWrapper) error {
 
 	signerWrapper.Router.Use(Authorize)
 
-	if err := addSignerKeysApi(signerWrapper); err != nil {
+	if err := addSignerKeysApi(signerWrapper, EntryKindSignerKey, &tmaxiov1.SignerKey{}); err != nil {
+		return err
+	}
+
+	nsSignerWrapper := wrapper.New(fmt.Sprintf("/namespaces/{%s}/%s/{%s}", NamespaceParamKey, NamespaceSignerKind, ResourceParamKey), nil, nil)
+	if err := parent.Add(nsSignerWrapper); err != nil {
+		return err
+	}
+
+	nsSignerWrapper.Router.Use(Authorize)
+
+	if err := addSignerKeysApi(nsSignerWrapper, EntryKindNamespaceSignerKey, &tmaxiov1.NamespaceSignerKey{}); err != nil {
+		return err
+	}
+	return nil
+}
+
+// addSignerInfoApis adds read-only apis listing (or watching) and getting signers with metadata of their keys
+func addSignerInfoApis(parent *wrapper.RouterWrapper, resource, kind, prefix string) error {
+	listWrapper := wrapper.New(fmt.Sprintf("%s/%s", prefix, resource), []string{"GET"}, authorized(signerListHandler))
+	listWrapper.Resource = &wrapper.Resource{Kind: kind, Response: &SignerInfoList{}, Watch: true}
+	if err := parent.Add(listWrapper); err != nil {
+		return err
+	}
+
+	getWrapper := wrapper.New(fmt.Sprintf("%s/%s/{%s}", prefix, resource, ResourceParamKey), []string{"GET"}, authorized(signerGetHandler))
+	getWrapper.Resource = &wrapper.Resource{Kind: kind, Response: &SignerInfo{}}
+	if err := parent.Add(getWrapper); err != nil {
 		return err
 	}
+
 	return nil
 }
 
-func addSignerKeysApi(parent *wrapper.RouterWrapper) error {
+func addSignerKeysApi(parent *wrapper.RouterWrapper, kind string, key tmaxiov1.Key) error {
 	keysWrapper := wrapper.New(fmt.Sprintf("/%s", SignerApiKeys), []string{"GET"}, signerKeysHandler)
+	keysWrapper.Resource = &wrapper.Resource{Kind: kind, Response: key}
 	if err := parent.Add(keysWrapper); err != nil {
 		return err
 	}
@@ -48,16 +85,30 @@ func signerKeysHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	key := &tmaxiov1.SignerKey{}
-	if err := k8sClient.Get(context.TODO(), types.NamespacedName{Name: resourceName}, key); err != nil {
+	// NamespaceSignerKey is used for the signer in a namespace
+	var key tmaxiov1.Key = &tmaxiov1.SignerKey{}
+	kind := tmaxiov1.SignerKindImageSigner
+	namespace, nsExist := vars[NamespaceParamKey]
+	if nsExist {
+		key = &tmaxiov1.NamespaceSignerKey{}
+		kind = tmaxiov1.SignerKindNamespaceImageSigner
+	}
+
+	if err := k8sClient.Get(context.TODO(), types.NamespacedName{Name: resourceName, Namespace: namespace}, key); err != nil {
 		log.Error(err, "cannot get key file")
 		if errors.IsNotFound(err) {
-			_ = utils.RespondError(w, http.StatusNotFound, fmt.Sprintf("there is no SignerKey %s", resourceName))
+			_ = utils.RespondError(w, http.StatusNotFound, fmt.Sprintf("there is no key for signer %s", resourceName))
 		} else {
-			_ = utils.RespondError(w, http.StatusInternalServerError, "cannot get SignerKey")
+			_ = utils.RespondError(w, http.StatusInternalServerError, "cannot get signer key")
 		}
 		return
 	}
 
+	if err := recordKeyAccess(req, audit.EventKeyRead, kind, namespace, resourceName, ""); err != nil {
+		log.Error(err, "cannot record audit entry")
+		_ = utils.RespondError(w, http.StatusInternalServerError, "cannot record key access")
+		return
+	}
+
 	_ = utils.RespondJSON(w, key)
 }
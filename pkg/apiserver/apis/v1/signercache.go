@@ -0,0 +1,343 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/metadata/metadatainformer"
+	"k8s.io/client-go/rest"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+
+	tmaxiov1 "github.com/tmax-cloud/image-signing-operator/api/v1"
+)
+
+const (
+	// signerHistorySize is the number of recent changes of signers, which watches can continue from
+	signerHistorySize = 256
+
+	signerCacheSyncTimeout = 30 * time.Second
+)
+
+// signerInfos is the cache of signers, which lists and watches of signers are served from
+var signerInfos *signerCache
+
+// signerRef identifies a signer, as an ImageSigner and a NamespaceImageSigner can have the same name
+type signerRef struct {
+	kind string
+	types.NamespacedName
+}
+
+func signerRefOf(kind string, obj metav1.Object) signerRef {
+	return signerRef{kind: kind, NamespacedName: types.NamespacedName{Name: obj.GetName(), Namespace: obj.GetNamespace()}}
+}
+
+// signerEvent is a change of a signer, numbered by the revision of the cache
+type signerEvent struct {
+	revision  uint64
+	eventType watch.EventType
+	info      SignerInfo
+}
+
+// signerCache keeps signers with metadata of their keys, which are updated by informers.
+// Keys are watched by their metadata and read only when they are changed, so private keys are not cached.
+// Each change increases the revision of the cache, which is the resource version of lists and watches of signers
+type signerCache struct {
+	// getKey reads the key of the signer. It returns nil if the key does not exist
+	getKey func(signer tmaxiov1.Signer) (tmaxiov1.Key, error)
+
+	// updateMu serializes updates, so that a key read by an older event does not overwrite a newer one
+	updateMu sync.Mutex
+
+	mu      sync.RWMutex
+	signers map[signerRef]tmaxiov1.Signer
+	infos   map[signerRef]SignerInfo
+	// epoch distinguishes revisions of other processes, e.g., before a restart
+	epoch    string
+	revision uint64
+	history  []signerEvent
+	// changed is closed and replaced when a change is recorded
+	changed chan struct{}
+	synced  chan struct{}
+}
+
+func newSignerCache(getKey func(signer tmaxiov1.Signer) (tmaxiov1.Key, error)) *signerCache {
+	return &signerCache{
+		getKey:  getKey,
+		signers: map[signerRef]tmaxiov1.Signer{},
+		infos:   map[signerRef]SignerInfo{},
+		epoch:   strconv.FormatInt(time.Now().UnixNano(), 36),
+		changed: make(chan struct{}),
+		synced:  make(chan struct{}),
+	}
+}
+
+// getSignerKey reads the key of the signer from the api server
+func getSignerKey(signer tmaxiov1.Signer) (tmaxiov1.Key, error) {
+	key := signer.NewKey()
+	if err := k8sClient.Get(context.TODO(), tmaxiov1.KeyObjectKey(signer), key); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return key, nil
+}
+
+// start starts informers of signers and metadata of their keys, and waits for them to be synced
+func (c *signerCache) start(cfg *rest.Config, scheme *runtime.Scheme, mapper meta.RESTMapper, stop <-chan struct{}) error {
+	informers, err := cache.New(cfg, cache.Options{Scheme: scheme, Mapper: mapper})
+	if err != nil {
+		return err
+	}
+	for _, kind := range []string{tmaxiov1.SignerKindImageSigner, tmaxiov1.SignerKindNamespaceImageSigner} {
+		informer, err := informers.GetInformer(context.TODO(), tmaxiov1.NewSigner(kind))
+		if err != nil {
+			return err
+		}
+		informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+			AddFunc:    c.setSigner,
+			UpdateFunc: func(_, obj interface{}) { c.setSigner(obj) },
+			DeleteFunc: c.deleteSigner,
+		})
+	}
+
+	metadataClient, err := metadata.NewForConfig(cfg)
+	if err != nil {
+		return err
+	}
+	keyInformers := metadatainformer.NewSharedInformerFactory(metadataClient, 0)
+	keyResources := map[string]string{
+		tmaxiov1.SignerKindImageSigner:          "signerkeys",
+		tmaxiov1.SignerKindNamespaceImageSigner: "namespacesignerkeys",
+	}
+	for kind, resource := range keyResources {
+		changed := c.keyChanged(kind)
+		keyInformers.ForResource(tmaxiov1.GroupVersion.WithResource(resource)).Informer().AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+			AddFunc:    changed,
+			UpdateFunc: func(_, obj interface{}) { changed(obj) },
+			DeleteFunc: changed,
+		})
+	}
+
+	go func() {
+		if err := informers.Start(stop); err != nil {
+			log.Error(err, "cannot start informers of signers")
+		}
+	}()
+	keyInformers.Start(stop)
+
+	if !informers.WaitForCacheSync(stop) {
+		return fmt.Errorf("cannot sync informers of signers")
+	}
+	for resource, synced := range keyInformers.WaitForCacheSync(stop) {
+		if !synced {
+			return fmt.Errorf("cannot sync informer of %s", resource.Resource)
+		}
+	}
+	close(c.synced)
+	return nil
+}
+
+// waitSynced waits until the cache is synced, for signerCacheSyncTimeout at most
+func (c *signerCache) waitSynced(ctx context.Context) bool {
+	ctx, cancel := context.WithTimeout(ctx, signerCacheSyncTimeout)
+	defer cancel()
+
+	select {
+	case <-c.synced:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (c *signerCache) setSigner(obj interface{}) {
+	signer, ok := obj.(tmaxiov1.Signer)
+	if !ok {
+		return
+	}
+
+	c.updateMu.Lock()
+	defer c.updateMu.Unlock()
+	c.refresh(signer)
+}
+
+func (c *signerCache) deleteSigner(obj interface{}) {
+	if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	signer, ok := obj.(tmaxiov1.Signer)
+	if !ok {
+		return
+	}
+
+	c.updateMu.Lock()
+	defer c.updateMu.Unlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ref := signerRefOf(signer.SignerKind(), signer)
+	info, exist := c.infos[ref]
+	if !exist {
+		return
+	}
+	delete(c.signers, ref)
+	delete(c.infos, ref)
+	c.record(watch.Deleted, info)
+}
+
+// keyChanged returns the handler of changes of keys of the signer kind, which refreshes the signer of the key
+func (c *signerCache) keyChanged(kind string) func(obj interface{}) {
+	return func(obj interface{}) {
+		if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		key, ok := obj.(metav1.Object)
+		if !ok {
+			return
+		}
+
+		c.updateMu.Lock()
+		defer c.updateMu.Unlock()
+
+		// signer key has the same name as its signer
+		c.mu.RLock()
+		signer, exist := c.signers[signerRefOf(kind, key)]
+		c.mu.RUnlock()
+		if exist {
+			c.refresh(signer)
+		}
+	}
+}
+
+// refresh reads the key of the signer, and records a change if the signer or its key is changed.
+// If the key cannot be read, the previous metadata of the key is kept
+func (c *signerCache) refresh(signer tmaxiov1.Signer) {
+	key, err := c.getKey(signer)
+	if err != nil {
+		log.Error(err, "cannot get signer key", "signer", signer.GetName(), "namespace", signer.GetNamespace())
+	}
+	info := newSignerInfo(signer, key)
+	if key != nil {
+		info.keyResourceVersion = key.GetResourceVersion()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ref := signerRefOf(signer.SignerKind(), signer)
+	prev, exist := c.infos[ref]
+	if err != nil && exist {
+		info.Keys = prev.Keys
+		info.keyResourceVersion = prev.keyResourceVersion
+	}
+	c.signers[ref] = signer
+
+	if exist && prev.ResourceVersion == info.ResourceVersion && prev.keyResourceVersion == info.keyResourceVersion {
+		return
+	}
+	c.infos[ref] = info
+	if exist {
+		c.record(watch.Modified, info)
+	} else {
+		c.record(watch.Added, info)
+	}
+}
+
+// record adds the change to the history and notifies watches. c.mu should be locked
+func (c *signerCache) record(eventType watch.EventType, info SignerInfo) {
+	c.revision++
+	c.history = append(c.history, signerEvent{revision: c.revision, eventType: eventType, info: info})
+	if len(c.history) > signerHistorySize {
+		c.history = c.history[len(c.history)-signerHistorySize:]
+	}
+
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// signerMatcher matches ImageSigners, or NamespaceImageSigners in the namespace if namespaced
+func signerMatcher(namespace string, namespaced bool) func(info *SignerInfo) bool {
+	if namespaced {
+		return func(info *SignerInfo) bool {
+			return info.Kind == tmaxiov1.SignerKindNamespaceImageSigner && info.Namespace == namespace
+		}
+	}
+	return func(info *SignerInfo) bool {
+		return info.Kind == tmaxiov1.SignerKindImageSigner
+	}
+}
+
+// list returns the signers which match, sorted by their namespaces and names, and the revision of the list
+func (c *signerCache) list(match func(info *SignerInfo) bool) ([]SignerInfo, uint64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	items := []SignerInfo{}
+	for _, info := range c.infos {
+		if match(&info) {
+			items = append(items, info)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Namespace != items[j].Namespace {
+			return items[i].Namespace < items[j].Namespace
+		}
+		return items[i].Name < items[j].Name
+	})
+	return items, c.revision
+}
+
+// since returns the changes after the revision which match, the revision of the last change,
+// and a channel which is closed at the next change. It returns false if the changes are not kept anymore
+func (c *signerCache) since(revision uint64, match func(info *SignerInfo) bool) ([]signerEvent, uint64, <-chan struct{}, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if revision == c.revision {
+		return nil, c.revision, c.changed, true
+	}
+	if revision > c.revision || len(c.history) == 0 || c.history[0].revision > revision+1 {
+		return nil, c.revision, c.changed, false
+	}
+
+	var events []signerEvent
+	for _, e := range c.history {
+		if e.revision > revision && match(&e.info) {
+			events = append(events, e)
+		}
+	}
+	return events, c.revision, c.changed, true
+}
+
+// resourceVersion returns the resource version of the revision, e.g., kd8x1c2a.15
+func (c *signerCache) resourceVersion(revision uint64) string {
+	return c.epoch + "." + strconv.FormatUint(revision, 10)
+}
+
+// parseResourceVersion returns the revision of the resource version.
+// It returns false if the resource version is not of the cache, e.g., of an object or of another process
+func (c *signerCache) parseResourceVersion(resourceVersion string) (uint64, bool) {
+	s := strings.SplitN(resourceVersion, ".", 2)
+	if len(s) != 2 || s[0] != c.epoch {
+		return 0, false
+	}
+	revision, err := strconv.ParseUint(s[1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return revision, true
+}
@@ -0,0 +1,225 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/gorilla/mux"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	tmaxiov1 "github.com/tmax-cloud/image-signing-operator/api/v1"
+	"github.com/tmax-cloud/image-signing-operator/internal/utils"
+	"github.com/tmax-cloud/image-signing-operator/pkg/hsm"
+	"github.com/tmax-cloud/image-signing-operator/pkg/trust"
+)
+
+// SignerInfo is a signer with metadata of its keys. Private keys and passphrases are not included
+type SignerInfo struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   tmaxiov1.ImageSignerSpec   `json:"spec,omitempty"`
+	Status tmaxiov1.ImageSignerStatus `json:"status,omitempty"`
+	// Keys is not set if the signer key is not created yet
+	Keys *SignerKeyInfo `json:"keys,omitempty"`
+
+	// keyResourceVersion is the resource version of the key, to watch changes of keys as well as the signer
+	keyResourceVersion string
+}
+
+// SignerInfoList is a list of SignerInfo
+type SignerInfoList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []SignerInfo `json:"items"`
+}
+
+// SignerKeyInfo has public information of the keys of SignerKeySpec
+type SignerKeyInfo struct {
+	Root        KeyInfo            `json:"root"`
+	Targets     map[string]KeyInfo `json:"targets,omitempty"`
+	Delegations map[string]KeyInfo `json:"delegations,omitempty"`
+	Snapshots   map[string]KeyInfo `json:"snapshots,omitempty"`
+}
+
+// KeyInfo is TrustKey without its private key and passphrase
+type KeyInfo struct {
+	ID        string       `json:"id"`
+	PublicKey string       `json:"publicKey,omitempty"`
+	CreatedAt *metav1.Time `json:"createdAt,omitempty"`
+	// HardwareKey is true if the private key is in a hardware token
+	HardwareKey bool `json:"hardwareKey,omitempty"`
+}
+
+func newKeyInfo(key *tmaxiov1.TrustKey, role trust.RoleType) KeyInfo {
+	return KeyInfo{
+		ID:          key.ID,
+		PublicKey:   publicKey(key, role),
+		CreatedAt:   key.CreatedAt,
+		HardwareKey: key.IsHardwareKey(),
+	}
+}
+
+func newKeyInfoMap(keys map[string]tmaxiov1.TrustKey, role func(name string) trust.RoleType) map[string]KeyInfo {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	infos := map[string]KeyInfo{}
+	for name, key := range keys {
+		infos[name] = newKeyInfo(&key, role(name))
+	}
+	return infos
+}
+
+// publicKeys caches public keys derived from private keys by key ID, as decrypting private keys is slow
+var publicKeys sync.Map
+
+// publicKey returns the public key of the trust key. It is derived from the private key if it is not stored,
+// e.g., root and target keys which are generated by docker
+func publicKey(key *tmaxiov1.TrustKey, role trust.RoleType) string {
+	if len(key.PublicKey) > 0 || len(key.Key) == 0 {
+		return key.PublicKey
+	}
+	if pub, ok := publicKeys.Load(key.ID); ok {
+		return pub.(string)
+	}
+
+	priv, err := trust.ParsePrivateKey(key.ID, []byte(key.Key), key.PassPhrase, role)
+	if err != nil {
+		log.Error(err, "cannot parse private key", "id", key.ID)
+		return ""
+	}
+	pub, err := hsm.PublicKeyPEM(priv.Public(), string(role))
+	if err != nil {
+		log.Error(err, "cannot encode public key", "id", key.ID)
+		return ""
+	}
+	publicKeys.Store(key.ID, pub)
+	return pub
+}
+
+func roleOf(role trust.RoleType) func(string) trust.RoleType {
+	return func(string) trust.RoleType { return role }
+}
+
+// newSignerInfo returns the signer with metadata of the key. Key can be nil
+func newSignerInfo(signer tmaxiov1.Signer, key tmaxiov1.Key) SignerInfo {
+	info := SignerInfo{
+		TypeMeta: metav1.TypeMeta{APIVersion: fmt.Sprintf("%s/%s", ApiGroup, ApiVersion)},
+		Spec:     *signer.SignerSpec().DeepCopy(),
+		Status:   *signer.SignerStatus().DeepCopy(),
+	}
+	switch s := signer.(type) {
+	case *tmaxiov1.ImageSigner:
+		info.Kind = "ImageSigner"
+		s.ObjectMeta.DeepCopyInto(&info.ObjectMeta)
+	case *tmaxiov1.NamespaceImageSigner:
+		info.Kind = "NamespaceImageSigner"
+		s.ObjectMeta.DeepCopyInto(&info.ObjectMeta)
+	}
+	info.ManagedFields = nil
+
+	if key != nil {
+		spec := key.KeySpec()
+		info.Keys = &SignerKeyInfo{
+			Root:    newKeyInfo(&spec.Root, trust.TrustRoleRoot),
+			Targets: newKeyInfoMap(spec.Targets, roleOf(trust.TrustRoleTarget)),
+			// the role of a delegation key is its name
+			Delegations: newKeyInfoMap(spec.Delegations, func(name string) trust.RoleType { return trust.RoleType(name) }),
+			Snapshots:   newKeyInfoMap(spec.Snapshots, roleOf(trust.TrustRoleSnapshot)),
+		}
+	}
+
+	return info
+}
+
+// signerListHandler lists ImageSigners, or NamespaceImageSigners in the namespace, with metadata of their keys.
+// If watch=true, changes of the signers are streamed instead
+func signerListHandler(w http.ResponseWriter, req *http.Request) {
+	namespace, nsExist := mux.Vars(req)[NamespaceParamKey]
+
+	if s := req.URL.Query().Get("watch"); len(s) > 0 {
+		watch, err := strconv.ParseBool(s)
+		if err != nil {
+			_ = utils.RespondError(w, http.StatusBadRequest, "watch should be true or false")
+			return
+		}
+		if watch {
+			watchSignerInfos(w, req, namespace, nsExist)
+			return
+		}
+	}
+
+	list, err := listSignerInfos(req.Context(), namespace, nsExist)
+	if err != nil {
+		log.Error(err, "cannot list signers")
+		_ = utils.RespondError(w, http.StatusServiceUnavailable, "cannot list signers")
+		return
+	}
+
+	_ = utils.RespondJSON(w, list)
+}
+
+// listSignerInfos lists ImageSigners, or NamespaceImageSigners in the namespace if namespaced, from the cache.
+// The resource version of the list is the revision of the cache, which a watch can start from
+func listSignerInfos(ctx context.Context, namespace string, namespaced bool) (*SignerInfoList, error) {
+	if !signerInfos.waitSynced(ctx) {
+		return nil, fmt.Errorf("signers are not synced")
+	}
+
+	list := &SignerInfoList{
+		TypeMeta: metav1.TypeMeta{Kind: "ImageSignerList", APIVersion: fmt.Sprintf("%s/%s", ApiGroup, ApiVersion)},
+	}
+	if namespaced {
+		list.Kind = "NamespaceImageSignerList"
+	}
+	items, revision := signerInfos.list(signerMatcher(namespace, namespaced))
+	list.Items = items
+	list.ResourceVersion = signerInfos.resourceVersion(revision)
+
+	return list, nil
+}
+
+// signerGetHandler returns the ImageSigner or NamespaceImageSigner with metadata of its key
+func signerGetHandler(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+
+	resourceName, nameExist := vars[ResourceParamKey]
+	if !nameExist {
+		_ = utils.RespondError(w, http.StatusBadRequest, "url is malformed")
+		return
+	}
+
+	kind := tmaxiov1.SignerKindImageSigner
+	namespace, nsExist := vars[NamespaceParamKey]
+	if nsExist {
+		kind = tmaxiov1.SignerKindNamespaceImageSigner
+	}
+
+	signer := tmaxiov1.NewSigner(kind)
+	if err := k8sClient.Get(context.TODO(), types.NamespacedName{Name: resourceName, Namespace: namespace}, signer); err != nil {
+		log.Error(err, "cannot get signer")
+		if errors.IsNotFound(err) {
+			_ = utils.RespondError(w, http.StatusNotFound, fmt.Sprintf("there is no signer %s", resourceName))
+		} else {
+			_ = utils.RespondError(w, http.StatusInternalServerError, "cannot get signer")
+		}
+		return
+	}
+
+	key, err := getSignerKey(signer)
+	if err != nil {
+		log.Error(err, "cannot get signer key")
+		_ = utils.RespondError(w, http.StatusInternalServerError, "cannot get signer key")
+		return
+	}
+
+	_ = utils.RespondJSON(w, newSignerInfo(signer, key))
+}
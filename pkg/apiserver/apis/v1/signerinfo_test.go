@@ -0,0 +1,111 @@
+package v1
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	tmaxiov1 "github.com/tmax-cloud/image-signing-operator/api/v1"
+	"github.com/tmax-cloud/image-signing-operator/pkg/hsm"
+	"github.com/tmax-cloud/image-signing-operator/pkg/trust"
+)
+
+func newTestTrustKey(t *testing.T, role trust.RoleType, passphrase string) (tmaxiov1.TrustKey, crypto.Signer) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := trust.KeyID(priv.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents, err := trust.EncryptPrivateKey(priv, passphrase, role, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tmaxiov1.TrustKey{ID: id, Key: string(contents), PassPhrase: passphrase}, priv
+}
+
+func TestSignerGetHandler(t *testing.T) {
+	root, rootPriv := newTestTrustKey(t, trust.TrustRoleRoot, "root-passphrase")
+	target, targetPriv := newTestTrustKey(t, trust.TrustRoleTarget, "target-passphrase")
+	snapshot, _ := newTestTrustKey(t, trust.TrustRoleSnapshot, "target-passphrase")
+	delegation, delegationPriv := newTestTrustKey(t, trust.RoleType("team"), "target-passphrase")
+	if pub, err := hsm.PublicKeyPEM(delegationPriv.Public(), "team"); err != nil {
+		t.Fatal(err)
+	} else {
+		delegation.PublicKey = pub
+	}
+
+	scheme := runtime.NewScheme()
+	if err := tmaxiov1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	k8sClient = fake.NewFakeClientWithScheme(scheme,
+		&tmaxiov1.ImageSigner{ObjectMeta: metav1.ObjectMeta{Name: "signer"}},
+		&tmaxiov1.SignerKey{
+			ObjectMeta: metav1.ObjectMeta{Name: "signer"},
+			Spec: tmaxiov1.SignerKeySpec{
+				Root:        root,
+				Targets:     map[string]tmaxiov1.TrustKey{"reg/team/app": target},
+				Snapshots:   map[string]tmaxiov1.TrustKey{"reg/team/app": snapshot},
+				Delegations: map[string]tmaxiov1.TrustKey{"team": delegation},
+			},
+		},
+	)
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/imagesigners/signer", nil), map[string]string{ResourceParamKey: "signer"})
+	w := httptest.NewRecorder()
+	signerGetHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	body := w.Body.String()
+	for _, secret := range []string{"PRIVATE KEY", root.PassPhrase, target.PassPhrase, "passPhrase"} {
+		if strings.Contains(body, secret) {
+			t.Errorf("response should not contain %q", secret)
+		}
+	}
+
+	info := &SignerInfo{}
+	if err := json.Unmarshal(w.Body.Bytes(), info); err != nil {
+		t.Fatal(err)
+	}
+	if info.Keys == nil {
+		t.Fatal("expected keys")
+	}
+
+	expected := map[string]struct {
+		key  KeyInfo
+		priv crypto.Signer
+		role string
+	}{
+		"root":       {key: info.Keys.Root, priv: rootPriv, role: string(trust.TrustRoleRoot)},
+		"target":     {key: info.Keys.Targets["reg/team/app"], priv: targetPriv, role: string(trust.TrustRoleTarget)},
+		"delegation": {key: info.Keys.Delegations["team"], priv: delegationPriv, role: "team"},
+	}
+	for name, c := range expected {
+		pub, err := hsm.PublicKeyPEM(c.priv.Public(), c.role)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.key.PublicKey != pub {
+			t.Errorf("expected public key of the %s key, got %q", name, c.key.PublicKey)
+		}
+	}
+	if len(info.Keys.Snapshots["reg/team/app"].PublicKey) == 0 {
+		t.Error("expected public key of the snapshot key")
+	}
+}
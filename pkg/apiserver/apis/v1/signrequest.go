@@ -0,0 +1,167 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	tmaxiov1 "github.com/tmax-cloud/image-signing-operator/api/v1"
+	"github.com/tmax-cloud/image-signing-operator/internal/utils"
+	"github.com/tmax-cloud/image-signing-operator/internal/wrapper"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	SignRequestKind = "imagesignrequests"
+
+	SignRequestApiApprove = "approve"
+	SignRequestApiReject  = "reject"
+
+	NamespaceParamKey = "namespace"
+)
+
+// ApprovalBody is a request body of approve/reject api
+type ApprovalBody struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+func AddSignRequestApis(parent *wrapper.RouterWrapper) error {
+	signReqWrapper := wrapper.New(fmt.Sprintf("/namespaces/{%s}/%s/{%s}", NamespaceParamKey, SignRequestKind, ResourceParamKey), nil, nil)
+	if err := parent.Add(signReqWrapper); err != nil {
+		return err
+	}
+
+	signReqWrapper.Router.Use(Authorize)
+
+	approveWrapper := wrapper.New(fmt.Sprintf("/%s", SignRequestApiApprove), []string{"POST"}, approveHandler)
+	approveWrapper.Resource = &wrapper.Resource{Kind: "ImageSignRequest", Body: &ApprovalBody{}, Response: &tmaxiov1.ImageSignRequest{}}
+	if err := signReqWrapper.Add(approveWrapper); err != nil {
+		return err
+	}
+
+	rejectWrapper := wrapper.New(fmt.Sprintf("/%s", SignRequestApiReject), []string{"POST"}, rejectHandler)
+	rejectWrapper.Resource = &wrapper.Resource{Kind: "ImageSignRequest", Body: &ApprovalBody{}, Response: &tmaxiov1.ImageSignRequest{}}
+	if err := signReqWrapper.Add(rejectWrapper); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func approveHandler(w http.ResponseWriter, req *http.Request) {
+	handleApproval(w, req, true)
+}
+
+func rejectHandler(w http.ResponseWriter, req *http.Request) {
+	handleApproval(w, req, false)
+}
+
+func handleApproval(w http.ResponseWriter, req *http.Request, approved bool) {
+	vars := mux.Vars(req)
+
+	namespace, nsExist := vars[NamespaceParamKey]
+	resourceName, nameExist := vars[ResourceParamKey]
+	if !nsExist || !nameExist {
+		_ = utils.RespondError(w, http.StatusBadRequest, "url is malformed")
+		return
+	}
+
+	user, err := requestUser(req)
+	if err != nil {
+		_ = utils.RespondError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	userName, userGroups := user.Username, user.Groups
+
+	body := &ApprovalBody{}
+	if req.ContentLength > 0 {
+		if err := json.NewDecoder(req.Body).Decode(body); err != nil {
+			_ = utils.RespondError(w, http.StatusBadRequest, "body is malformed")
+			return
+		}
+	}
+
+	signReq := &tmaxiov1.ImageSignRequest{}
+	if err := k8sClient.Get(context.TODO(), types.NamespacedName{Name: resourceName, Namespace: namespace}, signReq); err != nil {
+		log.Error(err, "cannot get image sign request")
+		if errors.IsNotFound(err) {
+			_ = utils.RespondError(w, http.StatusNotFound, fmt.Sprintf("there is no ImageSignRequest %s/%s", namespace, resourceName))
+		} else {
+			_ = utils.RespondError(w, http.StatusInternalServerError, "cannot get ImageSignRequest")
+		}
+		return
+	}
+
+	signer := tmaxiov1.NewSigner(signReq.Spec.SignerKind)
+	if err := k8sClient.Get(context.TODO(), signReq.SignerObjectKey(), signer); err != nil {
+		log.Error(err, "cannot get image signer")
+		_ = utils.RespondError(w, http.StatusInternalServerError, fmt.Sprintf("cannot get %s %s", signer.SignerKind(), signReq.Spec.Signer))
+		return
+	}
+
+	approval := signer.SignerSpec().Approval
+	if approval == nil {
+		_ = utils.RespondError(w, http.StatusBadRequest, fmt.Sprintf("%s %s does not require approval", signer.SignerKind(), signer.GetName()))
+		return
+	}
+
+	if !isApprover(approval, userGroups) {
+		_ = utils.RespondError(w, http.StatusForbidden, fmt.Sprintf("user %s is not an approver of %s %s", userName, signer.SignerKind(), signer.GetName()))
+		return
+	}
+
+	if userName == signReq.Spec.Requester {
+		_ = utils.RespondError(w, http.StatusForbidden, fmt.Sprintf("user %s cannot review their own request", userName))
+		return
+	}
+
+	if signReq.Status.ImageSignResponse == nil || signReq.Status.Result != tmaxiov1.ResponseResultPendingApproval {
+		_ = utils.RespondError(w, http.StatusConflict, "ImageSignRequest is not pending approval")
+		return
+	}
+
+	for _, a := range signReq.Status.Approvals {
+		if a.User == userName {
+			_ = utils.RespondError(w, http.StatusConflict, fmt.Sprintf("user %s already reviewed this request", userName))
+			return
+		}
+	}
+
+	signReq.Status.Approvals = append(signReq.Status.Approvals, tmaxiov1.Approval{
+		User:     userName,
+		Approved: approved,
+		Reason:   body.Reason,
+		Time:     metav1.Now(),
+	})
+	if err := k8sClient.Status().Update(context.TODO(), signReq); err != nil {
+		log.Error(err, "cannot update image sign request")
+		if errors.IsConflict(err) {
+			_ = utils.RespondError(w, http.StatusConflict, "ImageSignRequest is modified, try again")
+		} else {
+			_ = utils.RespondError(w, http.StatusInternalServerError, "cannot update ImageSignRequest")
+		}
+		return
+	}
+
+	_ = utils.RespondJSON(w, signReq)
+}
+
+func isApprover(policy *tmaxiov1.ApprovalPolicy, userGroups []string) bool {
+	if len(policy.ApproverGroups) == 0 {
+		return true
+	}
+
+	for _, group := range policy.ApproverGroups {
+		for _, g := range userGroups {
+			if g == group {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,106 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+
+	"github.com/tmax-cloud/image-signing-operator/internal/utils"
+)
+
+const (
+	DefaultWatchTimeout = 30 * time.Minute
+)
+
+// watchSignerInfos streams changes of the signers and their keys as watch events (JSON lines), until the timeout
+// (e.g., timeoutSeconds=60) expires or the client is gone. Changes are streamed from the cache of signers.
+// If resourceVersion is empty or "0", existing signers are sent as ADDED events first.
+// Otherwise, changes after the resource version of a list or a watch event are sent. If they are not kept anymore,
+// 410 Gone is returned (or sent as an ERROR event, if the watch falls behind), and the client should list again
+func watchSignerInfos(w http.ResponseWriter, req *http.Request, namespace string, namespaced bool) {
+	timeout := DefaultWatchTimeout
+	if s := req.URL.Query().Get("timeoutSeconds"); len(s) > 0 {
+		sec, err := strconv.Atoi(s)
+		if err != nil || sec <= 0 {
+			_ = utils.RespondError(w, http.StatusBadRequest, "timeoutSeconds should be a positive integer")
+			return
+		}
+		timeout = time.Duration(sec) * time.Second
+	}
+
+	if !signerInfos.waitSynced(req.Context()) {
+		_ = utils.RespondError(w, http.StatusServiceUnavailable, "signers are not synced")
+		return
+	}
+	match := signerMatcher(namespace, namespaced)
+
+	var initial []SignerInfo
+	var revision uint64
+	rv := req.URL.Query().Get("resourceVersion")
+	if len(rv) == 0 || rv == "0" {
+		initial, revision = signerInfos.list(match)
+	} else {
+		ok := false
+		if revision, ok = signerInfos.parseResourceVersion(rv); ok {
+			_, _, _, ok = signerInfos.since(revision, match)
+		}
+		if !ok {
+			_ = utils.RespondError(w, http.StatusGone, "resource version "+rv+" is too old")
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+	send := func(eventType watch.EventType, obj interface{}) {
+		raw, err := json.Marshal(obj)
+		if err != nil {
+			log.Error(err, "cannot marshal watch event")
+			return
+		}
+		_ = encoder.Encode(&metav1.WatchEvent{Type: string(eventType), Object: runtime.RawExtension{Raw: raw}})
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	for _, info := range initial {
+		send(watch.Added, info)
+	}
+
+	for {
+		events, latest, changed, ok := signerInfos.since(revision, match)
+		if !ok {
+			send(watch.Error, &metav1.Status{
+				TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+				Status:   metav1.StatusFailure,
+				Message:  "watch fell behind changes of signers",
+				Reason:   metav1.StatusReasonExpired,
+				Code:     http.StatusGone,
+			})
+			return
+		}
+		for _, e := range events {
+			send(e.eventType, e.info)
+		}
+		revision = latest
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed:
+		}
+	}
+}
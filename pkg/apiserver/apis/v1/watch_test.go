@@ -0,0 +1,244 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+
+	tmaxiov1 "github.com/tmax-cloud/image-signing-operator/api/v1"
+)
+
+// newTestSignerCache returns a synced cache, whose keys are read from the map by the names of signers
+func newTestSignerCache(keys map[string]tmaxiov1.Key, keyErr *error) *signerCache {
+	c := newSignerCache(func(signer tmaxiov1.Signer) (tmaxiov1.Key, error) {
+		if keyErr != nil && *keyErr != nil {
+			return nil, *keyErr
+		}
+		if key, ok := keys[signer.GetName()]; ok {
+			return key, nil
+		}
+		return nil, nil
+	})
+	close(c.synced)
+	return c
+}
+
+func newTestSigner(name, namespace, resourceVersion string) tmaxiov1.Signer {
+	meta := metav1.ObjectMeta{Name: name, Namespace: namespace, ResourceVersion: resourceVersion}
+	if len(namespace) > 0 {
+		return &tmaxiov1.NamespaceImageSigner{ObjectMeta: meta}
+	}
+	return &tmaxiov1.ImageSigner{ObjectMeta: meta}
+}
+
+func newTestSignerKey(name, resourceVersion, id string) *tmaxiov1.SignerKey {
+	return &tmaxiov1.SignerKey{
+		ObjectMeta: metav1.ObjectMeta{Name: name, ResourceVersion: resourceVersion},
+		Spec:       tmaxiov1.SignerKeySpec{Root: tmaxiov1.TrustKey{ID: id}},
+	}
+}
+
+func eventTypes(events []signerEvent) []watch.EventType {
+	var types []watch.EventType
+	for _, e := range events {
+		types = append(types, e.eventType)
+	}
+	return types
+}
+
+func TestSignerCache(t *testing.T) {
+	keys := map[string]tmaxiov1.Key{}
+	var keyErr error
+	c := newTestSignerCache(keys, &keyErr)
+	all := signerMatcher("", false)
+
+	c.setSigner(newTestSigner("a", "", "1"))
+	c.setSigner(newTestSigner("a", "team", "1"))
+	// resync without changes
+	c.setSigner(newTestSigner("a", "", "1"))
+
+	events, revision, _, ok := c.since(0, all)
+	if !ok || revision != 2 || fmt.Sprint(eventTypes(events)) != "[ADDED]" {
+		t.Fatalf("expected ADDED of the cluster signer at revision 2, got %v at %d", eventTypes(events), revision)
+	}
+	if items, _ := c.list(signerMatcher("team", true)); len(items) != 1 || items[0].Namespace != "team" {
+		t.Fatalf("expected the signer of the namespace, got %v", items)
+	}
+	if items, _ := c.list(signerMatcher("dev", true)); len(items) != 0 {
+		t.Fatalf("expected no signers of another namespace, got %v", items)
+	}
+
+	// the key is created
+	keys["a"] = newTestSignerKey("a", "5", "root-1")
+	c.keyChanged(tmaxiov1.SignerKindImageSigner)(&metav1.PartialObjectMetadata{ObjectMeta: keys["a"].(*tmaxiov1.SignerKey).ObjectMeta})
+	// the key of an unknown signer is ignored
+	c.keyChanged(tmaxiov1.SignerKindImageSigner)(&metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Name: "b"}})
+
+	events, revision, _, _ = c.since(2, all)
+	if revision != 3 || len(events) != 1 || events[0].eventType != watch.Modified || events[0].info.Keys.Root.ID != "root-1" {
+		t.Fatalf("expected MODIFIED with the key at revision 3, got %v at %d", events, revision)
+	}
+
+	// keys which cannot be read are kept
+	keyErr = fmt.Errorf("unavailable")
+	c.setSigner(newTestSigner("a", "", "2"))
+	items, _ := c.list(all)
+	if len(items) != 1 || items[0].Keys == nil || items[0].Keys.Root.ID != "root-1" {
+		t.Fatalf("expected the previous key, got %v", items)
+	}
+	keyErr = nil
+
+	c.deleteSigner(newTestSigner("a", "", "2"))
+	events, revision, _, _ = c.since(3, all)
+	if revision != 5 || fmt.Sprint(eventTypes(events)) != "[MODIFIED DELETED]" {
+		t.Fatalf("expected MODIFIED and DELETED at revision 5, got %v at %d", eventTypes(events), revision)
+	}
+	if items, _ := c.list(all); len(items) != 0 {
+		t.Fatalf("expected no signers, got %v", items)
+	}
+
+	// changes which are not kept
+	for i := 0; i < signerHistorySize; i++ {
+		c.setSigner(newTestSigner("a", "", fmt.Sprint(10+i)))
+	}
+	if _, _, _, ok := c.since(3, all); ok {
+		t.Fatal("expected changes after revision 3 not to be kept")
+	}
+	if _, _, _, ok := c.since(c.revision+1, all); ok {
+		t.Fatal("expected a future revision not to be kept")
+	}
+}
+
+func TestSignerCacheResourceVersion(t *testing.T) {
+	c := newTestSignerCache(nil, nil)
+
+	if revision, ok := c.parseResourceVersion(c.resourceVersion(15)); !ok || revision != 15 {
+		t.Fatalf("expected revision 15, got %d, %t", revision, ok)
+	}
+	for _, rv := range []string{"15", "other.15", c.epoch + ".x"} {
+		if _, ok := c.parseResourceVersion(rv); ok {
+			t.Errorf("expected %q not to be a resource version of the cache", rv)
+		}
+	}
+}
+
+// watchTestServer serves watches of cluster signers
+func watchTestServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		signerListHandler(w, mux.SetURLVars(req, map[string]string{}))
+	}))
+}
+
+func readEvent(t *testing.T, decoder *json.Decoder) (watch.EventType, *SignerInfo) {
+	e := &metav1.WatchEvent{}
+	if err := decoder.Decode(e); err != nil {
+		t.Fatal(err)
+	}
+	info := &SignerInfo{}
+	if err := json.Unmarshal(e.Object.Raw, info); err != nil {
+		t.Fatal(err)
+	}
+	return watch.EventType(e.Type), info
+}
+
+func TestWatchSignerInfos(t *testing.T) {
+	signerInfos = newTestSignerCache(nil, nil)
+	signerInfos.setSigner(newTestSigner("a", "", "1"))
+	server := watchTestServer()
+	defer server.Close()
+
+	// list, then watch from the list after a change
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := &SignerInfoList{}
+	if err := json.NewDecoder(resp.Body).Decode(list); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if len(list.Items) != 1 || list.ResourceVersion != signerInfos.resourceVersion(1) {
+		t.Fatalf("expected a signer at revision 1, got %v", list)
+	}
+	signerInfos.setSigner(newTestSigner("b", "", "2"))
+
+	resp, err = http.Get(server.URL + "?watch=true&timeoutSeconds=5&resourceVersion=" + list.ResourceVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	decoder := json.NewDecoder(resp.Body)
+	if eventType, info := readEvent(t, decoder); eventType != watch.Added || info.Name != "b" {
+		t.Fatalf("expected ADDED of b, got %s of %s", eventType, info.Name)
+	}
+
+	signerInfos.setSigner(newTestSigner("a", "", "3"))
+	signerInfos.setSigner(newTestSigner("a", "team", "3"))
+	signerInfos.deleteSigner(newTestSigner("b", "", "2"))
+	if eventType, info := readEvent(t, decoder); eventType != watch.Modified || info.Name != "a" || info.ResourceVersion != "3" {
+		t.Fatalf("expected MODIFIED of a, got %s of %s", eventType, info.Name)
+	}
+	// signers of namespaces are not sent
+	if eventType, info := readEvent(t, decoder); eventType != watch.Deleted || info.Name != "b" {
+		t.Fatalf("expected DELETED of b, got %s of %s", eventType, info.Name)
+	}
+}
+
+func TestWatchSignerInfosInitial(t *testing.T) {
+	signerInfos = newTestSignerCache(nil, nil)
+	signerInfos.setSigner(newTestSigner("b", "", "1"))
+	signerInfos.setSigner(newTestSigner("a", "", "1"))
+	server := watchTestServer()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "?watch=true&timeoutSeconds=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	decoder := json.NewDecoder(resp.Body)
+	for _, name := range []string{"a", "b"} {
+		if eventType, info := readEvent(t, decoder); eventType != watch.Added || info.Name != name {
+			t.Fatalf("expected ADDED of %s, got %s of %s", name, eventType, info.Name)
+		}
+	}
+}
+
+func TestWatchSignerInfosGone(t *testing.T) {
+	signerInfos = newTestSignerCache(nil, nil)
+	for i := 0; i <= signerHistorySize; i++ {
+		signerInfos.setSigner(newTestSigner("a", "", fmt.Sprint(i)))
+	}
+	server := watchTestServer()
+	defer server.Close()
+
+	tc := map[string]struct {
+		resourceVersion string
+		expected        int
+	}{
+		"current":    {resourceVersion: signerInfos.resourceVersion(signerInfos.revision), expected: http.StatusOK},
+		"kept":       {resourceVersion: signerInfos.resourceVersion(1), expected: http.StatusOK},
+		"tooOld":     {resourceVersion: signerInfos.resourceVersion(0), expected: http.StatusGone},
+		"ofObject":   {resourceVersion: "12345", expected: http.StatusGone},
+		"ofOtherPod": {resourceVersion: "other.1", expected: http.StatusGone},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			resp, err := http.Get(server.URL + "?watch=true&timeoutSeconds=1&resourceVersion=" + c.resourceVersion)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != c.expected {
+				t.Fatalf("expected %d, got %d", c.expected, resp.StatusCode)
+			}
+		})
+	}
+}
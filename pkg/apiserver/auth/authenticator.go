@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"sync"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+)
+
+// Authenticator authenticates requests proxied by the front proxy.
+// The proxy is verified by its client certificate, and the user is read from the request headers
+type Authenticator struct {
+	lock   sync.RWMutex
+	config *RequestHeaderConfig
+}
+
+func NewAuthenticator(cfg *RequestHeaderConfig) *Authenticator {
+	return &Authenticator{config: cfg}
+}
+
+// SetConfig replaces the config, e.g., when the client CA is rotated
+func (a *Authenticator) SetConfig(cfg *RequestHeaderConfig) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.config = cfg
+}
+
+func (a *Authenticator) getConfig() *RequestHeaderConfig {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	return a.config
+}
+
+// Authenticate returns the user of the request, if the request is from the front proxy
+func (a *Authenticator) Authenticate(req *http.Request) (authenticationv1.UserInfo, error) {
+	cfg := a.getConfig()
+	if cfg == nil {
+		return authenticationv1.UserInfo{}, fmt.Errorf("authenticator is not configured")
+	}
+
+	if err := verifyProxy(req, cfg); err != nil {
+		return authenticationv1.UserInfo{}, err
+	}
+
+	userName := ""
+	for _, h := range cfg.UsernameHeaders {
+		if v := strings.TrimSpace(req.Header.Get(h)); len(v) > 0 {
+			userName = v
+			break
+		}
+	}
+	if len(userName) == 0 {
+		return authenticationv1.UserInfo{}, fmt.Errorf("no user in headers %v", cfg.UsernameHeaders)
+	}
+
+	var groups []string
+	for _, h := range cfg.GroupHeaders {
+		groups = append(groups, headerValues(req.Header, h)...)
+	}
+
+	return authenticationv1.UserInfo{
+		Username: userName,
+		Groups:   groups,
+		Extra:    extras(req.Header, cfg.ExtraHeaderPrefixes),
+	}, nil
+}
+
+// verifyProxy verifies the client certificate is issued by the client CA for one of the allowed names
+func verifyProxy(req *http.Request, cfg *RequestHeaderConfig) error {
+	if req.TLS == nil || len(req.TLS.PeerCertificates) == 0 {
+		return fmt.Errorf("is not https or there is no peer certificate")
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         cfg.ClientCA,
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	for _, c := range req.TLS.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+
+	peer := req.TLS.PeerCertificates[0]
+	if _, err := peer.Verify(opts); err != nil {
+		return fmt.Errorf("cannot verify client certificate: %v", err)
+	}
+
+	if len(cfg.AllowedNames) == 0 {
+		return nil
+	}
+	for _, name := range cfg.AllowedNames {
+		if peer.Subject.CommonName == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("client certificate of %s is not allowed, allowed names are %v", peer.Subject.CommonName, cfg.AllowedNames)
+}
+
+func headerValues(header http.Header, name string) []string {
+	var values []string
+	for _, v := range header[textproto.CanonicalMIMEHeaderKey(name)] {
+		if v = strings.TrimSpace(v); len(v) > 0 {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// extras returns the extra values of the headers with the prefixes.
+// Keys are lower-cased and unescaped, e.g., X-Remote-Extra-Scopes is scopes
+func extras(header http.Header, prefixes []string) map[string]authenticationv1.ExtraValue {
+	extra := map[string]authenticationv1.ExtraValue{}
+
+	for _, prefix := range prefixes {
+		prefix = strings.ToLower(prefix)
+		for k, v := range header {
+			lower := strings.ToLower(k)
+			if !strings.HasPrefix(lower, prefix) || len(lower) == len(prefix) {
+				continue
+			}
+
+			key := lower[len(prefix):]
+			if unescaped, err := url.PathUnescape(key); err == nil {
+				key = unescaped
+			}
+			extra[key] = append(extra[key], v...)
+		}
+	}
+
+	if len(extra) == 0 {
+		return nil
+	}
+	return extra
+}
+
+type userKey struct{}
+
+// WithUser returns a context with the authenticated user
+func WithUser(ctx context.Context, user authenticationv1.UserInfo) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// UserFrom returns the authenticated user of the context
+func UserFrom(ctx context.Context) (authenticationv1.UserInfo, bool) {
+	user, ok := ctx.Value(userKey{}).(authenticationv1.UserInfo)
+	return user, ok
+}
@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCert creates a CA if parent is nil, or a client certificate of the common name signed by the parent
+func newTestCert(t *testing.T, commonName string, parent *testCert, usages ...x509.ExtKeyUsage) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  usages,
+	}
+
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key}
+}
+
+func testConfig(ca *testCert, allowedNames ...string) *RequestHeaderConfig {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return &RequestHeaderConfig{
+		ClientCA:            pool,
+		AllowedNames:        allowedNames,
+		UsernameHeaders:     []string{DefaultUsernameHeader},
+		GroupHeaders:        []string{DefaultGroupHeader},
+		ExtraHeaderPrefixes: []string{DefaultExtraHeaderPrefix},
+	}
+}
+
+func TestAuthenticateProxy(t *testing.T) {
+	ca := newTestCert(t, "front-proxy-ca", nil)
+	otherCA := newTestCert(t, "other-ca", nil)
+
+	tc := map[string]struct {
+		peer         *testCert
+		allowedNames []string
+		wantErr      bool
+	}{
+		"allowedName": {
+			peer:         newTestCert(t, "front-proxy-client", ca, x509.ExtKeyUsageClientAuth),
+			allowedNames: []string{"aggregator", "front-proxy-client"},
+		},
+		"anyNameIfNotRestricted": {
+			peer: newTestCert(t, "someone", ca, x509.ExtKeyUsageClientAuth),
+		},
+		"notAllowedName": {
+			peer:         newTestCert(t, "someone", ca, x509.ExtKeyUsageClientAuth),
+			allowedNames: []string{"front-proxy-client"},
+			wantErr:      true,
+		},
+		"otherCA": {
+			peer:         newTestCert(t, "front-proxy-client", otherCA, x509.ExtKeyUsageClientAuth),
+			allowedNames: []string{"front-proxy-client"},
+			wantErr:      true,
+		},
+		"serverCert": {
+			peer:         newTestCert(t, "front-proxy-client", ca, x509.ExtKeyUsageServerAuth),
+			allowedNames: []string{"front-proxy-client"},
+			wantErr:      true,
+		},
+		"noCert": {
+			wantErr: true,
+		},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/apis/registry.tmax.io/v1/imagesigners", nil)
+			req.TLS = &tls.ConnectionState{}
+			if c.peer != nil {
+				req.TLS.PeerCertificates = []*x509.Certificate{c.peer.cert}
+			}
+			req.Header.Set(DefaultUsernameHeader, "alice")
+
+			_, err := NewAuthenticator(testConfig(ca, c.allowedNames...)).Authenticate(req)
+			if (err != nil) != c.wantErr {
+				t.Errorf("expected error %v, got %v", c.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestAuthenticateHeaders(t *testing.T) {
+	ca := newTestCert(t, "front-proxy-ca", nil)
+	peer := newTestCert(t, "front-proxy-client", ca, x509.ExtKeyUsageClientAuth)
+
+	req := httptest.NewRequest("GET", "/apis/registry.tmax.io/v1/imagesigners", nil)
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{peer.cert}}
+	req.Header.Set("X-Remote-User", "alice")
+	req.Header.Add("X-Remote-Group", "developers")
+	req.Header.Add("X-Remote-Group", "system:authenticated")
+	req.Header.Add("X-Remote-Extra-Scopes", "openid")
+	req.Header.Add("X-Remote-Extra-Scopes", "profile")
+	req.Header.Add("X-Remote-Extra-Example.com%2fteam", "signing")
+
+	user, err := NewAuthenticator(testConfig(ca, "front-proxy-client")).Authenticate(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := authenticationv1.UserInfo{
+		Username: "alice",
+		Groups:   []string{"developers", "system:authenticated"},
+		Extra: map[string]authenticationv1.ExtraValue{
+			"scopes":           {"openid", "profile"},
+			"example.com/team": {"signing"},
+		},
+	}
+	if !reflect.DeepEqual(user, expected) {
+		t.Errorf("expected %+v, got %+v", expected, user)
+	}
+
+	req.Header.Del("X-Remote-User")
+	if _, err := NewAuthenticator(testConfig(ca)).Authenticate(req); err == nil {
+		t.Errorf("expected error without user header")
+	}
+}
+
+func TestParseRequestHeaderConfig(t *testing.T) {
+	ca := newTestCert(t, "front-proxy-ca", nil)
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})
+
+	cm := &corev1.ConfigMap{Data: map[string]string{
+		ClientCAKey:            string(caPEM),
+		AllowedNamesKey:        `["front-proxy-client"]`,
+		UsernameHeadersKey:     `["X-Proxy-User"]`,
+		ExtraHeaderPrefixesKey: `[]`,
+	}}
+	cfg, err := ParseRequestHeaderConfig(cm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(cfg.AllowedNames, []string{"front-proxy-client"}) {
+		t.Errorf("unexpected allowed names %v", cfg.AllowedNames)
+	}
+	if !reflect.DeepEqual(cfg.UsernameHeaders, []string{"X-Proxy-User"}) {
+		t.Errorf("unexpected username headers %v", cfg.UsernameHeaders)
+	}
+	if !reflect.DeepEqual(cfg.GroupHeaders, []string{DefaultGroupHeader}) {
+		t.Errorf("unexpected group headers %v", cfg.GroupHeaders)
+	}
+	if !reflect.DeepEqual(cfg.ExtraHeaderPrefixes, []string{DefaultExtraHeaderPrefix}) {
+		t.Errorf("unexpected extra header prefixes %v", cfg.ExtraHeaderPrefixes)
+	}
+
+	delete(cm.Data, ClientCAKey)
+	if _, err := ParseRequestHeaderConfig(cm); err == nil {
+		t.Errorf("expected error without client CA")
+	}
+}
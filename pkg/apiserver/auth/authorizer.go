@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// DefaultAllowedTTL and DefaultDeniedTTL are how long decisions are cached.
+	// They are short, so that changes of RBAC take effect soon
+	DefaultAllowedTTL = 10 * time.Second
+	DefaultDeniedTTL  = 5 * time.Second
+
+	maxCachedDecisions = 4096
+)
+
+// Reviewer creates SubjectAccessReviews, e.g., SubjectAccessReviews() of the authorization client
+type Reviewer interface {
+	Create(ctx context.Context, review *authorizationv1.SubjectAccessReview, opts metav1.CreateOptions) (*authorizationv1.SubjectAccessReview, error)
+}
+
+// ForbiddenError is returned if the user is not allowed to access the resource
+type ForbiddenError struct {
+	Reason string
+}
+
+func (e *ForbiddenError) Error() string {
+	if len(e.Reason) == 0 {
+		return "access is denied"
+	}
+	return e.Reason
+}
+
+// IsForbidden returns true if the error is a ForbiddenError
+func IsForbidden(err error) bool {
+	_, ok := err.(*ForbiddenError)
+	return ok
+}
+
+// Authorizer authorizes users by SubjectAccessReviews, caching the decisions briefly
+type Authorizer struct {
+	reviewer   Reviewer
+	allowedTTL time.Duration
+	deniedTTL  time.Duration
+
+	lock      sync.Mutex
+	decisions map[string]decision
+
+	// now is replaced in tests
+	now func() time.Time
+}
+
+type decision struct {
+	allowed bool
+	reason  string
+	expiry  time.Time
+}
+
+func NewAuthorizer(reviewer Reviewer, allowedTTL, deniedTTL time.Duration) *Authorizer {
+	return &Authorizer{
+		reviewer:   reviewer,
+		allowedTTL: allowedTTL,
+		deniedTTL:  deniedTTL,
+		decisions:  map[string]decision{},
+		now:        time.Now,
+	}
+}
+
+// Authorize returns ForbiddenError if the user is not allowed to access the resource, or other errors if it cannot be reviewed
+func (a *Authorizer) Authorize(ctx context.Context, user authenticationv1.UserInfo, attributes *authorizationv1.ResourceAttributes) error {
+	extra := map[string]authorizationv1.ExtraValue{}
+	for k, v := range user.Extra {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+	spec := authorizationv1.SubjectAccessReviewSpec{
+		User:               user.Username,
+		UID:                user.UID,
+		Groups:             user.Groups,
+		Extra:              extra,
+		ResourceAttributes: attributes,
+	}
+
+	// Map keys are sorted when marshaled, so the same spec has the same key
+	b, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	key := string(b)
+
+	if d, ok := a.cached(key); ok {
+		return d.err()
+	}
+
+	result, err := a.reviewer.Create(ctx, &authorizationv1.SubjectAccessReview{Spec: spec}, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("cannot review access: %v", err)
+	}
+
+	d := decision{allowed: result.Status.Allowed, reason: result.Status.Reason}
+	a.cache(key, d)
+	return d.err()
+}
+
+func (d decision) err() error {
+	if d.allowed {
+		return nil
+	}
+	return &ForbiddenError{Reason: d.reason}
+}
+
+func (a *Authorizer) cached(key string) (decision, bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	d, ok := a.decisions[key]
+	if !ok || !a.now().Before(d.expiry) {
+		return decision{}, false
+	}
+	return d, true
+}
+
+func (a *Authorizer) cache(key string, d decision) {
+	ttl := a.deniedTTL
+	if d.allowed {
+		ttl = a.allowedTTL
+	}
+	if ttl <= 0 {
+		return
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	now := a.now()
+	if len(a.decisions) >= maxCachedDecisions {
+		for k, cached := range a.decisions {
+			if !now.Before(cached.expiry) {
+				delete(a.decisions, k)
+			}
+		}
+		if len(a.decisions) >= maxCachedDecisions {
+			a.decisions = map[string]decision{}
+		}
+	}
+
+	d.expiry = now.Add(ttl)
+	a.decisions[key] = d
+}
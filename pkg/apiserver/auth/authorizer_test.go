@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakeReviewer allows the users in allowed, counting the reviews
+type fakeReviewer struct {
+	allowed map[string]bool
+	err     error
+	reviews int
+}
+
+func (r *fakeReviewer) Create(_ context.Context, review *authorizationv1.SubjectAccessReview, _ metav1.CreateOptions) (*authorizationv1.SubjectAccessReview, error) {
+	r.reviews++
+	if r.err != nil {
+		return nil, r.err
+	}
+	review.Status.Allowed = r.allowed[review.Spec.User]
+	if !review.Status.Allowed {
+		review.Status.Reason = "no RBAC policy matched"
+	}
+	return review, nil
+}
+
+func TestAuthorize(t *testing.T) {
+	reviewer := &fakeReviewer{allowed: map[string]bool{"alice": true}}
+	a := NewAuthorizer(reviewer, DefaultAllowedTTL, DefaultDeniedTTL)
+	now := time.Now()
+	a.now = func() time.Time { return now }
+
+	alice := authenticationv1.UserInfo{Username: "alice", Groups: []string{"developers"}}
+	bob := authenticationv1.UserInfo{Username: "bob"}
+	attrs := &authorizationv1.ResourceAttributes{Group: "registry.tmax.io", Resource: "imagesigners", Verb: "list"}
+
+	for i := 0; i < 2; i++ {
+		if err := a.Authorize(context.Background(), alice, attrs); err != nil {
+			t.Errorf("expected alice to be allowed, got %v", err)
+		}
+		if err := a.Authorize(context.Background(), bob, attrs); !IsForbidden(err) || err.Error() != "no RBAC policy matched" {
+			t.Errorf("expected bob to be forbidden, got %v", err)
+		}
+	}
+	if reviewer.reviews != 2 {
+		t.Errorf("expected decisions to be cached, got %d reviews", reviewer.reviews)
+	}
+
+	// Other attributes are reviewed again
+	if err := a.Authorize(context.Background(), alice, &authorizationv1.ResourceAttributes{Group: "registry.tmax.io", Resource: "imagesigners", Verb: "watch"}); err != nil {
+		t.Errorf("expected alice to be allowed, got %v", err)
+	}
+	if reviewer.reviews != 3 {
+		t.Errorf("expected 3 reviews, got %d", reviewer.reviews)
+	}
+
+	// Denied decisions expire first
+	now = now.Add(DefaultDeniedTTL)
+	_ = a.Authorize(context.Background(), alice, attrs)
+	_ = a.Authorize(context.Background(), bob, attrs)
+	if reviewer.reviews != 4 {
+		t.Errorf("expected only the denied decision to expire, got %d reviews", reviewer.reviews)
+	}
+
+	now = now.Add(DefaultAllowedTTL)
+	_ = a.Authorize(context.Background(), alice, attrs)
+	if reviewer.reviews != 5 {
+		t.Errorf("expected the allowed decision to expire, got %d reviews", reviewer.reviews)
+	}
+}
+
+func TestAuthorizeError(t *testing.T) {
+	reviewer := &fakeReviewer{err: errors.New("connection refused")}
+	a := NewAuthorizer(reviewer, DefaultAllowedTTL, DefaultDeniedTTL)
+
+	user := authenticationv1.UserInfo{Username: "alice"}
+	attrs := &authorizationv1.ResourceAttributes{Resource: "imagesigners", Verb: "list"}
+	for i := 0; i < 2; i++ {
+		if err := a.Authorize(context.Background(), user, attrs); err == nil || IsForbidden(err) {
+			t.Errorf("expected review error, got %v", err)
+		}
+	}
+	if reviewer.reviews != 2 {
+		t.Errorf("expected errors not to be cached, got %d reviews", reviewer.reviews)
+	}
+}
@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/util/cert"
+)
+
+// ConfigMap published by kube-apiserver for extension api servers, in kube-system namespace
+const (
+	ConfigMapName = "extension-apiserver-authentication"
+
+	ClientCAKey            = "requestheader-client-ca-file"
+	AllowedNamesKey        = "requestheader-allowed-names"
+	UsernameHeadersKey     = "requestheader-username-headers"
+	GroupHeadersKey        = "requestheader-group-headers"
+	ExtraHeaderPrefixesKey = "requestheader-extra-headers-prefix"
+)
+
+// Default headers, used if the ConfigMap does not specify them
+const (
+	DefaultUsernameHeader    = "X-Remote-User"
+	DefaultGroupHeader       = "X-Remote-Group"
+	DefaultExtraHeaderPrefix = "X-Remote-Extra-"
+)
+
+// RequestHeaderConfig is how the front proxy (i.e., kube-aggregator) authenticates itself and passes the user
+type RequestHeaderConfig struct {
+	// ClientCA verifies client certificates of the front proxy
+	ClientCA *x509.CertPool
+	// AllowedNames are common names of the front proxy. Any common name is allowed if it is empty
+	AllowedNames []string
+
+	UsernameHeaders     []string
+	GroupHeaders        []string
+	ExtraHeaderPrefixes []string
+}
+
+// ParseRequestHeaderConfig parses the request header config of the ConfigMap
+func ParseRequestHeaderConfig(cm *corev1.ConfigMap) (*RequestHeaderConfig, error) {
+	clientCA, ok := cm.Data[ClientCAKey]
+	if !ok {
+		return nil, fmt.Errorf("no key [%s] found in configmap %s/%s", ClientCAKey, cm.Namespace, cm.Name)
+	}
+
+	certs, err := cert.ParseCertsPEM([]byte(clientCA))
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &RequestHeaderConfig{ClientCA: x509.NewCertPool()}
+	for _, c := range certs {
+		cfg.ClientCA.AddCert(c)
+	}
+
+	if cfg.AllowedNames, err = parseList(cm, AllowedNamesKey, nil); err != nil {
+		return nil, err
+	}
+	if cfg.UsernameHeaders, err = parseList(cm, UsernameHeadersKey, []string{DefaultUsernameHeader}); err != nil {
+		return nil, err
+	}
+	if cfg.GroupHeaders, err = parseList(cm, GroupHeadersKey, []string{DefaultGroupHeader}); err != nil {
+		return nil, err
+	}
+	if cfg.ExtraHeaderPrefixes, err = parseList(cm, ExtraHeaderPrefixesKey, []string{DefaultExtraHeaderPrefix}); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// parseList parses the value of the key, which is a JSON array of strings (e.g., ["X-Remote-User"])
+func parseList(cm *corev1.ConfigMap, key string, defaultValue []string) ([]string, error) {
+	value, ok := cm.Data[key]
+	if !ok || len(value) == 0 {
+		return defaultValue, nil
+	}
+
+	var list []string
+	if err := json.Unmarshal([]byte(value), &list); err != nil {
+		return nil, fmt.Errorf("cannot parse key [%s] of configmap %s/%s: %v", key, cm.Namespace, cm.Name, err)
+	}
+	if len(list) == 0 {
+		return defaultValue, nil
+	}
+	return list, nil
+}
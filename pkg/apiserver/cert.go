@@ -1,115 +1,329 @@
 package apiserver
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
-	"io/ioutil"
-	"os"
-	"path"
+	"sync"
 	"time"
 
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/client-go/util/cert"
 	apiregv1 "k8s.io/kube-aggregator/pkg/apis/apiregistration/v1"
 	certResources "knative.dev/pkg/webhook/certificates/resources"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/tmax-cloud/image-signing-operator/internal/utils"
+	"github.com/tmax-cloud/image-signing-operator/pkg/apiserver/auth"
 )
 
 const (
-	CertDir = "/tmp/run-api"
+	APIServiceName = "v1.registry.tmax.io"
 
-	K8sConfigMapName = "extension-apiserver-authentication"
-	K8sConfigMapKey  = "requestheader-client-ca-file"
+	// CertValidity is the validity of self-signed certificates, which are rotated CertRotateBefore they expire
+	CertValidity     = 365 * 24 * time.Hour
+	CertRotateBefore = 30 * 24 * time.Hour
+	// CertRotateGrace is how long the previous certificate is served after the CA of the next one is published,
+	// so that the api server has the new CA bundle before the next certificate is served
+	CertRotateGrace = 10 * time.Minute
 
-	APIServiceName = "v1.registry.tmax.io"
+	// certCheckInterval is how often certificates and the client CA are checked for changes
+	certCheckInterval = time.Minute
 )
 
-// Create and Store certificates for webhook server
-// server key / server cert is stored as file in CertDir
-// CA bundle is stored in ValidatingWebhookConfigurations
-func createCert(ctx context.Context, client client.Client) error {
-	// Make directory recursively
-	if err := os.MkdirAll(CertDir, os.ModePerm); err != nil {
-		return err
+// certManager keeps the serving certificate of the server and the client CA of the front proxy up to date.
+// The serving certificate is self-signed and rotated before it expires, or is read from a TLS secret issued by cert-manager.
+// CA bundle of the serving certificate is stored in APIService and Validating/MutatingWebhookConfigurations
+type certManager struct {
+	client client.Client
+	// secretName is the TLS secret (tls.crt, tls.key and ca.crt) in the operator namespace.
+	// If it is empty, self-signed certificates are generated
+	secretName string
+
+	// authenticator is configured with the request header config of the client CA
+	authenticator *auth.Authenticator
+
+	// now is time.Now, replaced by tests
+	now func() time.Time
+
+	lock sync.RWMutex
+	cert *tls.Certificate
+	leaf *x509.Certificate
+	// caCert is the CA of the current self-signed certificate, kept in the CA bundle with the next CA while rotating
+	caCert        []byte
+	caBundle      []byte
+	secretVersion string
+	// next is the self-signed certificate to be served after CertRotateGrace, whose CA is already published
+	next *nextCert
+
+	clientCA        *x509.CertPool
+	clientCAVersion string
+}
+
+func newCertManager(c client.Client, secretName string) *certManager {
+	return &certManager{
+		client:        c,
+		secretName:    secretName,
+		authenticator: auth.NewAuthenticator(nil),
+		now:           time.Now,
+	}
+}
+
+// nextCert is a rotated certificate, which is served after its CA is published for CertRotateGrace
+type nextCert struct {
+	cert        *tls.Certificate
+	caCert      []byte
+	publishedAt time.Time
+}
+
+// run checks certificates and the client CA periodically until the context is done
+func (m *certManager) run(ctx context.Context) {
+	ticker := time.NewTicker(certCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := m.loadServingCert(ctx); err != nil {
+			log.Error(err, "cannot rotate serving certificate")
+		}
+		if err := m.loadClientCA(ctx); err != nil {
+			log.Error(err, "cannot refresh client CA")
+		}
+	}
+}
+
+// tlsConfig serves the current serving certificate, and verifies client certificates, if given, by the current client CA.
+// Requests of the apis are authenticated again with the allowed names by the authenticator
+func (m *certManager) tlsConfig() *tls.Config {
+	return &tls.Config{
+		GetCertificate: m.getCertificate,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			m.lock.RLock()
+			defer m.lock.RUnlock()
+			return &tls.Config{
+				GetCertificate: m.getCertificate,
+				ClientCAs:      m.clientCA,
+				ClientAuth:     tls.VerifyClientCertIfGiven,
+				NextProtos:     []string{"h2", "http/1.1"},
+			}, nil
+		},
+	}
+}
+
+func (m *certManager) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	if m.cert == nil {
+		return nil, fmt.Errorf("serving certificate is not loaded")
+	}
+	return m.cert, nil
+}
+
+// loadServingCert loads the certificate of the secret if it is changed, or rotates the self-signed certificate if it expires soon.
+// A rotated certificate is served CertRotateGrace after the CA bundle with its CA and the previous CA is published,
+// unless there is no certificate to serve yet or the previous certificate expires
+func (m *certManager) loadServingCert(ctx context.Context) error {
+	if len(m.secretName) > 0 {
+		return m.loadSecretCert(ctx)
+	}
+
+	now := m.now()
+	m.lock.RLock()
+	leaf, caCert, next := m.leaf, m.caCert, m.next
+	m.lock.RUnlock()
+	if leaf != nil && leaf.NotAfter.Sub(now) > CertRotateBefore {
+		return nil
+	}
+
+	if next == nil {
+		svc := utils.OperatorServiceName()
+		ns, err := utils.Namespace()
+		if err != nil {
+			return err
+		}
+
+		tlsKey, tlsCrt, caCrt, err := certResources.CreateCerts(ctx, svc, ns, now.Add(CertValidity))
+		if err != nil {
+			return err
+		}
+		cert, err := parseCert(tlsCrt, tlsKey)
+		if err != nil {
+			return err
+		}
+
+		// Clients may have the previous CA until they get the new bundle, so the previous CA is trusted as well
+		bundle := append(append([]byte{}, caCrt...), caCert...)
+		if err := m.updateCABundle(ctx, bundle); err != nil {
+			return err
+		}
+		next = &nextCert{cert: cert, caCert: caCrt, publishedAt: now}
+
+		m.lock.Lock()
+		m.next = next
+		m.lock.Unlock()
+		log.Info("CA of the next serving certificate is published", "notAfter", cert.Leaf.NotAfter)
+	}
+
+	if leaf != nil && now.Sub(next.publishedAt) < CertRotateGrace && now.Before(leaf.NotAfter) {
+		return nil
 	}
 
-	// Get service name and namespace
-	svc := utils.OperatorServiceName()
+	m.lock.Lock()
+	m.cert = next.cert
+	m.leaf = next.cert.Leaf
+	m.caCert = next.caCert
+	m.next = nil
+	m.lock.Unlock()
+
+	log.Info("serving certificate is rotated", "notAfter", next.cert.Leaf.NotAfter)
+	return nil
+}
+
+// loadSecretCert loads the certificate of the secret if it is changed.
+// The CA of the secret is published only if the certificate is valid
+func (m *certManager) loadSecretCert(ctx context.Context) error {
 	ns, err := utils.Namespace()
 	if err != nil {
 		return err
 	}
 
-	// Create certs
-	tlsKey, tlsCrt, caCrt, err := certResources.CreateCerts(ctx, svc, ns, time.Now().AddDate(1, 0, 0))
-	if err != nil {
+	secret := &corev1.Secret{}
+	if err := m.client.Get(ctx, types.NamespacedName{Name: m.secretName, Namespace: ns}, secret); err != nil {
 		return err
 	}
+	m.lock.RLock()
+	secretVersion := m.secretVersion
+	m.lock.RUnlock()
+	if secret.ResourceVersion == secretVersion {
+		return nil
+	}
 
-	// Write certs to file
-	keyPath := path.Join(CertDir, "tls.key")
-	err = ioutil.WriteFile(keyPath, tlsKey, 0644)
+	cert, err := parseCert(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
 	if err != nil {
-		return err
+		return fmt.Errorf("cannot load certificate of secret %s/%s: %v", ns, m.secretName, err)
+	}
+	if ca := secret.Data["ca.crt"]; len(ca) > 0 {
+		if err := m.updateCABundle(ctx, ca); err != nil {
+			return err
+		}
 	}
 
-	crtPath := path.Join(CertDir, "tls.crt")
-	err = ioutil.WriteFile(crtPath, tlsCrt, 0644)
+	m.lock.Lock()
+	m.cert = cert
+	m.leaf = cert.Leaf
+	m.secretVersion = secret.ResourceVersion
+	m.lock.Unlock()
+
+	log.Info("serving certificate is loaded", "secret", m.secretName, "notAfter", cert.Leaf.NotAfter)
+	return nil
+}
+
+// parseCert parses the certificate and its key, with the parsed leaf certificate
+func parseCert(certPEM, keyPEM []byte) (*tls.Certificate, error) {
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	cert.Leaf = leaf
+	return &cert, nil
+}
+
+// updateCABundle stores the CA bundle in APIService and Validating/MutatingWebhookConfigurations, if it is changed
+func (m *certManager) updateCABundle(ctx context.Context, caBundle []byte) error {
+	m.lock.RLock()
+	unchanged := bytes.Equal(caBundle, m.caBundle)
+	m.lock.RUnlock()
+	if unchanged {
+		return nil
 	}
 
 	// Update ApiService
 	apiService := &apiregv1.APIService{}
-	if err := client.Get(ctx, types.NamespacedName{Name: APIServiceName}, apiService); err != nil {
+	if err := m.client.Get(ctx, types.NamespacedName{Name: APIServiceName}, apiService); err != nil {
 		return err
 	}
-	apiService.Spec.CABundle = caCrt
-	if err := client.Update(ctx, apiService); err != nil {
+	apiService.Spec.CABundle = caBundle
+	if err := m.client.Update(ctx, apiService); err != nil {
 		return err
 	}
 
-	return nil
-}
+	// Update ValidatingWebhookConfiguration
+	webhookCfg := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+	if err := m.client.Get(ctx, types.NamespacedName{Name: ValidatingWebhookName}, webhookCfg); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		log.Info("there is no ValidatingWebhookConfiguration, admission check is disabled", "name", ValidatingWebhookName)
+	} else {
+		for i := range webhookCfg.Webhooks {
+			webhookCfg.Webhooks[i].ClientConfig.CABundle = caBundle
+		}
+		if err := m.client.Update(ctx, webhookCfg); err != nil {
+			return err
+		}
+	}
 
-func tlsConfig(ctx context.Context, client client.Client) (*tls.Config, error) {
-	caPool, err := getCAPool(ctx, client)
-	if err != nil {
-		return nil, err
+	// Update MutatingWebhookConfiguration
+	mutatingCfg := &admissionregistrationv1.MutatingWebhookConfiguration{}
+	if err := m.client.Get(ctx, types.NamespacedName{Name: MutatingWebhookName}, mutatingCfg); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		log.Info("there is no MutatingWebhookConfiguration, requesters are not recorded", "name", MutatingWebhookName)
+	} else {
+		for i := range mutatingCfg.Webhooks {
+			mutatingCfg.Webhooks[i].ClientConfig.CABundle = caBundle
+		}
+		if err := m.client.Update(ctx, mutatingCfg); err != nil {
+			return err
+		}
 	}
 
-	return &tls.Config{
-		ClientCAs:  caPool,
-		ClientAuth: tls.VerifyClientCertIfGiven,
-	}, nil
+	m.lock.Lock()
+	m.caBundle = caBundle
+	m.lock.Unlock()
+	return nil
 }
 
-func getCAPool(ctx context.Context, client client.Client) (*x509.CertPool, error) {
+// loadClientCA loads the request header config of the front proxy if the ConfigMap is changed
+func (m *certManager) loadClientCA(ctx context.Context) error {
 	cm := &corev1.ConfigMap{}
-	if err := client.Get(ctx, types.NamespacedName{Name: K8sConfigMapName, Namespace: metav1.NamespaceSystem}, cm); err != nil {
-		return nil, err
+	if err := m.client.Get(ctx, types.NamespacedName{Name: auth.ConfigMapName, Namespace: metav1.NamespaceSystem}, cm); err != nil {
+		return err
 	}
-
-	clientCA, ok := cm.Data[K8sConfigMapKey]
-	if !ok {
-		return nil, fmt.Errorf("no key [%s] found in configmap %s/%s", K8sConfigMapKey, metav1.NamespaceSystem, K8sConfigMapName)
+	m.lock.RLock()
+	clientCAVersion := m.clientCAVersion
+	m.lock.RUnlock()
+	if cm.ResourceVersion == clientCAVersion {
+		return nil
 	}
 
-	certs, err := cert.ParseCertsPEM([]byte(clientCA))
+	cfg, err := auth.ParseRequestHeaderConfig(cm)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	pool := x509.NewCertPool()
-	for _, c := range certs {
-		pool.AddCert(c)
-	}
+	m.lock.Lock()
+	m.clientCA = cfg.ClientCA
+	m.clientCAVersion = cm.ResourceVersion
+	m.lock.Unlock()
+	m.authenticator.SetConfig(cfg)
 
-	return pool, nil
+	log.Info("client CA is loaded", "configmap", fmt.Sprintf("%s/%s", cm.Namespace, cm.Name), "resourceVersion", cm.ResourceVersion)
+	return nil
 }
@@ -0,0 +1,201 @@
+package apiserver
+
+import (
+	"context"
+	"crypto/x509"
+	"os"
+	"testing"
+	"time"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	apiregv1 "k8s.io/kube-aggregator/pkg/apis/apiregistration/v1"
+	certResources "knative.dev/pkg/webhook/certificates/resources"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newTestCertManager(t *testing.T, secretName string, objs ...runtime.Object) *certManager {
+	if err := os.Setenv("NAMESPACE", "registry-system"); err != nil {
+		t.Fatal(err)
+	}
+
+	scheme := runtime.NewScheme()
+	for _, add := range []func(*runtime.Scheme) error{apiregv1.AddToScheme, corev1.AddToScheme, admissionregistrationv1.AddToScheme} {
+		if err := add(scheme); err != nil {
+			t.Fatal(err)
+		}
+	}
+	objs = append(objs,
+		&apiregv1.APIService{ObjectMeta: metav1.ObjectMeta{Name: APIServiceName}},
+		&admissionregistrationv1.ValidatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: ValidatingWebhookName},
+			Webhooks:   []admissionregistrationv1.ValidatingWebhook{{Name: "validate.registry.tmax.io"}},
+		},
+		&admissionregistrationv1.MutatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: MutatingWebhookName},
+			Webhooks:   []admissionregistrationv1.MutatingWebhook{{Name: "mutate.registry.tmax.io"}},
+		},
+	)
+	return newCertManager(fake.NewFakeClientWithScheme(scheme, objs...), secretName)
+}
+
+// publishedBundle returns the CA bundle of the APIService, after checking the webhooks have the same bundle
+func publishedBundle(t *testing.T, c client.Client) []byte {
+	apiService := &apiregv1.APIService{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: APIServiceName}, apiService); err != nil {
+		t.Fatal(err)
+	}
+	validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: ValidatingWebhookName}, validating); err != nil {
+		t.Fatal(err)
+	}
+	mutating := &admissionregistrationv1.MutatingWebhookConfiguration{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: MutatingWebhookName}, mutating); err != nil {
+		t.Fatal(err)
+	}
+	if string(validating.Webhooks[0].ClientConfig.CABundle) != string(apiService.Spec.CABundle) ||
+		string(mutating.Webhooks[0].ClientConfig.CABundle) != string(apiService.Spec.CABundle) {
+		t.Fatal("expected webhooks to have the CA bundle of the APIService")
+	}
+	return apiService.Spec.CABundle
+}
+
+// verify fails if the served certificate is not trusted by the published CA bundle
+func verify(t *testing.T, m *certManager, now time.Time) *x509.Certificate {
+	cert, err := m.getCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(publishedBundle(t, m.client)) {
+		t.Fatal("expected CAs in the published bundle")
+	}
+	if _, err := cert.Leaf.Verify(x509.VerifyOptions{Roots: pool, CurrentTime: now}); err != nil {
+		t.Fatalf("expected the served certificate to be trusted by the published bundle: %v", err)
+	}
+	return cert.Leaf
+}
+
+func TestRotateServingCert(t *testing.T) {
+	m := newTestCertManager(t, "")
+	now := time.Now().Add(time.Minute)
+	m.now = func() time.Time { return now }
+
+	// the first certificate is served at once
+	if err := m.loadServingCert(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	first := verify(t, m, now)
+
+	// not rotated yet
+	now = first.NotAfter.Add(-CertRotateBefore - time.Hour)
+	if err := m.loadServingCert(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	if m.next != nil {
+		t.Fatal("expected the certificate not to be rotated yet")
+	}
+
+	// the next CA is published, while the previous certificate is served
+	now = first.NotAfter.Add(-CertRotateBefore + time.Hour)
+	if err := m.loadServingCert(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	if served := verify(t, m, now); !served.Equal(first) {
+		t.Fatal("expected the previous certificate to be served during the grace period")
+	}
+	if m.next == nil {
+		t.Fatal("expected the next certificate")
+	}
+	next := m.next.cert.Leaf
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(publishedBundle(t, m.client))
+	if _, err := next.Verify(x509.VerifyOptions{Roots: pool, CurrentTime: now}); err != nil {
+		t.Fatalf("expected the next certificate to be trusted by the published bundle: %v", err)
+	}
+
+	now = now.Add(CertRotateGrace / 2)
+	if err := m.loadServingCert(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	if served := verify(t, m, now); !served.Equal(first) {
+		t.Fatal("expected the previous certificate to be served during the grace period")
+	}
+
+	// the next certificate is served after the grace period, trusted by the same bundle
+	now = now.Add(CertRotateGrace)
+	if err := m.loadServingCert(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	if served := verify(t, m, now); !served.Equal(next) {
+		t.Fatal("expected the next certificate to be served")
+	}
+	if m.next != nil {
+		t.Fatal("expected no next certificate")
+	}
+}
+
+func TestRotateExpiredServingCert(t *testing.T) {
+	m := newTestCertManager(t, "")
+	now := time.Now().Add(time.Minute)
+	m.now = func() time.Time { return now }
+	if err := m.loadServingCert(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	first := verify(t, m, now)
+
+	// an expired certificate is replaced without the grace period
+	now = first.NotAfter.Add(time.Minute)
+	if err := m.loadServingCert(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	if served := verify(t, m, now); served.Equal(first) {
+		t.Fatal("expected the expired certificate to be replaced")
+	}
+}
+
+func TestLoadSecretCert(t *testing.T) {
+	tlsKey, tlsCrt, caCrt, err := certResources.CreateCerts(context.TODO(), "image-signer", "registry-system", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "api-cert", Namespace: "registry-system", ResourceVersion: "1"},
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       tlsCrt,
+			corev1.TLSPrivateKeyKey: []byte("invalid"),
+			"ca.crt":                caCrt,
+		},
+	}
+	m := newTestCertManager(t, "api-cert", secret)
+
+	// the CA of an invalid certificate is not published
+	if err := m.loadServingCert(context.TODO()); err == nil {
+		t.Fatal("expected the invalid certificate not to be loaded")
+	}
+	if bundle := publishedBundle(t, m.client); len(bundle) != 0 {
+		t.Fatalf("expected no CA bundle, got %s", bundle)
+	}
+	if len(m.secretVersion) != 0 {
+		t.Fatal("expected the secret to be loaded again")
+	}
+
+	if err := m.client.Get(context.TODO(), types.NamespacedName{Name: "api-cert", Namespace: "registry-system"}, secret); err != nil {
+		t.Fatal(err)
+	}
+	secret.Data[corev1.TLSPrivateKeyKey] = tlsKey
+	if err := m.client.Update(context.TODO(), secret); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.loadServingCert(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	verify(t, m, time.Now())
+	if m.secretVersion != secret.ResourceVersion {
+		t.Fatalf("expected secret version %s, got %s", secret.ResourceVersion, m.secretVersion)
+	}
+}
@@ -0,0 +1,198 @@
+package discovery
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/tmax-cloud/image-signing-operator/internal/wrapper"
+)
+
+// Route is a handler describing its resource, whose path is in form of
+// /apis/<group>/<version>/[namespaces/{namespace}/]<resource>[/{name}][/<subresource>]
+type Route struct {
+	*wrapper.Resource
+
+	Path    string
+	Methods []string
+
+	GroupVersion schema.GroupVersion
+	Namespaced   bool
+	Name         string
+	// Named is true if the route is on a single object, i.e., the path has a name parameter
+	Named       bool
+	Subresource string
+}
+
+// DiscoveryName is the name of the resource in APIResourceList, e.g., imagesigners/keys
+func (r *Route) DiscoveryName() string {
+	if len(r.Subresource) == 0 {
+		return r.Name
+	}
+	return r.Name + "/" + r.Subresource
+}
+
+// IsList is true if the route lists the resource
+func (r *Route) IsList() bool {
+	return !r.Named && len(r.Subresource) == 0
+}
+
+// Verb returns the verb of the request method on the route, which is the same as the verb the api server authorizes
+func (r *Route) Verb(method string) string {
+	switch method {
+	case http.MethodGet:
+		if r.IsList() {
+			return "list"
+		}
+		return "get"
+	case http.MethodPost, http.MethodPut:
+		if r.Named {
+			return "update"
+		}
+		return "create"
+	}
+	return ""
+}
+
+// Verbs returns the verbs of the route, including watch if the list is watchable
+func (r *Route) Verbs() []string {
+	var verbs []string
+	for _, m := range r.methods() {
+		verb := r.Verb(m)
+		if len(verb) == 0 {
+			continue
+		}
+		verbs = append(verbs, verb)
+		if verb == "list" && r.Watch {
+			verbs = append(verbs, "watch")
+		}
+	}
+	return verbs
+}
+
+// methods are the methods of the route, which is GET if not restricted
+func (r *Route) methods() []string {
+	if len(r.Methods) == 0 {
+		return []string{http.MethodGet}
+	}
+	return r.Methods
+}
+
+// Routes returns the routes of the wrapper and its descendants which describe their resources
+func Routes(w *wrapper.RouterWrapper) []Route {
+	var routes []Route
+
+	if w.Handler != nil && w.Resource != nil {
+		if route, ok := parseRoute(w.FullPath()); ok {
+			route.Resource = w.Resource
+			route.Methods = w.Methods
+			routes = append(routes, route)
+		}
+	}
+
+	for _, c := range w.Children {
+		routes = append(routes, Routes(c)...)
+	}
+
+	return routes
+}
+
+func parseRoute(path string) (Route, bool) {
+	route := Route{Path: path}
+
+	subPaths := strings.Split(strings.Trim(path, "/"), "/")
+	if len(subPaths) < 4 || subPaths[0] != "apis" {
+		return route, false
+	}
+	route.GroupVersion = schema.GroupVersion{Group: subPaths[1], Version: subPaths[2]}
+	subPaths = subPaths[3:]
+
+	if len(subPaths) > 2 && subPaths[0] == "namespaces" && isParam(subPaths[1]) {
+		route.Namespaced = true
+		subPaths = subPaths[2:]
+	}
+
+	route.Name = subPaths[0]
+	subPaths = subPaths[1:]
+	if len(subPaths) > 0 && isParam(subPaths[0]) {
+		route.Named = true
+		subPaths = subPaths[1:]
+	}
+
+	switch len(subPaths) {
+	case 0:
+	case 1:
+		route.Subresource = subPaths[0]
+	default:
+		return route, false
+	}
+
+	return route, !isParam(route.Name)
+}
+
+func isParam(subPath string) bool {
+	return strings.HasPrefix(subPath, "{") && strings.HasSuffix(subPath, "}")
+}
+
+// APIResources returns the resources of the group version, merging verbs of the routes on the same resource
+func APIResources(routes []Route, gv schema.GroupVersion) []metav1.APIResource {
+	var resources []metav1.APIResource
+	index := map[string]int{}
+
+	for _, r := range routes {
+		if r.GroupVersion != gv {
+			continue
+		}
+
+		name := r.DiscoveryName()
+		i, exist := index[name]
+		if !exist {
+			i = len(resources)
+			index[name] = i
+			resources = append(resources, metav1.APIResource{
+				Name:       name,
+				Namespaced: r.Namespaced,
+				Kind:       r.Kind,
+				Verbs:      metav1.Verbs{},
+			})
+		}
+		if len(resources[i].Kind) == 0 {
+			resources[i].Kind = r.Kind
+		}
+		resources[i].Verbs = appendVerbs(resources[i].Verbs, r.Verbs()...)
+	}
+
+	return resources
+}
+
+func appendVerbs(verbs metav1.Verbs, added ...string) metav1.Verbs {
+	set := map[string]bool{}
+	for _, v := range verbs {
+		set[v] = true
+	}
+	for _, v := range added {
+		if !set[v] {
+			set[v] = true
+			verbs = append(verbs, v)
+		}
+	}
+	sort.Strings(verbs)
+	return verbs
+}
+
+// GroupVersions returns the group versions served under the /apis wrapper, in the order they are added
+func GroupVersions(apis *wrapper.RouterWrapper) []schema.GroupVersion {
+	var gvs []schema.GroupVersion
+
+	for _, c := range apis.Children {
+		subPaths := strings.Split(strings.Trim(c.FullPath(), "/"), "/")
+		if len(subPaths) == 3 && subPaths[0] == "apis" {
+			gvs = append(gvs, schema.GroupVersion{Group: subPaths[1], Version: subPaths[2]})
+		}
+	}
+
+	return gvs
+}
@@ -0,0 +1,263 @@
+package discovery
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/mux"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/tmax-cloud/image-signing-operator/internal/wrapper"
+)
+
+type testObject struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec testSpec `json:"spec"`
+}
+
+type testSpec struct {
+	Image   string   `json:"image"`
+	Signers []string `json:"signers,omitempty"`
+	Size    int32    `json:"size,omitempty"`
+	Created metav1.Time
+	Ignored string `json:"-"`
+	hidden  string
+}
+
+type testObjectList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []testObject `json:"items"`
+}
+
+var testGroupVersion = schema.GroupVersion{Group: "registry.tmax.io", Version: "v1"}
+
+func noopHandler(http.ResponseWriter, *http.Request) {}
+
+// newTestApis returns the /apis wrapper with routes of the test group version
+func newTestApis(t *testing.T) (*wrapper.RouterWrapper, *wrapper.RouterWrapper) {
+	root := wrapper.New("/", nil, noopHandler)
+	root.Router = mux.NewRouter()
+
+	apis := wrapper.New("/apis", nil, noopHandler)
+	version := wrapper.New("/registry.tmax.io/v1", nil, noopHandler)
+	list := wrapper.New("/namespaces/{namespace}/tests", []string{http.MethodGet}, noopHandler)
+	list.Resource = &wrapper.Resource{Kind: "Test", Response: &testObjectList{}, Watch: true}
+	get := wrapper.New("/namespaces/{namespace}/tests/{name}", []string{http.MethodGet}, noopHandler)
+	get.Resource = &wrapper.Resource{Kind: "Test", Response: &testObject{}}
+	create := wrapper.New("/namespaces/{namespace}/tests/sign", []string{http.MethodPost}, noopHandler)
+	create.Resource = &wrapper.Resource{Kind: "Test", Body: &testSpec{}, Response: &testObject{}}
+	keys := wrapper.New("/clustertests/{name}/keys", nil, noopHandler)
+	keys.Resource = &wrapper.Resource{Kind: "TestKey"}
+	// not advertised
+	hidden := wrapper.New("/hidden", nil, noopHandler)
+
+	for _, w := range []struct{ parent, child *wrapper.RouterWrapper }{
+		{root, apis}, {apis, version}, {version, list}, {version, get}, {version, create}, {version, keys}, {version, hidden},
+	} {
+		if err := w.parent.Add(w.child); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root, apis
+}
+
+func TestParseRoute(t *testing.T) {
+	tc := map[string]struct {
+		path     string
+		ok       bool
+		expected Route
+	}{
+		"list": {
+			path:     "/apis/registry.tmax.io/v1/imagesigners",
+			ok:       true,
+			expected: Route{GroupVersion: testGroupVersion, Name: "imagesigners"},
+		},
+		"namespacedObject": {
+			path:     "/apis/registry.tmax.io/v1/namespaces/{namespace}/namespaceimagesigners/{name}",
+			ok:       true,
+			expected: Route{GroupVersion: testGroupVersion, Namespaced: true, Name: "namespaceimagesigners", Named: true},
+		},
+		"subresource": {
+			path:     "/apis/registry.tmax.io/v1/imagesigners/{name}/keys",
+			ok:       true,
+			expected: Route{GroupVersion: testGroupVersion, Name: "imagesigners", Named: true, Subresource: "keys"},
+		},
+		"collectionSubresource": {
+			path:     "/apis/registry.tmax.io/v1/namespaces/{namespace}/imagesignrequests/sign",
+			ok:       true,
+			expected: Route{GroupVersion: testGroupVersion, Namespaced: true, Name: "imagesignrequests", Subresource: "sign"},
+		},
+		"notApis":         {path: "/api/v1/pods"},
+		"version":         {path: "/apis/registry.tmax.io/v1"},
+		"tooLong":         {path: "/apis/registry.tmax.io/v1/imagesigners/{name}/keys/{id}"},
+		"paramAsName":     {path: "/apis/registry.tmax.io/v1/{resource}"},
+		"namespaceObject": {path: "/apis/registry.tmax.io/v1/namespaces/{namespace}", ok: true, expected: Route{GroupVersion: testGroupVersion, Name: "namespaces", Named: true}},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			route, ok := parseRoute(c.path)
+			if ok != c.ok {
+				t.Fatalf("expected %t, got %t", c.ok, ok)
+			}
+			if !ok {
+				return
+			}
+			c.expected.Path = c.path
+			if !reflect.DeepEqual(route, c.expected) {
+				t.Fatalf("expected %+v, got %+v", c.expected, route)
+			}
+		})
+	}
+}
+
+func TestVerbs(t *testing.T) {
+	tc := map[string]struct {
+		route    Route
+		expected []string
+	}{
+		"list":        {route: Route{Resource: &wrapper.Resource{}}, expected: []string{"list"}},
+		"watch":       {route: Route{Resource: &wrapper.Resource{Watch: true}}, expected: []string{"list", "watch"}},
+		"get":         {route: Route{Resource: &wrapper.Resource{Watch: true}, Named: true}, expected: []string{"get"}},
+		"subresource": {route: Route{Resource: &wrapper.Resource{}, Named: true, Subresource: "keys"}, expected: []string{"get"}},
+		"create": {
+			route:    Route{Resource: &wrapper.Resource{}, Subresource: "sign", Methods: []string{http.MethodPost}},
+			expected: []string{"create"},
+		},
+		"update": {
+			route:    Route{Resource: &wrapper.Resource{}, Named: true, Methods: []string{http.MethodPut, http.MethodDelete}},
+			expected: []string{"update"},
+		},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			if verbs := c.route.Verbs(); !reflect.DeepEqual(verbs, c.expected) {
+				t.Fatalf("expected %v, got %v", c.expected, verbs)
+			}
+		})
+	}
+}
+
+func TestAPIResources(t *testing.T) {
+	root, apis := newTestApis(t)
+	routes := Routes(root)
+	if len(routes) != 4 {
+		t.Fatalf("expected 4 routes, got %d", len(routes))
+	}
+
+	expected := []metav1.APIResource{
+		{Name: "tests", Namespaced: true, Kind: "Test", Verbs: metav1.Verbs{"get", "list", "watch"}},
+		{Name: "tests/sign", Namespaced: true, Kind: "Test", Verbs: metav1.Verbs{"create"}},
+		{Name: "clustertests/keys", Kind: "TestKey", Verbs: metav1.Verbs{"get"}},
+	}
+	if resources := APIResources(routes, testGroupVersion); !reflect.DeepEqual(resources, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, resources)
+	}
+	if resources := APIResources(routes, schema.GroupVersion{Group: "other", Version: "v1"}); len(resources) != 0 {
+		t.Fatalf("expected no resources of another group, got %+v", resources)
+	}
+
+	if gvs := GroupVersions(apis); !reflect.DeepEqual(gvs, []schema.GroupVersion{testGroupVersion}) {
+		t.Fatalf("expected %v, got %v", testGroupVersion, gvs)
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	root, _ := newTestApis(t)
+	routes := Routes(root)
+
+	for _, version := range []string{OpenAPIV2, OpenAPIV3} {
+		t.Run(version, func(t *testing.T) {
+			doc := OpenAPI(version, "test", routes, testGroupVersion)
+			// documents are served as JSON
+			b, err := json.Marshal(doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed := map[string]interface{}{}
+			if err := json.Unmarshal(b, &parsed); err != nil {
+				t.Fatal(err)
+			}
+
+			paths := parsed["paths"].(map[string]interface{})
+			if len(paths) != 4 {
+				t.Fatalf("expected 4 paths, got %d", len(paths))
+			}
+			list := paths["/apis/registry.tmax.io/v1/namespaces/{namespace}/tests"].(map[string]interface{})
+			get := list["get"].(map[string]interface{})
+			if get["operationId"] != "listRegistryTmaxIoV1NamespacedTests" || get["x-kubernetes-action"] != "list" {
+				t.Fatalf("unexpected list operation %v", get)
+			}
+			if params := get["parameters"].([]interface{}); len(params) != 3 {
+				t.Fatalf("expected parameters of watch, got %v", params)
+			}
+			sign := paths["/apis/registry.tmax.io/v1/namespaces/{namespace}/tests/sign"].(map[string]interface{})
+			if _, ok := sign["post"]; !ok {
+				t.Fatalf("expected post operation, got %v", sign)
+			}
+
+			var definitions map[string]interface{}
+			if version == OpenAPIV3 {
+				if parsed["openapi"] != OpenAPIV3 {
+					t.Fatalf("expected openapi %s, got %v", OpenAPIV3, parsed["openapi"])
+				}
+				definitions = parsed["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+			} else {
+				if parsed["swagger"] != OpenAPIV2 {
+					t.Fatalf("expected swagger %s, got %v", OpenAPIV2, parsed["swagger"])
+				}
+				definitions = parsed["definitions"].(map[string]interface{})
+			}
+
+			const prefix = "com.github.tmax-cloud.image-signing-operator.pkg.apiserver.discovery."
+			object, ok := definitions[prefix+"testObject"].(map[string]interface{})
+			if !ok {
+				t.Fatalf("expected definition of testObject, got %v", definitions)
+			}
+			gvks := object["x-kubernetes-group-version-kind"].([]interface{})
+			if len(gvks) != 1 || gvks[0].(map[string]interface{})["kind"] != "Test" {
+				t.Fatalf("expected kind Test, got %v", gvks)
+			}
+			list = definitions[prefix+"testObjectList"].(map[string]interface{})
+			if gvk := list["x-kubernetes-group-version-kind"].([]interface{})[0].(map[string]interface{}); gvk["kind"] != "TestList" {
+				t.Fatalf("expected kind TestList, got %v", gvk)
+			}
+			if _, ok := definitions["io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"]; !ok {
+				t.Fatal("expected definition of ObjectMeta")
+			}
+		})
+	}
+}
+
+func TestSchema(t *testing.T) {
+	s := newSchemas("#/definitions/")
+	if ref := s.ref(&testSpec{}); ref["$ref"] != "#/definitions/com.github.tmax-cloud.image-signing-operator.pkg.apiserver.discovery.testSpec" {
+		t.Fatalf("unexpected reference %v", ref)
+	}
+
+	spec := s.definitions["com.github.tmax-cloud.image-signing-operator.pkg.apiserver.discovery.testSpec"]
+	expected := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"image":   map[string]interface{}{"type": "string"},
+			"signers": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+			"size":    map[string]interface{}{"type": "integer", "format": "int32"},
+			"Created": map[string]interface{}{"type": "string", "format": "date-time"},
+		},
+		"required": []string{"image", "Created"},
+	}
+	if !reflect.DeepEqual(spec, expected) {
+		t.Fatalf("expected %v, got %v", expected, spec)
+	}
+	if s.ref(nil) != nil {
+		t.Fatal("expected no schema of nil")
+	}
+}
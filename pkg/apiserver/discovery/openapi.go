@@ -0,0 +1,170 @@
+package discovery
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Versions of OpenAPI documents
+const (
+	OpenAPIV2 = "2.0"
+	OpenAPIV3 = "3.0.0"
+)
+
+// OpenAPI returns an OpenAPI document (OpenAPIV2 or OpenAPIV3) of the routes.
+// If gv is not empty, only the routes of the group version are documented
+func OpenAPI(openAPIVersion, title string, routes []Route, gv schema.GroupVersion) map[string]interface{} {
+	v3 := openAPIVersion == OpenAPIV3
+	refPrefix := "#/definitions/"
+	if v3 {
+		refPrefix = "#/components/schemas/"
+	}
+	s := newSchemas(refPrefix)
+
+	paths := map[string]interface{}{}
+	for i := range routes {
+		r := &routes[i]
+		if !gv.Empty() && r.GroupVersion != gv {
+			continue
+		}
+
+		item, _ := paths[r.Path].(map[string]interface{})
+		if item == nil {
+			item = map[string]interface{}{"parameters": pathParameters(r.Path, v3)}
+			paths[r.Path] = item
+		}
+
+		for _, method := range r.methods() {
+			if verb := r.Verb(method); len(verb) > 0 {
+				item[strings.ToLower(method)] = operation(s, r, method, verb, v3)
+			}
+		}
+
+		// Lists and objects of the resource are known to clients by their kinds
+		if len(r.Kind) > 0 && len(r.Subresource) == 0 && r.Response != nil {
+			kind := r.Kind
+			if r.IsList() {
+				kind += "List"
+			}
+			s.addGroupVersionKind(r.Response, r.GroupVersion.Group, r.GroupVersion.Version, kind)
+		}
+	}
+
+	doc := map[string]interface{}{
+		"info":  map[string]interface{}{"title": title, "version": "unversioned"},
+		"paths": paths,
+	}
+	if v3 {
+		doc["openapi"] = OpenAPIV3
+		doc["components"] = map[string]interface{}{"schemas": s.definitions}
+	} else {
+		doc["swagger"] = OpenAPIV2
+		doc["definitions"] = s.definitions
+	}
+	return doc
+}
+
+func operation(s *schemas, r *Route, method, verb string, v3 bool) map[string]interface{} {
+	op := map[string]interface{}{
+		"operationId":         operationID(r, verb),
+		"consumes":            []string{"application/json"},
+		"produces":            []string{"application/json"},
+		"x-kubernetes-action": verb,
+	}
+	if len(r.Kind) > 0 {
+		op["x-kubernetes-group-version-kind"] = map[string]string{
+			"group":   r.GroupVersion.Group,
+			"version": r.GroupVersion.Version,
+			"kind":    r.Kind,
+		}
+	}
+
+	var params []interface{}
+	if verb == "list" && r.Watch {
+		params = append(params,
+			parameter("watch", "query", "Watch for changes of the listed objects, and stream them as watch events", "boolean", false, v3),
+			parameter("resourceVersion", "query", "Resource version of the list, which the watch starts from", "string", false, v3),
+			parameter("timeoutSeconds", "query", "Timeout of the watch", "integer", false, v3),
+		)
+	}
+
+	response := map[string]interface{}{"description": "OK"}
+	if schema := s.ref(r.Response); schema != nil {
+		if v3 {
+			response["content"] = map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+		} else {
+			response["schema"] = schema
+		}
+	}
+	op["responses"] = map[string]interface{}{"200": response, "401": map[string]interface{}{"description": "Unauthorized"}}
+
+	if body := s.ref(r.Body); body != nil && method != http.MethodGet {
+		if v3 {
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  map[string]interface{}{"application/json": map[string]interface{}{"schema": body}},
+			}
+		} else {
+			params = append(params, map[string]interface{}{"name": "body", "in": "body", "required": true, "schema": body})
+		}
+	}
+
+	if v3 {
+		delete(op, "consumes")
+		delete(op, "produces")
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+	return op
+}
+
+// operationID is unique for each verb on the path, e.g., listRegistryTmaxIoV1NamespacedNamespaceimagesigners
+func operationID(r *Route, verb string) string {
+	id := verb
+	for _, s := range strings.Split(r.GroupVersion.Group, ".") {
+		id += strings.Title(s)
+	}
+	id += strings.Title(r.GroupVersion.Version)
+	if r.Namespaced {
+		id += "Namespaced"
+	}
+	id += strings.Title(r.Name)
+	return id + strings.Title(r.Subresource)
+}
+
+// pathParameters returns parameters of the path, e.g., {namespace}
+func pathParameters(path string, v3 bool) []interface{} {
+	var names []string
+	for _, subPath := range strings.Split(path, "/") {
+		if isParam(subPath) {
+			names = append(names, strings.Trim(subPath, "{}"))
+		}
+	}
+	sort.Strings(names)
+
+	params := []interface{}{}
+	for _, name := range names {
+		params = append(params, parameter(name, "path", "", "string", true, v3))
+	}
+	return params
+}
+
+func parameter(name, in, description, typ string, required bool, v3 bool) map[string]interface{} {
+	p := map[string]interface{}{"name": name, "in": in}
+	if len(description) > 0 {
+		p["description"] = description
+	}
+	if required {
+		p["required"] = true
+	}
+	if v3 {
+		p["schema"] = map[string]interface{}{"type": typ}
+	} else {
+		p["type"] = typ
+	}
+	return p
+}
@@ -0,0 +1,164 @@
+package discovery
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	metaTimeType  = reflect.TypeOf(metav1.Time{})
+	microTimeType = reflect.TypeOf(metav1.MicroTime{})
+)
+
+// schemas generates JSON schemas of go types from their json tags.
+// Named struct types are added to definitions and referred with refPrefix (e.g., #/definitions/)
+type schemas struct {
+	refPrefix   string
+	definitions map[string]map[string]interface{}
+}
+
+func newSchemas(refPrefix string) *schemas {
+	return &schemas{refPrefix: refPrefix, definitions: map[string]map[string]interface{}{}}
+}
+
+// definitionName is the REST friendly name of the type, e.g., io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta
+func definitionName(t reflect.Type) string {
+	pkgPath := strings.Split(t.PkgPath(), "/")
+	domain := strings.Split(pkgPath[0], ".")
+	for i, j := 0, len(domain)-1; i < j; i, j = i+1, j-1 {
+		domain[i], domain[j] = domain[j], domain[i]
+	}
+	return strings.Join(append(append(domain, pkgPath[1:]...), t.Name()), ".")
+}
+
+// ref returns the schema of the value, or nil if the value is nil
+func (s *schemas) ref(v interface{}) map[string]interface{} {
+	if v == nil {
+		return nil
+	}
+	return s.schemaOf(reflect.TypeOf(v))
+}
+
+func (s *schemas) schemaOf(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType, metaTimeType, microTimeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number", "format": "double"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": s.schemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": s.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if len(t.Name()) == 0 {
+			return s.structSchema(t)
+		}
+		name := definitionName(t)
+		if _, exist := s.definitions[name]; !exist {
+			// Placeholder for recursive types
+			s.definitions[name] = map[string]interface{}{}
+			s.definitions[name] = s.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": s.refPrefix + name}
+	}
+
+	// Interfaces and others can be any value
+	return map[string]interface{}{}
+}
+
+func (s *schemas) structSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+	s.addProperties(t, properties, &required)
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// addProperties adds the fields of the struct, including inlined fields of embedded structs
+func (s *schemas) addProperties(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := strings.Split(f.Tag.Get("json"), ",")
+		name := tag[0]
+		if name == "-" || (len(f.PkgPath) > 0 && !f.Anonymous) {
+			continue
+		}
+
+		if f.Anonymous && len(name) == 0 {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				s.addProperties(ft, properties, required)
+				continue
+			}
+		}
+
+		if len(name) == 0 {
+			name = f.Name
+		}
+		properties[name] = s.schemaOf(f.Type)
+
+		omitEmpty := false
+		for _, opt := range tag[1:] {
+			if opt == "omitempty" {
+				omitEmpty = true
+			}
+		}
+		if !omitEmpty {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// addGroupVersionKind marks the definition of the type as an object of the kind
+func (s *schemas) addGroupVersionKind(v interface{}, group, version, kind string) {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || len(t.Name()) == 0 {
+		return
+	}
+
+	def, exist := s.definitions[definitionName(t)]
+	if !exist {
+		return
+	}
+
+	const key = "x-kubernetes-group-version-kind"
+	gvks, _ := def[key].([]map[string]string)
+	for _, gvk := range gvks {
+		if gvk["kind"] == kind && gvk["group"] == group && gvk["version"] == version {
+			return
+		}
+	}
+	def[key] = append(gvks, map[string]string{"group": group, "version": version, "kind": kind})
+}
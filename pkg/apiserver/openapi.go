@@ -0,0 +1,73 @@
+package apiserver
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/tmax-cloud/image-signing-operator/internal/utils"
+	"github.com/tmax-cloud/image-signing-operator/internal/wrapper"
+	"github.com/tmax-cloud/image-signing-operator/pkg/apiserver/discovery"
+)
+
+const (
+	OpenAPIV2Path = "/openapi/v2"
+	OpenAPIV3Path = "/openapi/v3"
+
+	openAPITitle = "image-signing-operator"
+)
+
+// addOpenAPIs serves OpenAPI documents of the apis registered to the server, which the aggregator collects
+func (s *Server) addOpenAPIs() error {
+	v2Wrapper := wrapper.New(OpenAPIV2Path, []string{"GET"}, s.openAPIV2Handler)
+	if err := s.Wrapper.Add(v2Wrapper); err != nil {
+		return err
+	}
+
+	v3Wrapper := wrapper.New(OpenAPIV3Path, []string{"GET"}, s.openAPIV3Handler)
+	if err := s.Wrapper.Add(v3Wrapper); err != nil {
+		return err
+	}
+
+	gvWrapper := wrapper.New("/apis/{group}/{version}", []string{"GET"}, s.openAPIV3GroupVersionHandler)
+	if err := v3Wrapper.Add(gvWrapper); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Server) openAPIV2Handler(w http.ResponseWriter, _ *http.Request) {
+	_ = utils.RespondJSON(w, discovery.OpenAPI(discovery.OpenAPIV2, openAPITitle, discovery.Routes(s.Wrapper), schema.GroupVersion{}))
+}
+
+// openAPIV3Handler returns the paths of the OpenAPI v3 documents of each group version
+func (s *Server) openAPIV3Handler(w http.ResponseWriter, _ *http.Request) {
+	paths := map[string]interface{}{}
+	for _, r := range discovery.Routes(s.Wrapper) {
+		gvPath := fmt.Sprintf("apis/%s/%s", r.GroupVersion.Group, r.GroupVersion.Version)
+		paths[gvPath] = map[string]string{"serverRelativeURL": fmt.Sprintf("%s/%s", OpenAPIV3Path, gvPath)}
+	}
+
+	_ = utils.RespondJSON(w, map[string]interface{}{"paths": paths})
+}
+
+func (s *Server) openAPIV3GroupVersionHandler(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	gv := schema.GroupVersion{Group: vars["group"], Version: vars["version"]}
+
+	var routes []discovery.Route
+	for _, r := range discovery.Routes(s.Wrapper) {
+		if r.GroupVersion == gv {
+			routes = append(routes, r)
+		}
+	}
+	if len(routes) == 0 {
+		_ = utils.RespondError(w, http.StatusNotFound, fmt.Sprintf("there is no group version %s", gv))
+		return
+	}
+
+	_ = utils.RespondJSON(w, discovery.OpenAPI(discovery.OpenAPIV3, openAPITitle, routes, gv))
+}
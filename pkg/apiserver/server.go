@@ -6,19 +6,23 @@ import (
 	v1 "github.com/tmax-cloud/image-signing-operator/pkg/apiserver/apis/v1"
 	"net/http"
 	"os"
-	"path"
 
 	"github.com/gorilla/mux"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	apiregv1 "k8s.io/kube-aggregator/pkg/apis/apiregistration/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	tmaxiov1 "github.com/tmax-cloud/image-signing-operator/api/v1"
 
 	"github.com/tmax-cloud/image-signing-operator/internal/utils"
 	"github.com/tmax-cloud/image-signing-operator/internal/wrapper"
 	"github.com/tmax-cloud/image-signing-operator/pkg/apiserver/apis"
+	"github.com/tmax-cloud/image-signing-operator/pkg/tracing"
 )
 
 const (
@@ -30,14 +34,19 @@ var log = logf.Log.WithName("extension-api-server")
 type Server struct {
 	Wrapper *wrapper.RouterWrapper
 	Client  client.Client
+
+	certs *certManager
 }
 
-func New() *Server {
+// New creates the extension api server. Its serving certificate is read from the TLS secret of certSecretName
+// (e.g., issued by cert-manager) in the operator namespace, or is self-signed if certSecretName is empty
+func New(certSecretName string) *Server {
 	var err error
 
 	server := &Server{}
 	server.Wrapper = wrapper.New("/", nil, server.rootHandler)
 	server.Wrapper.Router = mux.NewRouter()
+	server.Wrapper.Router.Use(tracing.Middleware)
 	server.Wrapper.Router.HandleFunc("/", server.rootHandler)
 
 	if err := apis.AddApis(server.Wrapper); err != nil {
@@ -45,7 +54,12 @@ func New() *Server {
 		os.Exit(1)
 	}
 
-	// Create CERT & Update Secret/ApiService
+	if err := server.addOpenAPIs(); err != nil {
+		log.Error(err, "cannot add openapi documents")
+		os.Exit(1)
+	}
+
+	// Load serving certificate & Update CA bundle of APIService/ValidatingWebhookConfiguration
 	opt := client.Options{}
 	opt.Scheme = runtime.NewScheme()
 	if err := apiregv1.AddToScheme(opt.Scheme); err != nil {
@@ -56,33 +70,70 @@ func New() *Server {
 		log.Error(err, "cannot register scheme")
 		os.Exit(1)
 	}
+	if err := admissionregistrationv1.AddToScheme(opt.Scheme); err != nil {
+		log.Error(err, "cannot register scheme")
+		os.Exit(1)
+	}
+	if err := tmaxiov1.AddToScheme(opt.Scheme); err != nil {
+		log.Error(err, "cannot register scheme")
+		os.Exit(1)
+	}
 
 	server.Client, err = utils.Client(opt)
 	if err != nil {
 		log.Error(err, "cannot get client")
 		os.Exit(1)
 	}
-	if err := createCert(context.TODO(), server.Client); err != nil {
-		log.Error(err, "cannot create cert")
+	server.certs = newCertManager(server.Client, certSecretName)
+	if err := server.certs.loadServingCert(context.TODO()); err != nil {
+		log.Error(err, "cannot load serving certificate")
 		os.Exit(1)
 	}
 
+	// Admission webhooks for ImageSignRequest
+	authCli, err := utils.AuthClient()
+	if err != nil {
+		log.Error(err, "cannot get auth client")
+		os.Exit(1)
+	}
+	server.Wrapper.Router.Handle(SignRequestMutatingWebhookPath, newWebhook(&signRequestRequesterSetter{}, opt.Scheme))
+	operatorUser, err := utils.OperatorUserName()
+	if err != nil {
+		log.Error(err, "cannot get user name of the operator")
+		os.Exit(1)
+	}
+	server.Wrapper.Router.Handle(SignRequestWebhookPath, newWebhook(&signRequestValidator{client: server.Client, authCli: authCli, operatorUser: operatorUser}, opt.Scheme))
+
 	return server
 }
 
-func (s *Server) Start() {
-	v1.Initiate()
-	addr := fmt.Sprintf("0.0.0.0:%d", Port)
-	log.Info(fmt.Sprintf("Server is running on %s", addr))
+func newWebhook(handler admission.Handler, scheme *runtime.Scheme) *admission.Webhook {
+	webhook := &admission.Webhook{Handler: handler}
+	if err := webhook.InjectLogger(log.WithName("webhook")); err != nil {
+		log.Error(err, "cannot inject logger")
+		os.Exit(1)
+	}
+	if err := webhook.InjectScheme(scheme); err != nil {
+		log.Error(err, "cannot inject scheme")
+		os.Exit(1)
+	}
+	return webhook
+}
 
-	cfg, err := tlsConfig(context.TODO(), s.Client)
-	if err != nil {
-		log.Error(err, "cannot get tls config")
+func (s *Server) Start() {
+	if err := s.certs.loadClientCA(context.TODO()); err != nil {
+		log.Error(err, "cannot load client CA")
 		os.Exit(1)
 	}
+	go s.certs.run(context.TODO())
+
+	v1.Initiate(s.certs.authenticator)
+	addr := fmt.Sprintf("0.0.0.0:%d", Port)
+	log.Info(fmt.Sprintf("Server is running on %s", addr))
 
-	httpServer := &http.Server{Addr: addr, Handler: s.Wrapper.Router, TLSConfig: cfg}
-	if err := httpServer.ListenAndServeTLS(path.Join(CertDir, "tls.crt"), path.Join(CertDir, "tls.key")); err != nil {
+	// Certificates are served by the TLS config, which are rotated without restarting the server
+	httpServer := &http.Server{Addr: addr, Handler: s.Wrapper.Router, TLSConfig: s.certs.tlsConfig()}
+	if err := httpServer.ListenAndServeTLS("", ""); err != nil {
 		log.Error(err, "cannot launch server")
 		os.Exit(1)
 	}
@@ -0,0 +1,124 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	authorization "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	tmaxiov1 "github.com/tmax-cloud/image-signing-operator/api/v1"
+	"github.com/tmax-cloud/image-signing-operator/pkg/access"
+)
+
+const (
+	ValidatingWebhookName = "image-signing-operator-validating-webhook"
+	MutatingWebhookName   = "image-signing-operator-mutating-webhook"
+
+	SignRequestWebhookPath         = "/validate-tmax-io-v1-imagesignrequest"
+	SignRequestMutatingWebhookPath = "/mutate-tmax-io-v1-imagesignrequest"
+)
+
+// signRequestRequesterSetter sets the requester of ImageSignRequests to the user who creates them
+type signRequestRequesterSetter struct {
+	decoder *admission.Decoder
+}
+
+func (s *signRequestRequesterSetter) InjectDecoder(d *admission.Decoder) error {
+	s.decoder = d
+	return nil
+}
+
+func (s *signRequestRequesterSetter) Handle(_ context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1beta1.Create {
+		return admission.Allowed("")
+	}
+
+	signReq := &tmaxiov1.ImageSignRequest{}
+	if err := s.decoder.Decode(req, signReq); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	signReq.Spec.Requester = req.UserInfo.Username
+	signReq.Spec.RequesterGroups = req.UserInfo.Groups
+	marshaled, err := json.Marshal(signReq)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
+
+// signRequestValidator denies ImageSignRequests whose requester is not allowed to use the signer,
+// and changes of the spec, e.g., to another signer after the access is checked.
+// The status, including approvals, can only be changed by the operator
+type signRequestValidator struct {
+	client  client.Client
+	authCli authorization.SubjectAccessReviewsGetter
+	decoder *admission.Decoder
+	// operatorUser is the user name of the operator, which adds approvals through the approve/reject apis
+	operatorUser string
+}
+
+func (v *signRequestValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
+
+func (v *signRequestValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	signReq := &tmaxiov1.ImageSignRequest{}
+	if err := v.decoder.Decode(req, signReq); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if req.Operation == admissionv1beta1.Update {
+		old := &tmaxiov1.ImageSignRequest{}
+		if err := v.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if req.SubResource == "status" {
+			if req.UserInfo.Username != v.operatorUser && !equality.Semantic.DeepEqual(old.Status, signReq.Status) {
+				return admission.Denied("status can only be changed by the operator, approve or reject the request through the apis")
+			}
+			return admission.Allowed("")
+		}
+		if !equality.Semantic.DeepEqual(old.Spec, signReq.Spec) {
+			return admission.Denied("spec cannot be changed after the request is created")
+		}
+		return admission.Allowed("")
+	}
+
+	// The requester is set by the mutating webhook, so the request is denied if it is not installed
+	if signReq.Spec.Requester != req.UserInfo.Username {
+		return admission.Denied(fmt.Sprintf("spec.requester should be the user who creates the request (%s)", req.UserInfo.Username))
+	}
+	if !equality.Semantic.DeepEqual(signReq.Spec.RequesterGroups, req.UserInfo.Groups) {
+		return admission.Denied("spec.requesterGroups should be the groups of the user who creates the request")
+	}
+
+	signReq.Namespace = req.Namespace
+	signer := tmaxiov1.NewSigner(signReq.Spec.SignerKind)
+	if err := v.client.Get(ctx, signReq.SignerObjectKey(), signer); err != nil {
+		if errors.IsNotFound(err) {
+			// The request fails while reconciling, so there is nothing to protect here
+			return admission.Allowed("")
+		}
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	if err := access.CheckNamespace(v.client, signer, req.Namespace); err != nil {
+		return admission.Denied(err.Error())
+	}
+
+	if err := access.CheckUser(v.authCli, signer, req.UserInfo); err != nil {
+		return admission.Denied(err.Error())
+	}
+
+	return admission.Allowed("")
+}
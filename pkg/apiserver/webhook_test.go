@@ -0,0 +1,118 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	tmaxiov1 "github.com/tmax-cloud/image-signing-operator/api/v1"
+)
+
+const testOperatorUser = "system:serviceaccount:registry-system:default"
+
+func TestSignRequestValidatorUpdate(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := tmaxiov1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	decoder, err := admission.NewDecoder(scheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := &signRequestValidator{client: fake.NewFakeClientWithScheme(scheme), operatorUser: testOperatorUser}
+	if err := v.InjectDecoder(decoder); err != nil {
+		t.Fatal(err)
+	}
+
+	old := &tmaxiov1.ImageSignRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "req", Namespace: "team"},
+		Spec:       tmaxiov1.ImageSignRequestSpec{Image: "reg/team/app:1", Signer: "signer", Requester: "alice"},
+	}
+	pending := old.DeepCopy()
+	pending.Status.ImageSignResponse = &tmaxiov1.ImageSignResponse{Result: tmaxiov1.ResponseResultPendingApproval}
+	approved := pending.DeepCopy()
+	approved.Status.Approvals = []tmaxiov1.Approval{{User: "bob", Approved: true}}
+
+	tc := map[string]struct {
+		old, new    *tmaxiov1.ImageSignRequest
+		subResource string
+		user        string
+		allowed     bool
+	}{
+		"metadata": {
+			old: old,
+			new: func() *tmaxiov1.ImageSignRequest {
+				r := old.DeepCopy()
+				r.Labels = map[string]string{"team": "a"}
+				return r
+			}(),
+			user:    "alice",
+			allowed: true,
+		},
+		"signer": {
+			old: old,
+			new: func() *tmaxiov1.ImageSignRequest {
+				r := old.DeepCopy()
+				r.Spec.Signer = "other"
+				return r
+			}(),
+			user: "alice",
+		},
+		"signerKind": {
+			old: old,
+			new: func() *tmaxiov1.ImageSignRequest {
+				r := old.DeepCopy()
+				r.Spec.SignerKind = tmaxiov1.SignerKindNamespaceImageSigner
+				return r
+			}(),
+			user: "alice",
+		},
+		"requester": {
+			old: old,
+			new: func() *tmaxiov1.ImageSignRequest {
+				r := old.DeepCopy()
+				r.Spec.Requester = "bob"
+				return r
+			}(),
+			user: "alice",
+		},
+		"approvalByOperator":   {old: pending, new: approved, subResource: "status", user: testOperatorUser, allowed: true},
+		"forgedApproval":       {old: pending, new: approved, subResource: "status", user: "bob"},
+		"unchangedStatus":      {old: approved, new: approved, subResource: "status", user: "bob", allowed: true},
+		"resultByOtherUser":    {old: old, new: pending, subResource: "status", user: "alice"},
+		"responseFromOperator": {old: old, new: pending, subResource: "status", user: testOperatorUser, allowed: true},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			oldRaw, err := json.Marshal(c.old)
+			if err != nil {
+				t.Fatal(err)
+			}
+			newRaw, err := json.Marshal(c.new)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := admission.Request{AdmissionRequest: admissionv1beta1.AdmissionRequest{
+				Operation:   admissionv1beta1.Update,
+				Namespace:   "team",
+				SubResource: c.subResource,
+				UserInfo:    authenticationv1.UserInfo{Username: c.user},
+				Object:      runtime.RawExtension{Raw: newRaw},
+				OldObject:   runtime.RawExtension{Raw: oldRaw},
+			}}
+
+			resp := v.Handle(context.TODO(), req)
+			if resp.Allowed != c.allowed {
+				t.Fatalf("expected allowed %t, got %t: %v", c.allowed, resp.Allowed, resp.Result)
+			}
+		})
+	}
+}
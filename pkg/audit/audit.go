@@ -0,0 +1,505 @@
+package audit
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var log logr.Logger = ctrl.Log.WithName("audit")
+
+// EventType is a type of audited events
+type EventType string
+
+const (
+	EventKeyGenerated EventType = "KeyGenerated"
+	EventKeyImported  EventType = "KeyImported"
+	EventKeyRead      EventType = "KeyRead"
+	EventKeyExported  EventType = "KeyExported"
+	EventKeyRestored  EventType = "KeyRestored"
+	EventImageSigned  EventType = "ImageSigned"
+)
+
+// UserOperator is a user of events done by the operator itself
+const UserOperator = "system:image-signing-operator"
+
+// DefaultMemoryEntries is the number of recent entries kept in memory for queries
+const DefaultMemoryEntries = 10000
+
+// queueSize is the number of entries which are waiting to be written to the sink
+const queueSize = 1000
+
+// batchSize is the number of queued entries which are chained by one update of the checkpoint
+const batchSize = 100
+
+// maxConflicts is the number of times entries are chained again, when other writers advance the checkpoint
+const maxConflicts = 10
+
+// ErrCheckpointConflict is returned by CheckpointStore if the stored checkpoint is not the expected one
+var ErrCheckpointConflict = fmt.Errorf("audit checkpoint is changed by another writer")
+
+// Entry is an audit log entry. Each entry has the HMAC of the previous entry's hash and itself,
+// so that modified, removed or forged entries are detected by the holder of the key
+type Entry struct {
+	Sequence uint64    `json:"sequence"`
+	Time     time.Time `json:"time"`
+	Type     EventType `json:"type"`
+	// User is X-Remote-User of extension api requests, or UserOperator
+	User string `json:"user"`
+	// Signer is <kind>/<name> or <kind>/<namespace>/<name>
+	Signer string `json:"signer,omitempty"`
+	// Request is <namespace>/<name> of the ImageSignRequest
+	Request string `json:"request,omitempty"`
+	Role    string `json:"role,omitempty"`
+	Target  string `json:"target,omitempty"`
+	KeyID   string `json:"keyId,omitempty"`
+	Image   string `json:"image,omitempty"`
+	Digest  string `json:"digest,omitempty"`
+	Message string `json:"message,omitempty"`
+
+	PrevHash string `json:"prevHash"`
+	// Resumes is the previous entry of the same writer, if entries of other writers (e.g., other replicas) are between them.
+	// It is the empty checkpoint for the first entry of a writer which joins the chain
+	Resumes *Checkpoint `json:"resumes,omitempty"`
+	Hash    string      `json:"hash"`
+}
+
+// computeHash returns HMAC-SHA256 of the previous hash and the entry without its hash
+func (e *Entry) computeHash(key []byte) (string, error) {
+	c := *e
+	c.Hash = ""
+	b, err := json.Marshal(&c)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(e.PrevHash))
+	mac.Write(b)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// Checkpoint is the last entry of a chain. Entries after the checkpoint are verified against it,
+// and it is stored out of the sink, so that the chain is continued across restarts even if the sink cannot be read back
+type Checkpoint struct {
+	Sequence uint64 `json:"sequence"`
+	Hash     string `json:"hash"`
+	// Writer identifies the log which wrote the entry, if the chain is shared by replicas
+	Writer string `json:"writer,omitempty"`
+}
+
+// Is returns true if the checkpoints are of the same entry
+func (c *Checkpoint) Is(o *Checkpoint) bool {
+	if c == nil || o == nil {
+		return c == o
+	}
+	return c.Sequence == o.Sequence && c.Hash == o.Hash
+}
+
+// CheckpointStore stores the checkpoint of the log. Logs of replicas share the store, which serializes their appends
+type CheckpointStore interface {
+	// LoadCheckpoint returns the stored checkpoint, or nil if the log has no entries
+	LoadCheckpoint() (*Checkpoint, error)
+	// SaveCheckpoint replaces the checkpoint prev, which is nil if the log has no entries.
+	// It returns ErrCheckpointConflict if the stored checkpoint is not prev
+	SaveCheckpoint(prev, c *Checkpoint) error
+}
+
+// Sink stores entries. It should only append entries
+type Sink interface {
+	Write(e *Entry) error
+	Close() error
+}
+
+// Reader is implemented by sinks which can read their entries back
+type Reader interface {
+	ReadAll() ([]Entry, error)
+}
+
+// Log appends chained entries to the sink. Entries are written in order by a single writer,
+// so that callers do not wait for other callers' writes, e.g., to a slow webhook.
+// Logs of replicas share a chain: entries are chained to the stored checkpoint, and the checkpoint is advanced
+// before they are written, so that no two logs write entries of the same sequence
+type Log struct {
+	sink   Sink
+	key    []byte
+	store  CheckpointStore
+	writer string
+
+	// queue and its writer
+	queueMu sync.RWMutex
+	closed  bool
+	queue   chan *record
+	done    chan struct{}
+
+	// last is the last entry of the chain (nil if it has no entries), and own is the last entry of this log.
+	// They are only used by the writer
+	last *Checkpoint
+	own  Checkpoint
+
+	mu sync.Mutex
+	// recent is used for queries, and anchor is the checkpoint of the entry before recent
+	recent    []Entry
+	anchor    *Checkpoint
+	maxRecent int
+	// err is a tampering detected when the log is opened
+	err error
+}
+
+type record struct {
+	entry Entry
+	// result receives the result of the write, if it is not nil
+	result chan error
+}
+
+// New creates a log whose entries are chained by HMAC with the key. The chain continues from the stored checkpoint.
+// The writer identifies the log among replicas which share the store (e.g., the pod name).
+// If the sink is a Reader, it is verified from its first entry to the checkpoint, or to the last entry of the writer
+// if other writers appended entries after it
+func New(sink Sink, key []byte, store CheckpointStore, writer string) (*Log, error) {
+	if len(key) == 0 {
+		return nil, fmt.Errorf("audit log needs a key")
+	}
+	l := &Log{
+		sink:      sink,
+		key:       key,
+		store:     store,
+		writer:    writer,
+		queue:     make(chan *record, queueSize),
+		done:      make(chan struct{}),
+		maxRecent: DefaultMemoryEntries,
+	}
+
+	checkpoint, err := store.LoadCheckpoint()
+	if err != nil {
+		return nil, err
+	}
+	// entries after the checkpoint of another writer are not in the sink
+	ownCheckpoint := checkpoint != nil && checkpoint.Writer == writer
+	if checkpoint != nil {
+		l.last = checkpoint
+		l.anchor = &Checkpoint{Sequence: checkpoint.Sequence, Hash: checkpoint.Hash}
+		if ownCheckpoint {
+			l.own = Checkpoint{Sequence: checkpoint.Sequence, Hash: checkpoint.Hash}
+		}
+	}
+
+	if r, ok := sink.(Reader); ok {
+		entries, err := r.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		l.err = l.Verify(entries, nil)
+		if l.err == nil && len(entries) > 0 {
+			last := entries[len(entries)-1]
+			if ownCheckpoint || checkpoint == nil {
+				if !checkpoint.Is(&Checkpoint{Sequence: last.Sequence, Hash: last.Hash}) {
+					l.err = fmt.Errorf("last entry %d of the audit log does not match the checkpoint", last.Sequence)
+				}
+			} else if last.Sequence >= checkpoint.Sequence {
+				l.err = fmt.Errorf("last entry %d of the audit log is not before the checkpoint of writer %s", last.Sequence, checkpoint.Writer)
+			} else {
+				l.own = Checkpoint{Sequence: last.Sequence, Hash: last.Hash}
+			}
+		} else if l.err == nil && ownCheckpoint {
+			l.err = fmt.Errorf("audit log is empty, but it has a checkpoint of entry %d", checkpoint.Sequence)
+		}
+		if l.err != nil {
+			// the log is kept as it is for investigation, and new entries are chained to the checkpoint
+			log.Error(l.err, "audit log is tampered")
+		} else {
+			l.setRecent(entries)
+		}
+	}
+
+	go l.run()
+	return l, nil
+}
+
+// setRecent keeps the last entries in memory, with the checkpoint of the entry before them
+func (l *Log) setRecent(entries []Entry) {
+	l.anchor = nil
+	if len(entries) > l.maxRecent {
+		prev := entries[len(entries)-l.maxRecent-1]
+		l.anchor = &Checkpoint{Sequence: prev.Sequence, Hash: prev.Hash}
+		entries = entries[len(entries)-l.maxRecent:]
+	}
+	l.recent = append([]Entry{}, entries...)
+}
+
+// Record appends the entry to the log, setting its sequence, time and hashes.
+// It returns after the entry is written to the sink
+func (l *Log) Record(e Entry) error {
+	result := make(chan error, 1)
+	if err := l.enqueue(&record{entry: e, result: result}); err != nil {
+		return err
+	}
+	return <-result
+}
+
+// RecordAsync appends the entry to the log without waiting for the sink. Errors are logged
+func (l *Log) RecordAsync(e Entry) {
+	if err := l.enqueue(&record{entry: e}); err != nil {
+		log.Error(err, "cannot record audit entry", "type", e.Type)
+	}
+}
+
+func (l *Log) enqueue(r *record) error {
+	r.entry.Time = time.Now().UTC()
+
+	l.queueMu.RLock()
+	defer l.queueMu.RUnlock()
+	if l.closed {
+		return fmt.Errorf("audit log is closed")
+	}
+	l.queue <- r
+	return nil
+}
+
+// run writes queued entries in order until the log is closed.
+// Entries queued while the previous entries are written are chained by one update of the checkpoint
+func (l *Log) run() {
+	defer close(l.done)
+
+	for r := range l.queue {
+		batch := []*record{r}
+	queued:
+		for len(batch) < batchSize {
+			select {
+			case r, ok := <-l.queue:
+				if !ok {
+					break queued
+				}
+				batch = append(batch, r)
+			default:
+				break queued
+			}
+		}
+
+		errs := l.write(batch)
+		for i, r := range batch {
+			if r.result != nil {
+				r.result <- errs[i]
+			} else if errs[i] != nil {
+				log.Error(errs[i], "cannot record audit entry", "type", r.entry.Type)
+			}
+		}
+	}
+}
+
+// write chains the entries to the stored checkpoint and writes them. It returns the result of each entry
+func (l *Log) write(batch []*record) []error {
+	errs := make([]error, len(batch))
+	entries, replaced, err := l.reserve(batch)
+	if err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+
+	written := 0
+	for ; written < len(entries); written++ {
+		if err := l.sink.Write(&entries[written]); err != nil {
+			for i := written; i < len(errs); i++ {
+				errs[i] = err
+			}
+			l.unreserve(entries, written, replaced)
+			break
+		}
+	}
+	if written == 0 {
+		return errs
+	}
+	last := entries[written-1]
+	l.own = Checkpoint{Sequence: last.Sequence, Hash: last.Hash}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// entries of other writers are not kept, so recent entries start after them
+	tail := l.anchor
+	if len(l.recent) > 0 {
+		e := l.recent[len(l.recent)-1]
+		tail = &Checkpoint{Sequence: e.Sequence, Hash: e.Hash}
+	}
+	if first := entries[0]; first.Sequence > 1 && !tail.Is(&Checkpoint{Sequence: first.Sequence - 1, Hash: first.PrevHash}) {
+		l.recent = nil
+		l.anchor = &Checkpoint{Sequence: first.Sequence - 1, Hash: first.PrevHash}
+	}
+	l.recent = append(l.recent, entries[:written]...)
+	if len(l.recent) > l.maxRecent {
+		prev := l.recent[len(l.recent)-l.maxRecent-1]
+		l.anchor = &Checkpoint{Sequence: prev.Sequence, Hash: prev.Hash}
+		l.recent = l.recent[len(l.recent)-l.maxRecent:]
+	}
+	return errs
+}
+
+// reserve chains the entries to the last entry of the chain, and advances the stored checkpoint to the last of them.
+// If another writer advanced the checkpoint, the entries are chained again to the reloaded checkpoint.
+// It returns the replaced checkpoint
+func (l *Log) reserve(batch []*record) ([]Entry, *Checkpoint, error) {
+	for conflicts := 0; ; conflicts++ {
+		entries, err := l.chain(batch)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		e := entries[len(entries)-1]
+		last := &Checkpoint{Sequence: e.Sequence, Hash: e.Hash, Writer: l.writer}
+		err = l.store.SaveCheckpoint(l.last, last)
+		if err == nil {
+			replaced := l.last
+			l.last = last
+			return entries, replaced, nil
+		}
+		if err != ErrCheckpointConflict || conflicts == maxConflicts {
+			return nil, nil, err
+		}
+
+		if l.last, err = l.store.LoadCheckpoint(); err != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+// chain returns the entries chained to the last entry of the chain
+func (l *Log) chain(batch []*record) ([]Entry, error) {
+	entries := make([]Entry, len(batch))
+	prev := Checkpoint{}
+	if l.last != nil {
+		prev = Checkpoint{Sequence: l.last.Sequence, Hash: l.last.Hash}
+	}
+	for i, r := range batch {
+		e := r.entry
+		e.Sequence = prev.Sequence + 1
+		e.PrevHash = prev.Hash
+		if i == 0 && prev != l.own {
+			own := l.own
+			e.Resumes = &own
+		}
+		hash, err := e.computeHash(l.key)
+		if err != nil {
+			return nil, err
+		}
+		e.Hash = hash
+
+		entries[i] = e
+		prev = Checkpoint{Sequence: e.Sequence, Hash: e.Hash}
+	}
+	return entries, nil
+}
+
+// unreserve moves the checkpoint back to the last written entry, or to the replaced checkpoint if no entry is written.
+// If another writer already chained entries to them, the chain has a gap of the entries which are not written
+func (l *Log) unreserve(entries []Entry, written int, replaced *Checkpoint) {
+	prev := replaced
+	if written > 0 {
+		e := entries[written-1]
+		prev = &Checkpoint{Sequence: e.Sequence, Hash: e.Hash, Writer: l.writer}
+	}
+
+	if err := l.store.SaveCheckpoint(l.last, prev); err != nil {
+		log.Error(err, "cannot release audit entries which are not written", "from", entries[written].Sequence, "to", l.last.Sequence)
+		return
+	}
+	l.last = prev
+}
+
+// Entries returns recent entries of the log, and the checkpoint of the entry before them.
+// The checkpoint is nil if the entries start from the first entry of the log
+func (l *Log) Entries() ([]Entry, *Checkpoint) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entries := make([]Entry, len(l.recent))
+	copy(entries, l.recent)
+	return entries, l.anchor
+}
+
+// Err returns a tampering of the log detected when it is opened
+func (l *Log) Err() error {
+	return l.err
+}
+
+// Verify checks the entries with the key of the log. See Verify
+func (l *Log) Verify(entries []Entry, anchor *Checkpoint) error {
+	return Verify(l.key, entries, anchor)
+}
+
+// Close writes queued entries, and closes the sink
+func (l *Log) Close() error {
+	l.queueMu.Lock()
+	if !l.closed {
+		l.closed = true
+		close(l.queue)
+	}
+	l.queueMu.Unlock()
+
+	<-l.done
+	return l.sink.Close()
+}
+
+// Verify checks hashes of the entries and that they are chained without gaps, from the first entry of the log,
+// or from the anchor which is the checkpoint of the entry before them.
+// Entries of other writers may be between an entry and the previous entry of the writer which it resumes
+func Verify(key []byte, entries []Entry, anchor *Checkpoint) error {
+	prev := &Checkpoint{}
+	if anchor != nil {
+		prev = anchor
+	}
+
+	for i := range entries {
+		e := &entries[i]
+		hash, err := e.computeHash(key)
+		if err != nil {
+			return err
+		}
+		if hash != e.Hash {
+			return fmt.Errorf("hash of entry %d does not match", e.Sequence)
+		}
+		chained := e.PrevHash == prev.Hash && e.Sequence == prev.Sequence+1
+		resumed := e.Resumes != nil && e.Resumes.Is(prev) && e.Sequence > prev.Sequence+1
+		if !chained && !resumed {
+			return fmt.Errorf("entry %d is not chained to entry %d", e.Sequence, prev.Sequence)
+		}
+		prev = &Checkpoint{Sequence: e.Sequence, Hash: e.Hash}
+	}
+
+	return nil
+}
+
+// SignerName returns <kind>/[<namespace>/]<name> of a signer
+func SignerName(kind, namespace, name string) string {
+	return path.Join(kind, namespace, name)
+}
+
+var defaultLog *Log
+
+// SetDefault sets the log used by Record
+func SetDefault(l *Log) {
+	defaultLog = l
+}
+
+// Default returns the log used by Record, which may be nil
+func Default() *Log {
+	return defaultLog
+}
+
+// Record appends the entry to the default log without waiting for the sink. Errors are logged, not returned,
+// because failing to audit should not fail signing which is already done
+func Record(e Entry) {
+	if defaultLog == nil {
+		return
+	}
+
+	defaultLog.RecordAsync(e)
+}
@@ -0,0 +1,481 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+type memoryStore struct {
+	mu         sync.Mutex
+	checkpoint *Checkpoint
+	saves      int
+}
+
+func (s *memoryStore) LoadCheckpoint() (*Checkpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.checkpoint, nil
+}
+
+func (s *memoryStore) SaveCheckpoint(prev, c *Checkpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.checkpoint.Is(prev) {
+		return ErrCheckpointConflict
+	}
+	s.checkpoint = c
+	s.saves++
+	return nil
+}
+
+func recordUsers(t *testing.T, l *Log, users ...string) {
+	for _, user := range users {
+		if err := l.Record(Entry{Type: EventKeyRead, User: user}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	l, err := New(&StreamSink{w: &bytes.Buffer{}}, testKey, &memoryStore{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordUsers(t, l, "a", "b", "c")
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	entries, anchor := l.Entries()
+	if anchor != nil {
+		t.Fatalf("expected entries from the first entry, got anchor %v", anchor)
+	}
+
+	tc := map[string]struct {
+		key      []byte
+		entries  func() []Entry
+		anchor   *Checkpoint
+		expected string
+	}{
+		"valid": {
+			key:     testKey,
+			entries: func() []Entry { return entries },
+		},
+		"modified": {
+			key: testKey,
+			entries: func() []Entry {
+				modified := append([]Entry{}, entries...)
+				modified[1].User = "x"
+				return modified
+			},
+			expected: "hash of entry 2 does not match",
+		},
+		"firstRemoved": {
+			key:      testKey,
+			entries:  func() []Entry { return entries[1:] },
+			expected: "entry 2 is not chained to entry 0",
+		},
+		"middleRemoved": {
+			key:      testKey,
+			entries:  func() []Entry { return []Entry{entries[0], entries[2]} },
+			expected: "entry 3 is not chained to entry 1",
+		},
+		"anchored": {
+			key:     testKey,
+			entries: func() []Entry { return entries[1:] },
+			anchor:  &Checkpoint{Sequence: entries[0].Sequence, Hash: entries[0].Hash},
+		},
+		// entries rehashed without the key cannot be forged
+		"otherKey": {
+			key:      []byte("other"),
+			entries:  func() []Entry { return entries },
+			expected: "hash of entry 1 does not match",
+		},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			err := Verify(c.key, c.entries(), c.anchor)
+			if len(c.expected) == 0 && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if len(c.expected) > 0 && (err == nil || err.Error() != c.expected) {
+				t.Fatalf("expected %q, got %v", c.expected, err)
+			}
+		})
+	}
+}
+
+// sinks which cannot be read back continue the chain from the checkpoint after a restart
+func TestStreamSinkRestart(t *testing.T) {
+	store := &memoryStore{}
+	out := &bytes.Buffer{}
+
+	l, err := New(&StreamSink{w: out}, testKey, store, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordUsers(t, l, "a", "b")
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if store.checkpoint == nil || store.checkpoint.Sequence != 2 {
+		t.Fatalf("expected checkpoint of entry 2, got %v", store.checkpoint)
+	}
+
+	l, err = New(&StreamSink{w: out}, testKey, store, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordUsers(t, l, "c")
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, anchor := l.Entries()
+	if len(entries) != 1 || entries[0].Sequence != 3 {
+		t.Fatalf("expected entry 3, got %v", entries)
+	}
+	if err := l.Verify(entries, anchor); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(out.String(), "\n"); lines != 3 {
+		t.Fatalf("expected 3 lines, got %d", lines)
+	}
+}
+
+func TestFileSinkRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+	store := &memoryStore{}
+
+	open := func() *Log {
+		sink, err := NewFileSink(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		l, err := New(sink, testKey, store, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return l
+	}
+
+	l := open()
+	recordUsers(t, l, "a", "b")
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	l = open()
+	if err := l.Err(); err != nil {
+		t.Fatal(err)
+	}
+	recordUsers(t, l, "c")
+	entries, anchor := l.Entries()
+	if len(entries) != 3 || anchor != nil {
+		t.Fatalf("expected 3 entries from the first entry, got %d, %v", len(entries), anchor)
+	}
+	if err := l.Verify(entries, anchor); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// remove the last entry
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(strings.TrimSpace(string(b)), "\n")
+	if err := ioutil.WriteFile(path, []byte(strings.Join(lines[:2], "")), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	l = open()
+	defer l.Close()
+	if err := l.Err(); err == nil || err.Error() != "last entry 2 of the audit log does not match the checkpoint" {
+		t.Fatalf("expected the truncated log to be detected, got %v", err)
+	}
+	// new entries are chained to the checkpoint, so the removed entry is still detected
+	recordUsers(t, l, "d")
+	if store.checkpoint.Sequence != 4 {
+		t.Fatalf("expected entry 4, got %v", store.checkpoint)
+	}
+}
+
+func TestRecentEntries(t *testing.T) {
+	l, err := New(&StreamSink{w: &bytes.Buffer{}}, testKey, &memoryStore{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.maxRecent = 2
+	recordUsers(t, l, "a", "b", "c", "d")
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, anchor := l.Entries()
+	if len(entries) != 2 || entries[0].Sequence != 3 {
+		t.Fatalf("expected entries 3 and 4, got %v", entries)
+	}
+	if anchor == nil || anchor.Sequence != 2 {
+		t.Fatalf("expected anchor of entry 2, got %v", anchor)
+	}
+	if err := l.Verify(entries, anchor); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Verify(entries, nil); err == nil {
+		t.Fatal("expected entries not to be verified from the first entry")
+	}
+}
+
+func TestRecordAsync(t *testing.T) {
+	l, err := New(&StreamSink{w: &bytes.Buffer{}}, testKey, &memoryStore{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		l.RecordAsync(Entry{Type: EventImageSigned, User: UserOperator})
+	}
+	// queued entries are written before the log is closed
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, anchor := l.Entries()
+	if len(entries) != 10 {
+		t.Fatalf("expected 10 entries, got %d", len(entries))
+	}
+	if err := l.Verify(entries, anchor); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Record(Entry{Type: EventKeyRead}); err == nil {
+		t.Fatal("expected an error after the log is closed")
+	}
+}
+
+func TestSecretStore(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewFakeClientWithScheme(scheme)
+
+	store, key, err := LoadSecret(c, "registry-system", DefaultSecretName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(key) != keySize {
+		t.Fatalf("expected a key of %d bytes, got %d", keySize, len(key))
+	}
+	if checkpoint, err := store.LoadCheckpoint(); err != nil || checkpoint != nil {
+		t.Fatalf("expected no checkpoint, got %v, %v", checkpoint, err)
+	}
+	if err := store.SaveCheckpoint(nil, &Checkpoint{Sequence: 3, Hash: "abc"}); err != nil {
+		t.Fatal(err)
+	}
+	// the checkpoint is advanced by another writer
+	if err := store.SaveCheckpoint(nil, &Checkpoint{Sequence: 1, Hash: "def"}); err != ErrCheckpointConflict {
+		t.Fatalf("expected a conflict, got %v", err)
+	}
+
+	store, loaded, err := LoadSecret(c, "registry-system", DefaultSecretName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key, loaded) {
+		t.Fatal("expected the key of the secret")
+	}
+	checkpoint, err := store.LoadCheckpoint()
+	if err != nil || checkpoint == nil || *checkpoint != (Checkpoint{Sequence: 3, Hash: "abc"}) {
+		t.Fatalf("expected the saved checkpoint, got %v, %v", checkpoint, err)
+	}
+
+	secret := &corev1.Secret{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: DefaultSecretName, Namespace: "registry-system"}, secret); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(secret.Data[secretKeyHMAC], key) {
+		t.Fatal("expected the key to be stored in the secret")
+	}
+}
+
+type blockingSink struct {
+	release chan struct{}
+}
+
+func (s *blockingSink) Write(e *Entry) error {
+	<-s.release
+	return nil
+}
+
+func (s *blockingSink) Close() error {
+	return nil
+}
+
+// a slow sink does not block queries and other callers
+func TestSlowSink(t *testing.T) {
+	sink := &blockingSink{release: make(chan struct{})}
+	l, err := New(sink, testKey, &memoryStore{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.RecordAsync(Entry{Type: EventImageSigned})
+	l.RecordAsync(Entry{Type: EventImageSigned})
+
+	if entries, _ := l.Entries(); len(entries) != 0 {
+		t.Fatalf("expected no written entries, got %d", len(entries))
+	}
+
+	close(sink.release)
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := l.Entries(); len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+}
+
+// replicas share the chain of the store, and each sink is verified with gaps of the entries of the other replica
+func TestSharedChain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := &memoryStore{}
+
+	open := func(writer string) *Log {
+		sink, err := NewFileSink(filepath.Join(dir, writer+".log"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		l, err := New(sink, testKey, store, writer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := l.Err(); err != nil {
+			t.Fatalf("expected log of %s to be verified, got %v", writer, err)
+		}
+		return l
+	}
+
+	a, b := open("a"), open("b")
+	recordUsers(t, a, "a1", "a2")
+	recordUsers(t, b, "b1")
+	recordUsers(t, a, "a3")
+	var wg sync.WaitGroup
+	for _, l := range []*Log{a, b} {
+		wg.Add(1)
+		go func(l *Log) {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				l.RecordAsync(Entry{Type: EventImageSigned, User: UserOperator})
+			}
+		}(l)
+	}
+	wg.Wait()
+
+	all := []Entry{}
+	for _, l := range []*Log{a, b} {
+		if err := l.Close(); err != nil {
+			t.Fatal(err)
+		}
+		entries, anchor := l.Entries()
+		if err := l.Verify(entries, anchor); err != nil {
+			t.Fatal(err)
+		}
+		written, err := l.sink.(Reader).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		all = append(all, written...)
+	}
+	if store.checkpoint.Sequence != 24 {
+		t.Fatalf("expected checkpoint of entry 24, got %v", store.checkpoint)
+	}
+	// entries of both sinks are a chain without forks
+	sort.Slice(all, func(i, j int) bool { return all[i].Sequence < all[j].Sequence })
+	if err := Verify(testKey, all, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// the sinks are verified after a restart, and a new replica joins the chain
+	a, b, c := open("a"), open("b"), open("c")
+	recordUsers(t, c, "c1")
+	recordUsers(t, a, "a4")
+	for _, l := range []*Log{a, b, c} {
+		if err := l.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, writer := range []string{"a", "b", "c"} {
+		open(writer).Close()
+	}
+
+	// removing an entry of a sink is detected, even if the entries of the other replica are between them
+	path := filepath.Join(dir, "a.log")
+	b2, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(strings.TrimSpace(string(b2)), "\n")
+	if err := ioutil.WriteFile(path, []byte(strings.Join(append(lines[:2:2], lines[3:]...), "")), 0600); err != nil {
+		t.Fatal(err)
+	}
+	sink, err := NewFileSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := New(sink, testKey, store, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if l.Err() == nil {
+		t.Fatal("expected the removed entry to be detected")
+	}
+}
+
+// entries queued while the sink writes are chained by one update of the checkpoint
+func TestBatchedCheckpoints(t *testing.T) {
+	sink := &blockingSink{release: make(chan struct{})}
+	store := &memoryStore{}
+	l, err := New(sink, testKey, store, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		l.RecordAsync(Entry{Type: EventImageSigned})
+	}
+
+	close(sink.release)
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if store.checkpoint.Sequence != 10 {
+		t.Fatalf("expected checkpoint of entry 10, got %v", store.checkpoint)
+	}
+	if store.saves > 2 {
+		t.Fatalf("expected 2 updates of the checkpoint at most, got %d", store.saves)
+	}
+}
@@ -0,0 +1,121 @@
+package audit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DefaultSecretName is the secret of the HMAC key and the checkpoint of the audit log, in the operator namespace
+	DefaultSecretName = "image-signing-audit"
+
+	secretKeyHMAC       = "key"
+	secretKeyCheckpoint = "checkpoint"
+	keySize             = 32
+)
+
+// SecretStore keeps the HMAC key and the checkpoint of the log in a secret.
+// Deleting the secret makes all entries unverifiable, instead of restarting the chain silently
+type SecretStore struct {
+	client client.Client
+	key    types.NamespacedName
+}
+
+// LoadSecret returns the store of the secret and its HMAC key. The secret is created with a random key if it does not exist.
+// The client should not read from a cache, as the store is used before the cache is started
+func LoadSecret(c client.Client, namespace, name string) (*SecretStore, []byte, error) {
+	store := &SecretStore{client: c, key: types.NamespacedName{Name: name, Namespace: namespace}}
+
+	secret := &corev1.Secret{}
+	err := c.Get(context.TODO(), store.key, secret)
+	if err == nil {
+		key := secret.Data[secretKeyHMAC]
+		if len(key) == 0 {
+			return nil, nil, errors.NewBadRequest("audit secret " + name + " has no key")
+		}
+		return store, key, nil
+	}
+	if !errors.IsNotFound(err) {
+		return nil, nil, err
+	}
+
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, err
+	}
+	secret = &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Type:       corev1.SecretTypeOpaque,
+		Data:       map[string][]byte{secretKeyHMAC: key},
+	}
+	if err := c.Create(context.TODO(), secret); err != nil {
+		return nil, nil, err
+	}
+	log.Info("audit key is generated", "secret", store.key)
+
+	return store, key, nil
+}
+
+func (s *SecretStore) LoadCheckpoint() (*Checkpoint, error) {
+	secret := &corev1.Secret{}
+	if err := s.client.Get(context.TODO(), s.key, secret); err != nil {
+		return nil, err
+	}
+	return checkpointOf(secret)
+}
+
+// checkpointOf returns the checkpoint in the secret, or nil if the log has no entries
+func checkpointOf(secret *corev1.Secret) (*Checkpoint, error) {
+	b, ok := secret.Data[secretKeyCheckpoint]
+	if !ok {
+		return nil, nil
+	}
+	checkpoint := &Checkpoint{}
+	if err := json.Unmarshal(b, checkpoint); err != nil {
+		return nil, err
+	}
+	return checkpoint, nil
+}
+
+// SaveCheckpoint replaces the checkpoint prev. It returns ErrCheckpointConflict if the stored checkpoint is not prev,
+// or if another writer updates the secret after it is read
+func (s *SecretStore) SaveCheckpoint(prev, c *Checkpoint) error {
+	secret := &corev1.Secret{}
+	if err := s.client.Get(context.TODO(), s.key, secret); err != nil {
+		return err
+	}
+	stored, err := checkpointOf(secret)
+	if err != nil {
+		return err
+	}
+	if !stored.Is(prev) {
+		return ErrCheckpointConflict
+	}
+
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	if c == nil {
+		delete(secret.Data, secretKeyCheckpoint)
+	} else {
+		b, err := json.Marshal(c)
+		if err != nil {
+			return err
+		}
+		secret.Data[secretKeyCheckpoint] = b
+	}
+	if err := s.client.Update(context.TODO(), secret); err != nil {
+		if errors.IsConflict(err) {
+			return ErrCheckpointConflict
+		}
+		return err
+	}
+	return nil
+}
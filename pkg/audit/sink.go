@@ -0,0 +1,164 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	SinkFile    = "file"
+	SinkStdout  = "stdout"
+	SinkWebhook = "webhook"
+	SinkNone    = "none"
+
+	webhookTimeout = 10 * time.Second
+)
+
+// NewSink creates a sink of the type. Target is a file path for file sinks, or a URL for webhook sinks
+func NewSink(sinkType, target string) (Sink, error) {
+	switch sinkType {
+	case SinkFile:
+		return NewFileSink(target)
+	case SinkStdout:
+		return &StreamSink{w: os.Stdout}, nil
+	case SinkWebhook:
+		if len(target) == 0 {
+			return nil, fmt.Errorf("webhook sink needs url")
+		}
+		return &WebhookSink{url: target, client: &http.Client{Timeout: webhookTimeout}}, nil
+	case SinkNone, "":
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("audit sink %s is not supported", sinkType)
+}
+
+// FileSink appends entries to a file as JSON lines
+type FileSink struct {
+	path string
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileSink opens the file in append-only mode, creating it if it does not exist
+func NewFileSink(path string) (*FileSink, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("file sink needs path")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileSink{path: path, file: f}, nil
+}
+
+func (s *FileSink) Write(e *Entry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.file.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+func (s *FileSink) ReadAll() ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		e := Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("line %d of audit log is malformed", line)
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, scanner.Err()
+}
+
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// StreamSink writes entries to a stream (e.g., stdout) as JSON lines
+type StreamSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *StreamSink) Write(e *Entry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.w.Write(append(b, '\n'))
+	return err
+}
+
+func (s *StreamSink) Close() error {
+	return nil
+}
+
+// WebhookSink posts each entry to the URL as JSON
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func (s *WebhookSink) Write(e *Entry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("audit webhook responded %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *WebhookSink) Close() error {
+	return nil
+}
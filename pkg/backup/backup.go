@@ -0,0 +1,144 @@
+package backup
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+
+	apiv1 "github.com/tmax-cloud/image-signing-operator/api/v1"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	ArchiveVersion = 1
+	KdfScrypt      = "scrypt"
+
+	// MinPasswordLength is the minimum length of archive passwords
+	MinPasswordLength = 8
+
+	scryptN    = 1 << 15
+	scryptR    = 8
+	scryptP    = 1
+	saltLength = 16
+
+	// Parameters of archives are limited, as they are given by users. Memory of scrypt is 128*N*R bytes (128MiB at most)
+	maxScryptN = 1 << 17
+	maxScryptR = 8
+	maxScryptP = 4
+)
+
+// Archive is a password protected archive of signer keys.
+// Contents are encrypted by ChaCha20-Poly1305 with a key derived from the password by scrypt
+type Archive struct {
+	Version    int    `json:"version"`
+	Kdf        string `json:"kdf"`
+	N          int    `json:"n"`
+	R          int    `json:"r"`
+	P          int    `json:"p"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// Contents is the decrypted contents of an archive
+type Contents struct {
+	ExportedAt metav1.Time `json:"exportedAt"`
+	Keys       []Entry     `json:"keys"`
+}
+
+// Entry is a signer key and its signer
+type Entry struct {
+	// Kind is SignerKey or NamespaceSignerKey
+	Kind        string            `json:"kind"`
+	Name        string            `json:"name"`
+	Namespace   string            `json:"namespace,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	Spec apiv1.SignerKeySpec `json:"spec"`
+	// Signer is the spec of the signer owning the key, if it exists
+	Signer *apiv1.ImageSignerSpec `json:"signer,omitempty"`
+}
+
+// Encrypt encrypts contents with the password
+func Encrypt(contents *Contents, password string) (*Archive, error) {
+	if len(password) < MinPasswordLength {
+		return nil, fmt.Errorf("password should be at least %d characters", MinPasswordLength)
+	}
+
+	plain, err := json.Marshal(contents)
+	if err != nil {
+		return nil, err
+	}
+
+	archive := &Archive{
+		Version: ArchiveVersion,
+		Kdf:     KdfScrypt,
+		N:       scryptN,
+		R:       scryptR,
+		P:       scryptP,
+		Salt:    make([]byte, saltLength),
+		Nonce:   make([]byte, chacha20poly1305.NonceSize),
+	}
+	if _, err := rand.Read(archive.Salt); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(archive.Nonce); err != nil {
+		return nil, err
+	}
+
+	aead, err := archive.aead(password)
+	if err != nil {
+		return nil, err
+	}
+	archive.Ciphertext = aead.Seal(nil, archive.Nonce, plain, archive.additionalData())
+
+	return archive, nil
+}
+
+// Decrypt decrypts the archive with the password
+func Decrypt(archive *Archive, password string) (*Contents, error) {
+	if archive.Version != ArchiveVersion || archive.Kdf != KdfScrypt {
+		return nil, fmt.Errorf("unsupported archive version %d (kdf: %s)", archive.Version, archive.Kdf)
+	}
+	if archive.N > maxScryptN || archive.R > maxScryptR || archive.P > maxScryptP {
+		return nil, fmt.Errorf("scrypt parameters of the archive exceed the limits (n: %d, r: %d, p: %d)", maxScryptN, maxScryptR, maxScryptP)
+	}
+	if len(archive.Nonce) != chacha20poly1305.NonceSize {
+		return nil, fmt.Errorf("nonce of the archive should be %d bytes", chacha20poly1305.NonceSize)
+	}
+
+	aead, err := archive.aead(password)
+	if err != nil {
+		return nil, err
+	}
+
+	plain, err := aead.Open(nil, archive.Nonce, archive.Ciphertext, archive.additionalData())
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt archive, password may be wrong")
+	}
+
+	contents := &Contents{}
+	if err := json.Unmarshal(plain, contents); err != nil {
+		return nil, err
+	}
+
+	return contents, nil
+}
+
+func (a *Archive) aead(password string) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(password), a.Salt, a.N, a.R, a.P, chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, err
+	}
+
+	return chacha20poly1305.New(key)
+}
+
+// additionalData binds the kdf parameters to the ciphertext
+func (a *Archive) additionalData() []byte {
+	return []byte(fmt.Sprintf("%d/%s/%d/%d/%d", a.Version, a.Kdf, a.N, a.R, a.P))
+}
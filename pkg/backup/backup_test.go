@@ -0,0 +1,103 @@
+package backup
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	apiv1 "github.com/tmax-cloud/image-signing-operator/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const testPassword = "correct horse battery staple"
+
+func testContents() *Contents {
+	return &Contents{
+		ExportedAt: metav1.NewTime(time.Unix(1601510400, 0)),
+		Keys: []Entry{{
+			Kind:   "SignerKey",
+			Name:   "signer",
+			Labels: map[string]string{"app": "signer"},
+			Spec: apiv1.SignerKeySpec{
+				Root: apiv1.TrustKey{ID: "root-key-id", Key: "root-key", PassPhrase: "root-passphrase"},
+			},
+		}},
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	contents := testContents()
+	archive, err := Encrypt(contents, testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decrypted, err := Decrypt(archive, testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decrypted, contents) {
+		t.Errorf("expected %+v, got %+v", contents, decrypted)
+	}
+
+	if _, err := Encrypt(contents, "short"); err == nil {
+		t.Errorf("expected error for short password")
+	}
+}
+
+func TestDecryptError(t *testing.T) {
+	tc := map[string]struct {
+		password string
+		tamper   func(a *Archive)
+	}{
+		"wrongPassword": {password: "wrong password"},
+		"ciphertext": {tamper: func(a *Archive) {
+			a.Ciphertext[0] ^= 1
+		}},
+		"salt": {tamper: func(a *Archive) {
+			a.Salt[0] ^= 1
+		}},
+		// Parameters are authenticated as additional data
+		"parameters": {tamper: func(a *Archive) {
+			a.P = 2
+		}},
+		"shortNonce": {tamper: func(a *Archive) {
+			a.Nonce = a.Nonce[:4]
+		}},
+		"noNonce": {tamper: func(a *Archive) {
+			a.Nonce = nil
+		}},
+		"largeN": {tamper: func(a *Archive) {
+			a.N = 1 << 30
+		}},
+		"largeR": {tamper: func(a *Archive) {
+			a.R = 1 << 20
+		}},
+		"largeP": {tamper: func(a *Archive) {
+			a.P = 1 << 20
+		}},
+		"version": {tamper: func(a *Archive) {
+			a.Version = 2
+		}},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			archive, err := Encrypt(testContents(), testPassword)
+			if err != nil {
+				t.Fatal(err)
+			}
+			password := testPassword
+			if len(c.password) > 0 {
+				password = c.password
+			}
+			if c.tamper != nil {
+				c.tamper(archive)
+			}
+
+			if _, err := Decrypt(archive, password); err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
@@ -2,11 +2,15 @@ package controller
 
 import (
 	"bytes"
+	"context"
+	"io"
 	"os"
 	"path"
+	"sort"
 	"strings"
 
 	"github.com/tmax-cloud/image-signing-operator/internal/k8s"
+	"github.com/tmax-cloud/image-signing-operator/pkg/tracing"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -17,16 +21,33 @@ type ExecResult struct {
 }
 
 const (
+	// DockerConfigDir is a directory of docker config.json
+	DockerConfigDir  = "/root/.docker"
+	DockerConfigFile = "config.json"
 	// BaseDir is docker trust content base directory path
 	BaseDir = "/root/.docker/trust"
 	// PrivateKeyDir is docker trust content private key directory path
 	PrivateKeyDir = BaseDir + "/private"
+	// TLSDir is a directory of certs of notary servers, as /root/.docker/tls/<host>/ca.crt
+	TLSDir = "/root/.docker/tls"
+	// PublicKeyFileExt is an extension of public key files created by 'docker trust key generate'
+	PublicKeyFileExt = ".pub"
+	// SessionEnvFile has environment variables of the session (e.g., passphrases), which every command sources
+	SessionEnvFile = "/root/.docker/session.env"
 )
 
 // KubeCommander is a commander to excute command to container in specified pod
 type KubeCommander struct {
 	client                    client.Client
 	namespace, pod, container string
+	// envFile is sourced by every command, after the variables of the session are stored in it
+	envFile string
+	// failed is set if any command failed
+	failed bool
+	// ctx is the parent of spans of commands
+	ctx context.Context
+	// exec runs the command in the container of the pod
+	exec func(pod, container, namespace, command string, stdin io.Reader, stdout, stderr io.Writer) error
 }
 
 // NewKubeCommander create KubeCommander
@@ -41,6 +62,8 @@ func NewKubeCommander(c client.Client, namespace, pod string) *KubeCommander {
 		namespace: namespace,
 		pod:       pod,
 		container: "docker-cli",
+		ctx:       context.Background(),
+		exec:      k8s.ExecCmd,
 	}
 }
 
@@ -55,6 +78,19 @@ func (k *KubeCommander) GenerateKey(role string) (*ExecResult, error) {
 	return k.excute(strings.Join(command, " "))
 }
 
+// AddSigner adds the delegation with its public key to the repository
+func (k *KubeCommander) AddSigner(name, repository string) (*ExecResult, error) {
+	command := []string{"docker", "trust", "signer", "add", "--key", path.Join(BaseDir, name+PublicKeyFileExt), name, repository}
+	return k.excute(strings.Join(command, " "))
+}
+
+// InspectTrust returns signers and keys of the repository as json.
+// The output is empty if the repository does not exist yet, which 'docker trust inspect' fails for
+func (k *KubeCommander) InspectTrust(repository string) (*ExecResult, error) {
+	command := []string{"docker", "trust", "inspect", repository, "2>/dev/null", "||", "true"}
+	return k.excute(strings.Join(command, " "))
+}
+
 // ListKey returns key list in /root/.docker/trust/private directory
 func (k *KubeCommander) ListKey() (*ExecResult, error) {
 	command := []string{"ls", "--color=never", PrivateKeyDir}
@@ -67,6 +103,23 @@ func (k *KubeCommander) ReadKey(name string) (*ExecResult, error) {
 	return k.excute(strings.Join(command, " "))
 }
 
+// WriteFile stores the contents in dir/name, which only the owner can read. The contents are given through stdin
+// of the command, so that they are not in arguments of any process of the pod
+func (k *KubeCommander) WriteFile(dir, name string, contents []byte) (*ExecResult, error) {
+	command := []string{"mkdir", "-p", dir, "&&", "umask", "077", "&&", "cat", ">", path.Join(dir, name)}
+	return k.excuteWithInput(strings.Join(command, " "), bytes.NewReader(contents))
+}
+
+// WriteEnv stores the variables in SessionEnvFile, which the following commands source
+func (k *KubeCommander) WriteEnv(env map[string]string) (*ExecResult, error) {
+	res, err := k.WriteFile(path.Dir(SessionEnvFile), path.Base(SessionEnvFile), envFileContents(env))
+	if err != nil {
+		return nil, err
+	}
+	k.envFile = SessionEnvFile
+	return res, nil
+}
+
 // LoadImageTar loads tar image
 func (k *KubeCommander) LoadImageTar(path string) (*ExecResult, error) {
 	command := []string{"docker", "load", "<", path}
@@ -91,11 +144,64 @@ func (k *KubeCommander) ListImageId() (*ExecResult, error) {
 	return k.excute(strings.Join(command, " "))
 }
 
+// envFileContents returns a shell script which exports the variables
+func envFileContents(env map[string]string) []byte {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	contents := &bytes.Buffer{}
+	for _, name := range names {
+		contents.WriteString("export " + name + "='" + strings.Replace(env[name], "'", `'\''`, -1) + "'\n")
+	}
+	return contents.Bytes()
+}
+
+// commandName returns the name of the command for spans, without its arguments which may have keys or passphrases
+// (e.g., 'docker trust sign' of 'docker trust sign <image>')
+func commandName(command string) string {
+	name := []string{}
+	for _, f := range strings.Fields(command) {
+		if len(name) == 0 && strings.Contains(f, "=") {
+			continue
+		}
+		if strings.HasPrefix(f, "-") || strings.ContainsAny(f, "/=<>|&;:'\"") {
+			break
+		}
+		name = append(name, f)
+
+		// docker <command>, docker trust <command>
+		if name[0] != "docker" || len(name) == 3 || (len(name) == 2 && name[1] != "trust") {
+			break
+		}
+	}
+	return strings.Join(name, " ")
+}
+
 func (k *KubeCommander) excute(command string) (*ExecResult, error) {
+	return k.excuteWithInput(command, nil)
+}
+
+func (k *KubeCommander) excuteWithInput(command string, stdin io.Reader) (*ExecResult, error) {
+	_, span := tracing.Start(k.ctx, "KubeCommander.exec",
+		tracing.AttributePod.String(k.pod),
+		tracing.AttributeNamespace.String(k.namespace),
+		tracing.AttributeCommand.String(commandName(command)),
+	)
+
+	if len(k.envFile) > 0 {
+		command = ". " + k.envFile + "; " + command
+	}
+
 	res := &ExecResult{Outbuf: &bytes.Buffer{}, Errbuf: &bytes.Buffer{}}
-	if err := k8s.ExecCmd(k.pod, k.container, k.namespace, command, nil, res.Outbuf, res.Errbuf); err != nil {
+	if err := k.exec(k.pod, k.container, k.namespace, command, stdin, res.Outbuf, res.Errbuf); err != nil {
+		k.failed = true
+		tracing.End(span, err)
 		return nil, err
 	}
+	tracing.End(span, nil)
 
 	return res, nil
 }
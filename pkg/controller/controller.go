@@ -2,8 +2,10 @@ package controller
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"path"
+	"regexp"
 	"strings"
 	"time"
 
@@ -11,10 +13,15 @@ import (
 	apiv1 "github.com/tmax-cloud/image-signing-operator/api/v1"
 	"github.com/tmax-cloud/image-signing-operator/internal/schemes"
 	"github.com/tmax-cloud/image-signing-operator/internal/utils"
+	"github.com/tmax-cloud/image-signing-operator/pkg/hsm"
+	"github.com/tmax-cloud/image-signing-operator/pkg/metrics"
+	"github.com/tmax-cloud/image-signing-operator/pkg/reference"
 	"github.com/tmax-cloud/image-signing-operator/pkg/registry"
+	"github.com/tmax-cloud/image-signing-operator/pkg/tracing"
 	"github.com/tmax-cloud/image-signing-operator/pkg/trust"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -23,57 +30,179 @@ import (
 
 var log logr.Logger = ctrl.Log.WithName("signing-controller")
 
+var digestRegexp = regexp.MustCompile(`digest: (sha256:[a-f0-9]{64})`)
+
 type CommandOpt struct {
-	RootKey                                      *apiv1.TrustKey
-	TargetKey                                    *apiv1.TrustKey
-	RegistryLoginSecret, RegistryLoginCertSecret string
-	ImagePvc                                     string
+	RootKey                 *apiv1.TrustKey
+	TargetKey               *apiv1.TrustKey
+	RegistryLoginCertSecret string
+	ImagePvc                string
+	// RegistryCredential is stored in /root/.docker/config.json after the pod is started.
+	// The image is pushed anonymously if it is nil
+	RegistryCredential *registry.Credential
+	// RegistryCerts are mounted in /etc/docker/certs.d/<RegistryHost>, and the host is an insecure registry of docker daemon
+	// if RegistryTLS skips verifying its certificate
+	RegistryHost  string
+	RegistryTLS   *registry.TLS
+	RegistryCerts *schemes.SecretFiles
+	// DelegationKey is stored with its public key as <DelegationName>.pub, if it exists.
+	// It is re-encrypted by the passphrase of TargetKey, which docker uses for delegation keys
+	DelegationName string
+	DelegationKey  *apiv1.TrustKey
+	// SnapshotKey shares the repository passphrase with TargetKey
+	SnapshotKey *apiv1.TrustKey
+	// NotaryServer is used instead of the default server derived from the registry
+	NotaryServer *trust.NotaryServer
 }
 
 // NewSigningController is a controller for image signing.
-// if registryName or registryNamespace is empty string, RegCtl is nil
+// if resolver is nil, images cannot be signed (e.g., for creating keys)
 // if requestNamespace is empty string, get operator's namepsace
-func NewSigningController(c client.Client, signer *apiv1.ImageSigner, registryName, registryNamespace, requestNamespace string) *SigningController {
+func NewSigningController(c client.Client, signer apiv1.Signer, resolver registry.Resolver, requestNamespace string) *SigningController {
 	return &SigningController{
+		Log:         log.WithValues("signer", path.Join(signer.SignerKind(), signer.GetNamespace(), signer.GetName())),
 		ImageSigner: signer,
-		Cmder:       NewKubeCommander(c, requestNamespace, "image-signing-by-"+signer.Name+"-"+utils.RandomString(10)),
-		Regctl:      registry.NewRegCtl(c, registryName, registryNamespace),
+		Cmder:       NewKubeCommander(c, requestNamespace, "image-signing-by-"+signer.GetName()+"-"+utils.RandomString(10)),
+		Registry:    resolver,
 	}
 }
 
 type SigningController struct {
-	ImageSigner *apiv1.ImageSigner
+	ImageSigner apiv1.Signer
 	Cmder       *KubeCommander
-	Regctl      *registry.RegCtl
+	Registry    registry.Resolver
 	startedPod  *corev1.Pod
 	IsRunnging  bool
+	// Pool leases a pre-started pod instead of creating a new one, if the signer has a worker pool
+	Pool     *WorkerPool
+	worker   *worker
+	template *workerTemplate
+	// Step is the current step of the session
+	Step string
+	// OnEvent is called when a step of the session is done
+	OnEvent func(reason, message string)
+	// Context is the parent of spans of the session, e.g., the span of the reconcile
+	Context context.Context
+	// Log has fields of the session, e.g., the signer and the request
+	Log logr.Logger
+	// secrets are passphrases of the session, which are redacted from logs
+	secrets []string
 }
 
-func storeFileShellCommand(filename, contents string) string {
-	cmd := []string{"echo", "\"" + contents + "\"", ">", path.Join(PrivateKeyDir, filename)}
-
-	return strings.Join(cmd, " ")
+// sessionFile is a file of the session (e.g., a private key), which is stored in the pod through stdin of a command
+type sessionFile struct {
+	dir, name string
+	contents  []byte
 }
 
-func (c *SigningController) Start(cmdOpt *CommandOpt) error {
-	lifeCycleCmds := []string{}
+// sessionSetup returns environment variables, files and dockerd arguments of the session
+func sessionSetup(cmdOpt *CommandOpt) (map[string]string, []sessionFile, []string, error) {
+	files := []sessionFile{}
 	envs := map[string]string{}
 
-	addEnvAndCmd := func(trustKey *apiv1.TrustKey, roleName trust.RoleType) {
+	addEnvAndFile := func(trustKey *apiv1.TrustKey, roleName trust.RoleType) {
 		if trustKey != nil {
 			if len(trustKey.PassPhrase) > 0 {
 				envs[trust.RoleMap[roleName]] = trustKey.PassPhrase
 			}
 			if len(trustKey.ID) > 0 && len(trustKey.Key) > 0 {
-				lifeCycleCmds = append(lifeCycleCmds, storeFileShellCommand(trustKey.ID, trustKey.Key))
+				files = append(files, sessionFile{PrivateKeyDir, trustKey.ID, []byte(trustKey.Key)})
 			}
 		}
 	}
 
-	addEnvAndCmd(cmdOpt.RootKey, trust.TrustRoleRoot)
-	addEnvAndCmd(cmdOpt.TargetKey, trust.TrustRoleTarget)
-	if len(cmdOpt.RegistryLoginSecret) > 0 {
-		lifeCycleCmds = append(lifeCycleCmds, "cp /home/dockremap/.dockerconfigjson /root/.docker/config.json")
+	addEnvAndFile(cmdOpt.RootKey, trust.TrustRoleRoot)
+	addEnvAndFile(cmdOpt.TargetKey, trust.TrustRoleTarget)
+	if key := cmdOpt.SnapshotKey; key != nil && len(key.Key) > 0 {
+		files = append(files, sessionFile{PrivateKeyDir, key.ID, []byte(key.Key)})
+	}
+	if key := cmdOpt.DelegationKey; key != nil && len(key.Key) > 0 {
+		contents, err := delegationKeyFile(key, cmdOpt.DelegationName, cmdOpt.TargetKey)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		files = append(files,
+			sessionFile{PrivateKeyDir, key.ID, []byte(contents)},
+			sessionFile{BaseDir, cmdOpt.DelegationName + PublicKeyFileExt, []byte(key.PublicKey)},
+		)
+	}
+	if server := cmdOpt.NotaryServer; server != nil {
+		envs[trust.DctEnvServer] = server.URL
+		if len(server.CA) > 0 {
+			host, err := server.Host()
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			files = append(files, sessionFile{path.Join(TLSDir, host), NotaryCAKey, server.CA})
+		}
+	}
+
+	dockerdArgs := []string{}
+	if t := cmdOpt.RegistryTLS; t != nil && t.InsecureSkipVerify && len(cmdOpt.RegistryHost) > 0 {
+		dockerdArgs = append(dockerdArgs, insecureOption, cmdOpt.RegistryHost)
+	}
+
+	return envs, files, dockerdArgs, nil
+}
+
+// delegationKeyFile returns the delegation key encrypted by the repository passphrase of the target key.
+// docker unlocks delegation keys by the repository passphrase, the same as target and snapshot keys
+func delegationKeyFile(key *apiv1.TrustKey, name string, targetKey *apiv1.TrustKey) (string, error) {
+	if targetKey == nil || len(targetKey.PassPhrase) == 0 {
+		return "", fmt.Errorf("delegation %s requires the passphrase of the target key", name)
+	}
+	if key.PassPhrase == targetKey.PassPhrase {
+		return key.Key, nil
+	}
+
+	priv, err := trust.ParsePrivateKey(key.ID, []byte(key.Key), key.PassPhrase, trust.RoleType(name))
+	if err != nil {
+		return "", err
+	}
+	contents, err := trust.EncryptPrivateKey(priv, targetKey.PassPhrase, trust.RoleType(name), "")
+	if err != nil {
+		return "", err
+	}
+	return string(contents), nil
+}
+
+func (c *SigningController) Start(cmdOpt *CommandOpt) (err error) {
+	if c.Context == nil {
+		c.Context = context.Background()
+	}
+	pooled := c.Pool != nil && c.ImageSigner.SignerSpec().WorkerPool != nil
+	ctx, span := tracing.Start(c.Context, "SigningController.Start",
+		tracing.AttributeSigner.String(metrics.SignerLabel(c.ImageSigner)),
+		tracing.AttributePooled.Bool(pooled),
+	)
+	// commands of the start (e.g., loading keys into a pooled pod) are traced under its span
+	c.Cmder.ctx = ctx
+	defer func() {
+		span.SetAttributes(tracing.AttributePod.String(c.Cmder.pod))
+		tracing.End(span, err)
+		c.Cmder.ctx = c.Context
+	}()
+
+	c.Step = StepStartWorker
+	envs, files, dockerdArgs, err := sessionSetup(cmdOpt)
+	if err != nil {
+		return err
+	}
+	for name, value := range envs {
+		if isPassphraseEnv(name) {
+			c.secrets = append(c.secrets, value)
+		}
+	}
+
+	if pooled {
+		if err := c.startWorker(cmdOpt, dockerdArgs); err != nil {
+			return err
+		}
+		if err := c.loadSession(envs, files, cmdOpt.RegistryCredential); err != nil {
+			return err
+		}
+		c.event(EventReasonWorkerStarted, fmt.Sprintf("pooled pod %s/%s is leased", c.startedPod.Namespace, c.startedPod.Name))
+		return nil
 	}
 
 	c.startedPod = schemes.NewDindPod(
@@ -81,13 +210,14 @@ func (c *SigningController) Start(cmdOpt *CommandOpt) error {
 		c.Cmder.pod,
 		c.Cmder.container,
 		"",
-		schemes.WithEnv(envs),
 		schemes.WithPvc(cmdOpt.ImagePvc),
-		schemes.WithDcjSecret(cmdOpt.RegistryLoginSecret),
 		schemes.WithCertSecret(cmdOpt.RegistryLoginCertSecret),
-		schemes.WithLifeCycle(lifeCycleCmds),
+		schemes.WithSecretFiles(certsVolume, cmdOpt.RegistryCerts),
+		schemes.WithLifeCycle(nil),
+		schemes.WithArgs(dockerdArgs),
 	)
 
+	start := time.Now()
 	if err := c.Cmder.client.Create(context.TODO(), c.startedPod); err != nil {
 		if errors.IsAlreadyExists(err) {
 			return nil
@@ -95,169 +225,435 @@ func (c *SigningController) Start(cmdOpt *CommandOpt) error {
 		return err
 	}
 
+	if err := waitForRunning(c.Cmder.client, c.startedPod); err != nil {
+		return err
+	}
+	c.IsRunnging = true
+	metrics.ObserveWorkerStartup(false, start)
+	if err := c.loadSession(envs, files, cmdOpt.RegistryCredential); err != nil {
+		return err
+	}
+	c.event(EventReasonWorkerStarted, fmt.Sprintf("pod %s/%s is running", c.startedPod.Namespace, c.startedPod.Name))
+
+	return nil
+}
+
+// startWorker leases a pod from the pool
+func (c *SigningController) startWorker(cmdOpt *CommandOpt, dockerdArgs []string) error {
+	template := &workerTemplate{
+		signer:     c.ImageSigner,
+		namespace:  c.Cmder.namespace,
+		pvc:        cmdOpt.ImagePvc,
+		certSecret: cmdOpt.RegistryLoginCertSecret,
+		certs:      cmdOpt.RegistryCerts,
+		args:       dockerdArgs,
+	}
+	start := time.Now()
+	w, err := c.Pool.lease(template)
+	if err != nil {
+		return err
+	}
+	metrics.ObserveWorkerStartup(true, start)
+
+	c.worker, c.template = w, template
+	c.startedPod = w.pod
+	c.Cmder.pod = w.pod.Name
+	c.IsRunnging = true
+	c.Log.Info("worker leased", "pod/namespace", w.pod.Name+"/"+w.pod.Namespace, "uses", w.uses)
+
+	if _, err := c.Cmder.excute("mkdir -p " + PrivateKeyDir); err != nil {
+		return fmt.Errorf("cannot prepare worker %s: %v", w.pod.Name, err)
+	}
+
+	return nil
+}
+
+// loadSession stores passphrases, keys, certs and the registry credential of the session in the started pod,
+// the same for pooled and new pods. They are given through stdin of commands,
+// so that they are neither in the pod spec nor in arguments of the commands
+func (c *SigningController) loadSession(envs map[string]string, files []sessionFile, cred *registry.Credential) error {
+	if _, err := c.Cmder.WriteEnv(envs); err != nil {
+		return fmt.Errorf("cannot store passphrases: %v", err)
+	}
+	for _, f := range files {
+		if _, err := c.Cmder.WriteFile(f.dir, f.name, f.contents); err != nil {
+			return fmt.Errorf("cannot store %s: %v", path.Join(f.dir, f.name), err)
+		}
+	}
+	return c.storeRegistryCredential(cred)
+}
+
+// storeRegistryCredential stores docker config.json of the credential through the exec channel,
+// so that the credential is neither in the pod spec nor in arguments of the commands
+func (c *SigningController) storeRegistryCredential(cred *registry.Credential) error {
+	if cred == nil {
+		return nil
+	}
+	c.secrets = append(c.secrets, cred.Password)
+
+	config, err := cred.DockerConfig()
+	if err != nil {
+		return err
+	}
+	if _, err := c.Cmder.WriteFile(DockerConfigDir, DockerConfigFile, config); err != nil {
+		return fmt.Errorf("cannot store registry credential: %v", err)
+	}
+	return nil
+}
+
+func waitForRunning(c client.Client, pod *corev1.Pod) error {
 	const MaxRetryCount = 60
 	for cnt := 0; cnt < MaxRetryCount; cnt++ {
-		if err := c.Cmder.client.Get(context.TODO(), client.ObjectKey{Name: c.Cmder.pod, Namespace: c.Cmder.namespace}, c.startedPod); err != nil {
+		if err := c.Get(context.TODO(), client.ObjectKey{Name: pod.Name, Namespace: pod.Namespace}, pod); err != nil {
 			return err
 		}
-		if c.startedPod.Status.Phase == corev1.PodRunning {
-			c.IsRunnging = true
-			break
+		if pod.Status.Phase == corev1.PodRunning {
+			return nil
 		}
 		time.Sleep(1 * time.Second)
 	}
 
-	if !c.IsRunnging {
-		return fmt.Errorf("pod is not running")
-	}
-
-	return nil
+	return fmt.Errorf("pod is not running")
 }
 
 func (c *SigningController) Close() error {
+	if c.worker != nil {
+		return c.releaseWorker()
+	}
+
 	if err := c.Cmder.client.Delete(context.TODO(), c.startedPod); err != nil {
 		return err
 	}
-	log.Info("dind closed", "pod/namespace", c.startedPod.Name+"/"+c.startedPod.Namespace)
+	c.Log.Info("dind closed", "pod/namespace", c.startedPod.Name+"/"+c.startedPod.Namespace)
 
 	return nil
 }
 
+// releaseWorker wipes keys, files and images of the session, and returns the pod to the pool.
+// The pod is replaced if any command of the session failed
+func (c *SigningController) releaseWorker() error {
+	w := c.worker
+	c.worker = nil
+	c.Cmder.envFile = ""
+
+	_, err := c.Cmder.excute(wipeWorkerCommand)
+	recycled := c.Pool.release(c.template, w, c.Cmder.failed)
+	c.Log.Info("worker released", "pod/namespace", w.pod.Name+"/"+w.pod.Namespace, "recycled", recycled)
+
+	return err
+}
+
 func (c *SigningController) readTrustKey(phrase trust.TrustPass, roleName trust.RoleType) (*apiv1.TrustKey, error) {
-	log.Info("list key")
-	out, err := c.Cmder.ListKey()
+	id, contents, err := c.findKeyFile(func(contents string) bool {
+		return trust.HasRole([]byte(contents), roleName)
+	})
 	if err != nil {
-		log.Error(err, "list_key_err")
 		return nil, err
 	}
-	log.Info("list key suceess", "stdout", out.Outbuf.String(), "stderr", out.Errbuf.String())
 
-	keys := strings.Fields(out.Outbuf.String())
-	keyFileFound := false
-	trustKey := &apiv1.TrustKey{}
+	now := metav1.Now()
+	return &apiv1.TrustKey{
+		ID:         id,
+		Key:        contents,
+		PassPhrase: phrase[trust.RoleMap[roleName]],
+		CreatedAt:  &now,
+	}, nil
+}
 
+// findKeyFile returns the first key file in /root/.docker/trust/private which matches
+func (c *SigningController) findKeyFile(match func(contents string) bool) (string, string, error) {
+	c.Log.Info("list key")
+	out, err := c.Cmder.ListKey()
+	if err != nil {
+		c.Log.Error(err, "list_key_err")
+		return "", "", err
+	}
+	c.logOutput("list key suceess", out)
+
+	keys := strings.Fields(out.Outbuf.String())
 	for _, key := range keys {
-		log.Info("private key", "key", key)
+		c.Log.Info("private key", "key", key)
 		readKeyOut, err := c.Cmder.ReadKey(key)
 		if err != nil {
-			log.Error(err, "")
-			return nil, err
+			c.Log.Error(err, "")
+			return "", "", err
 		}
-		if strings.Contains(readKeyOut.Outbuf.String(), "role: "+string(roleName)) {
-			trustKey.ID = key
-			trustKey.Key = readKeyOut.Outbuf.String()
-			trustKey.PassPhrase = phrase[trust.RoleMap[roleName]]
-			keyFileFound = true
-			break
+		if match(readKeyOut.Outbuf.String()) {
+			return key, readKeyOut.Outbuf.String(), nil
 		}
 	}
 
-	if !keyFileFound {
-		return nil, fmt.Errorf("key file not found")
-	}
-
-	return trustKey, nil
+	return "", "", fmt.Errorf("key file not found")
 }
 
-func (c *SigningController) CreateRootKey(phrase trust.TrustPass, owner *apiv1.ImageSigner, scheme *runtime.Scheme) (*apiv1.TrustKey, error) {
-	log.Info("generate key")
+func (c *SigningController) CreateRootKey(phrase trust.TrustPass, owner apiv1.Signer, scheme *runtime.Scheme) (*apiv1.TrustKey, error) {
+	c.Log.Info("generate key")
 	out, err := c.Cmder.GenerateKey(string(trust.TrustRoleRoot))
 	if err != nil {
-		log.Error(err, "generate key err")
+		c.Log.Error(err, "generate key err")
 		return nil, err
 	}
-	log.Info("generate key success", "stdout", out.Outbuf.String(), "stderr", out.Errbuf.String())
+	c.logOutput("generate key success", out)
 
 	rootKey, err := c.readTrustKey(phrase, trust.TrustRoleRoot)
 	if err != nil {
-		log.Error(err, "read key err")
+		c.Log.Error(err, "read key err")
 		return nil, err
 	}
 
-	log.Info("create root key")
+	c.Log.Info("create root key")
 	if err := c.createRootKey(owner, scheme, rootKey); err != nil {
-		log.Error(err, "")
+		c.Log.Error(err, "")
 		return nil, err
 	}
 
-	log.Info("create root key success")
+	c.Log.Info("create root key success")
+	metrics.KeyGenerations.WithLabelValues(metrics.SignerLabel(owner), string(trust.TrustRoleRoot)).Inc()
 	return rootKey, nil
 }
 
-func (c *SigningController) AddTargetKey(originalKey *apiv1.SignerKey, targetName string, phrase trust.TrustPass) error {
+// AddTargetKey stores the target key created by signing, and returns the key
+func (c *SigningController) AddTargetKey(originalKey apiv1.Key, targetName string, phrase trust.TrustPass) (*apiv1.TrustKey, error) {
+	c.Step = StepAddTargetKey
 	targetKey, err := c.readTrustKey(phrase, trust.TrustRoleTarget)
 	if err != nil {
-		log.Error(err, "read key error")
-		return err
+		c.Log.Error(err, "read key error")
+		return nil, err
+	}
+
+	target := originalKey.DeepCopyObject().(apiv1.Key)
+	originObject := client.MergeFrom(originalKey)
+
+	if target.KeySpec().Targets == nil {
+		target.KeySpec().Targets = map[string]apiv1.TrustKey{}
+	}
+	target.KeySpec().Targets[targetName] = *targetKey
+
+	if err := c.Cmder.client.Patch(context.TODO(), target, originObject); err != nil {
+		c.Log.Error(err, "patch error")
+		return nil, err
+	}
+	metrics.KeyGenerations.WithLabelValues(metrics.SignerLabel(c.ImageSigner), string(trust.TrustRoleTarget)).Inc()
+
+	return targetKey, nil
+}
+
+// CreateDelegationKey generates a new key of the delegation with a new passphrase.
+// The key is generated by the operator, so that the passphrase is not given to any command of the pod
+func (c *SigningController) CreateDelegationKey(name string) (*apiv1.TrustKey, error) {
+	c.Step = StepCreateDelegationKey
+	c.Log.Info("generate delegation key", "delegation", name)
+	key, priv, err := loadOrGenerateKey(nil, name, "", trust.RoleType(name), "")
+	if err != nil {
+		c.Log.Error(err, "generate delegation key err")
+		return nil, err
+	}
+	c.secrets = append(c.secrets, key.PassPhrase)
+
+	// same as the public key written by 'docker trust key generate'
+	if key.PublicKey, err = hsm.PublicKeyPEM(priv.Public(), name); err != nil {
+		return nil, err
 	}
+	metrics.KeyGenerations.WithLabelValues(metrics.SignerLabel(c.ImageSigner), metrics.RoleDelegation).Inc()
+
+	return key, nil
+}
 
-	target := originalKey.DeepCopy()
+func (c *SigningController) AddDelegationKey(originalKey apiv1.Key, name string, delegationKey *apiv1.TrustKey) error {
+	target := originalKey.DeepCopyObject().(apiv1.Key)
 	originObject := client.MergeFrom(originalKey)
 
-	target.Spec.Targets[targetName] = *targetKey
+	if target.KeySpec().Delegations == nil {
+		target.KeySpec().Delegations = map[string]apiv1.TrustKey{}
+	}
+	target.KeySpec().Delegations[name] = *delegationKey
 
 	if err := c.Cmder.client.Patch(context.TODO(), target, originObject); err != nil {
-		log.Error(err, "patch error")
+		c.Log.Error(err, "patch error")
 		return err
 	}
 
 	return nil
 }
 
-func (c *SigningController) createRootKey(owner *apiv1.ImageSigner, scheme *runtime.Scheme, trustKey *apiv1.TrustKey) error {
-	key := schemes.SignerKey(c.ImageSigner)
+func (c *SigningController) createRootKey(owner apiv1.Signer, scheme *runtime.Scheme, trustKey *apiv1.TrustKey) error {
+	return createSignerKey(c.Cmder.client, owner, scheme, apiv1.SignerKeySpec{
+		Root: *trustKey,
+	})
+}
+
+func createSignerKey(c client.Client, owner apiv1.Signer, scheme *runtime.Scheme, spec apiv1.SignerKeySpec) error {
+	key := schemes.SignerKey(owner)
 	if err := controllerutil.SetOwnerReference(owner, key, scheme); err != nil {
 		return err
 	}
 
-	key.Spec = apiv1.SignerKeySpec{
-		Root: *trustKey,
-	}
+	*key.KeySpec() = spec
 
-	if err := c.Cmder.client.Create(context.TODO(), key); err != nil {
+	if err := c.Create(context.TODO(), key); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (c *SigningController) SignImage(imageName, imageTag string) error {
-	out, err := c.Cmder.LoadImageTar(path.Join(schemes.ImageMountPath, imageName+".tar"))
+// SignImage signs and pushes the image, and returns the digest of the pushed image
+func (c *SigningController) SignImage(ref *reference.Reference) (string, error) {
+	image, err := c.prepareImage(ref)
 	if err != nil {
-		log.Error(err, "load image error")
-		return err
+		return "", err
 	}
-	log.Info("load image", "stdout", out.Outbuf.String(), "stderr", out.Errbuf.String())
+
+	c.Log.Info("sign", "image name", image.String())
+	c.Step = StepSignImage
+	start := time.Now()
+	out, err := c.Cmder.Sign(image.String())
+	metrics.ObserveStep(metrics.StepSign, start)
+	if err != nil {
+		c.Log.Error(err, "sign error")
+		return "", err
+	}
+	c.logOutput("sign image", out)
+	c.event(EventReasonImageSigned, fmt.Sprintf("image %s is signed", image))
+
+	return pushedDigest(out.Outbuf.String()), nil
+}
+
+// SignImageWithDelegation adds the delegation to the repository if it does not have the key, and signs the image with the delegation key
+func (c *SigningController) SignImageWithDelegation(ref *reference.Reference, delegation string, delegationKey *apiv1.TrustKey) (string, error) {
+	image, err := c.prepareImage(ref)
+	if err != nil {
+		return "", err
+	}
+
+	repository := image.Name()
+	out, err := c.Cmder.InspectTrust(repository)
+	if err != nil {
+		c.Log.Error(err, "inspect trust error")
+		return "", err
+	}
+	if hasSigner(out.Outbuf.String(), delegation, strings.TrimSuffix(delegationKey.ID, trust.KeyFileExt)) {
+		c.Log.Info("signer exists", "delegation", delegation, "repository", repository)
+	} else {
+		c.Log.Info("add signer", "delegation", delegation, "repository", repository)
+		c.Step = StepAddSigner
+		start := time.Now()
+		out, err := c.Cmder.AddSigner(delegation, repository)
+		metrics.ObserveStep(metrics.StepAddSigner, start)
+		if err != nil {
+			c.Log.Error(err, "add signer error")
+			return "", err
+		}
+		c.logOutput("add signer", out)
+	}
+
+	c.Log.Info("sign", "image name", image.String(), "delegation", delegation)
+	c.Step = StepSignImage
+	start := time.Now()
+	out, err = c.Cmder.Sign(image.String())
+	metrics.ObserveStep(metrics.StepSign, start)
+	if err != nil {
+		c.Log.Error(err, "sign error")
+		return "", err
+	}
+	c.logOutput("sign image", out)
+	c.event(EventReasonImageSigned, fmt.Sprintf("image %s is signed by delegation %s", image, delegation))
+
+	return pushedDigest(out.Outbuf.String()), nil
+}
+
+// hasSigner returns true if the output of 'docker trust inspect' has the key of the signer.
+// Repositories which do not exist yet have no signers
+func hasSigner(inspectOutput, name, keyID string) bool {
+	repos := []struct {
+		Signers []struct {
+			Name string
+			Keys []struct {
+				ID string
+			}
+		}
+	}{}
+	if err := json.Unmarshal([]byte(inspectOutput), &repos); err != nil {
+		return false
+	}
+
+	for _, repo := range repos {
+		for _, signer := range repo.Signers {
+			if signer.Name != name {
+				continue
+			}
+			for _, key := range signer.Keys {
+				if key.ID == keyID {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// pushedDigest returns the digest in the push output of 'docker trust sign' (e.g., 'latest: digest: sha256:... size: 528')
+func pushedDigest(output string) string {
+	if m := digestRegexp.FindStringSubmatch(output); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// prepareImage loads the image and tags it to the registry, and returns the tagged image.
+// The image is loaded from <image path>.tar in the pvc
+func (c *SigningController) prepareImage(ref *reference.Reference) (*reference.Reference, error) {
+	// docker trust signs tags, not digests
+	if len(ref.Tag) == 0 {
+		return nil, fmt.Errorf("image %s should have a tag to be signed", ref)
+	}
+
+	c.Step = StepLoadImage
+	start := time.Now()
+	out, err := c.Cmder.LoadImageTar(path.Join(schemes.ImageMountPath, ref.Path+".tar"))
+	metrics.ObserveStep(metrics.StepLoad, start)
+	if err != nil {
+		c.Log.Error(err, "load image error")
+		return nil, err
+	}
+	c.logOutput("load image", out)
 
 	out, err = c.Cmder.ListImageId()
 	if err != nil {
-		log.Error(err, "list image id error")
-		return err
+		c.Log.Error(err, "list image id error")
+		return nil, err
 	}
-	log.Info("list image id", "stdout", out.Outbuf.String(), "stderr", out.Errbuf.String())
+	c.logOutput("list image id", out)
 
 	imageIds := strings.Fields(out.Outbuf.String())
 	if len(imageIds) == 0 {
-		return fmt.Errorf("image is not found")
+		return nil, fmt.Errorf("image is not found")
 	}
 
-	registry := c.Regctl.GetEndpoint()
-
-	image := path.Join(registry, imageName) + ":" + imageTag
-	out, err = c.Cmder.TagImage(imageIds[0], image)
+	if c.Registry == nil {
+		return nil, fmt.Errorf("registry of the image is not given")
+	}
+	repository, err := c.Registry.Repository(ref.Path)
 	if err != nil {
-		log.Error(err, "list image id error")
-		return err
+		return nil, err
+	}
+	image, err := reference.ParseRepository(repository)
+	if err != nil {
+		return nil, err
 	}
-	log.Info("tag image", "stdout", out.Outbuf.String(), "stderr", out.Errbuf.String())
+	image.Tag = ref.Tag
+
+	c.event(EventReasonImageLoaded, fmt.Sprintf("image %s is loaded", ref.Path))
 
-	log.Info("sign", "image name", image)
-	out, err = c.Cmder.Sign(image)
+	c.Step = StepTagImage
+	start = time.Now()
+	out, err = c.Cmder.TagImage(imageIds[0], image.String())
+	metrics.ObserveStep(metrics.StepTag, start)
 	if err != nil {
-		log.Error(err, "sign error")
-		return err
+		c.Log.Error(err, "list image id error")
+		return nil, err
 	}
-	log.Info("sign image", "stdout", out.Outbuf.String(), "stderr", out.Errbuf.String())
+	c.logOutput("tag image", out)
 
-	return nil
+	return image, nil
 }
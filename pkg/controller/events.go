@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+
+	apiv1 "github.com/tmax-cloud/image-signing-operator/api/v1"
+)
+
+// Reasons of events of signers, requests and signer keys
+const (
+	EventReasonRootKeyGenerated   = "RootKeyGenerated"
+	EventReasonKeysImported       = "KeysImported"
+	EventReasonKeyCreationFailed  = "KeyCreationFailed"
+	EventReasonWorkerStarted      = "WorkerStarted"
+	EventReasonImageLoaded        = "ImageLoaded"
+	EventReasonImageSigned        = "ImageSigned"
+	EventReasonTargetKeyAdded     = "TargetKeyAdded"
+	EventReasonDelegationKeyAdded = "DelegationKeyAdded"
+	EventReasonSignFailed         = "SignFailed"
+	EventReasonKeyRotated         = "KeyRotated"
+)
+
+// Steps of a signing session, which are shown in events of failed requests
+const (
+	StepValidate            = "validate request"
+	StepStartWorker         = "start worker"
+	StepLoadImage           = "load image"
+	StepTagImage            = "tag image"
+	StepAddSigner           = "add signer"
+	StepSignImage           = "sign image"
+	StepCreateDelegationKey = "create delegation key"
+	StepAddTargetKey        = "add target key"
+)
+
+// event notifies a progress of the session, if OnEvent is set
+func (c *SigningController) event(reason, message string) {
+	if c.OnEvent != nil {
+		c.OnEvent(reason, message)
+	}
+}
+
+// KeyRotations returns messages of keys of the signer key which are replaced by other keys, e.g., rotated by
+// 'docker trust key rotate' and updated in the signer key. Added and removed keys are not rotations
+func KeyRotations(old, new apiv1.Key) []string {
+	oldSpec, newSpec := old.KeySpec(), new.KeySpec()
+
+	messages := []string{}
+	if len(oldSpec.Root.ID) > 0 && len(newSpec.Root.ID) > 0 && oldSpec.Root.ID != newSpec.Root.ID {
+		messages = append(messages, fmt.Sprintf("root key is rotated from %s to %s", oldSpec.Root.ID, newSpec.Root.ID))
+	}
+	messages = append(messages, rotatedKeys("target", oldSpec.Targets, newSpec.Targets)...)
+	messages = append(messages, rotatedKeys("snapshot", oldSpec.Snapshots, newSpec.Snapshots)...)
+	messages = append(messages, rotatedKeys("delegation", oldSpec.Delegations, newSpec.Delegations)...)
+	return messages
+}
+
+func rotatedKeys(role string, old, new map[string]apiv1.TrustKey) []string {
+	names := []string{}
+	for name, key := range new {
+		if oldKey, ok := old[name]; ok && oldKey.ID != key.ID {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	messages := []string{}
+	for _, name := range names {
+		messages = append(messages, fmt.Sprintf("key of %s %s is rotated from %s to %s", role, name, old[name].ID, new[name].ID))
+	}
+	return messages
+}
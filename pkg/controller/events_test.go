@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	apiv1 "github.com/tmax-cloud/image-signing-operator/api/v1"
+)
+
+func TestKeyRotations(t *testing.T) {
+	old := &apiv1.SignerKey{Spec: apiv1.SignerKeySpec{
+		Root: apiv1.TrustKey{ID: "root1"},
+		Targets: map[string]apiv1.TrustKey{
+			"reg/team/app":   {ID: "app1"},
+			"reg/team/web":   {ID: "web1"},
+			"reg/team/batch": {ID: "batch1"},
+		},
+		Delegations: map[string]apiv1.TrustKey{"releases": {ID: "rel1"}},
+	}}
+
+	tc := map[string]struct {
+		spec     apiv1.SignerKeySpec
+		expected []string
+	}{
+		"unchanged": {
+			spec:     old.Spec,
+			expected: []string{},
+		},
+		"added": {
+			spec: apiv1.SignerKeySpec{
+				Root:        apiv1.TrustKey{ID: "root1"},
+				Targets:     map[string]apiv1.TrustKey{"reg/team/app": {ID: "app1"}, "reg/team/new": {ID: "new1"}},
+				Delegations: map[string]apiv1.TrustKey{"releases": {ID: "rel1"}, "team": {ID: "team1"}},
+			},
+			expected: []string{},
+		},
+		"rotated": {
+			spec: apiv1.SignerKeySpec{
+				Root: apiv1.TrustKey{ID: "root2"},
+				Targets: map[string]apiv1.TrustKey{
+					"reg/team/app":   {ID: "app1"},
+					"reg/team/web":   {ID: "web2"},
+					"reg/team/batch": {ID: "batch2"},
+				},
+				Delegations: map[string]apiv1.TrustKey{"releases": {ID: "rel2"}},
+			},
+			expected: []string{
+				"root key is rotated from root1 to root2",
+				"key of target reg/team/batch is rotated from batch1 to batch2",
+				"key of target reg/team/web is rotated from web1 to web2",
+				"key of delegation releases is rotated from rel1 to rel2",
+			},
+		},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			got := KeyRotations(old, &apiv1.SignerKey{Spec: c.spec})
+			if !reflect.DeepEqual(got, c.expected) {
+				t.Errorf("expected %v, got %v", c.expected, got)
+			}
+		})
+	}
+}
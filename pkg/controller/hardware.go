@@ -0,0 +1,245 @@
+package controller
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+
+	apiv1 "github.com/tmax-cloud/image-signing-operator/api/v1"
+	"github.com/tmax-cloud/image-signing-operator/pkg/hsm"
+	"github.com/tmax-cloud/image-signing-operator/pkg/trust"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	DefaultPinKey = "pin"
+)
+
+// ImportHardwareKey creates the signer key with the public key of the root key in the signer's PKCS#11 token.
+// The private key never leaves the token, so the signer key does not have it.
+// Target keys in the signer's key secret are imported together, if it is given
+func ImportHardwareKey(c client.Client, signer apiv1.Signer, scheme *runtime.Scheme) (*apiv1.TrustKey, error) {
+	src := signer.SignerSpec().HardwareKey
+
+	key, err := openHardwareKey(c, signer)
+	if err != nil {
+		return nil, err
+	}
+	defer key.Close()
+
+	id, err := trust.KeyID(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	pub, err := hsm.PublicKeyPEM(key.Public(), string(trust.TrustRoleRoot))
+	if err != nil {
+		return nil, err
+	}
+
+	rootKey := &apiv1.TrustKey{
+		ID:        id + trust.KeyFileExt,
+		PublicKey: pub,
+	}
+
+	// Target keys of the repositories which are already initialized with the root key
+	targets, snapshots := map[string]apiv1.TrustKey{}, map[string]apiv1.TrustKey{}
+	if keySecret := signer.SignerSpec().KeySecret; keySecret != nil {
+		secret, err := getKeySecret(c, signer, keySecret)
+		if err != nil {
+			return nil, err
+		}
+		targets, snapshots, err = readTargetKeys(secret, keySecret)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	log.Info("import hardware key", "module", src.ModulePath, "label", src.Label, "rootKeyId", rootKey.ID, "targets", len(targets), "snapshots", len(snapshots))
+	if err := createSignerKey(c, signer, scheme, apiv1.SignerKeySpec{
+		Root:      *rootKey,
+		Targets:   targets,
+		Snapshots: snapshots,
+	}); err != nil {
+		return nil, err
+	}
+
+	return rootKey, nil
+}
+
+// PrepareHardwareRepository returns the target key (and the snapshot key, if the signer manages snapshot keys) of the repository (gun).
+// If the repository does not exist in the notary server, new keys are stored in the signer key, and true is returned
+// so that the repository is initialized by InitHardwareRepository, as dind cannot use the root key in the token
+func PrepareHardwareRepository(c client.Client, signer apiv1.Signer, signerKey apiv1.Key, notary *trust.NotaryServer, gun, targetName string) (*apiv1.TrustKey, *apiv1.TrustKey, bool, error) {
+	spec := signerKey.KeySpec()
+	signerSnapshot := signer.SignerSpec().SnapshotKeyManagement == apiv1.SnapshotKeyManagementSigner
+
+	exists := notary == nil
+	if notary != nil {
+		var err error
+		if exists, err = notary.RepositoryExists(gun); err != nil {
+			return nil, nil, false, err
+		}
+	}
+
+	// The repository is already initialized with its keys
+	if exists {
+		targetKey, ok := spec.Targets[targetName]
+		if !ok {
+			return nil, nil, false, fmt.Errorf("root key of %s %s is in a hardware token, so the repository %s should be initialized with its target key in advance, or the notary server should be configured", signer.SignerKind(), signer.GetName(), gun)
+		}
+		if !signerSnapshot {
+			return &targetKey, nil, false, nil
+		}
+		snapshotKey, ok := spec.Snapshots[targetName]
+		if !ok {
+			return nil, nil, false, fmt.Errorf("%s %s manages snapshot keys, but there is no snapshot key of %s. Import it by keySecret.snapshots", signer.SignerKind(), signer.GetName(), targetName)
+		}
+		// docker unlocks both target and snapshot keys by the repository passphrase
+		if snapshotKey.PassPhrase != targetKey.PassPhrase {
+			return nil, nil, false, fmt.Errorf("snapshot key of %s should have the same passphrase as its target key", targetName)
+		}
+		return &targetKey, &snapshotKey, false, nil
+	}
+
+	target := signerKey.DeepCopyObject().(apiv1.Key)
+	spec = target.KeySpec()
+
+	// docker unlocks both target and snapshot keys by the repository passphrase
+	passphrase := ""
+	if key, ok := spec.Snapshots[targetName]; ok && signerSnapshot {
+		passphrase = key.PassPhrase
+	}
+	targetKey, _, err := loadOrGenerateKey(spec.Targets, targetName, passphrase, trust.TrustRoleTarget, gun)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	var snapshotKey *apiv1.TrustKey
+	if signerSnapshot {
+		snapshotKey, _, err = loadOrGenerateKey(spec.Snapshots, targetName, targetKey.PassPhrase, trust.TrustRoleSnapshot, gun)
+		if err != nil {
+			return nil, nil, false, err
+		}
+	}
+
+	if spec.Targets == nil {
+		spec.Targets = map[string]apiv1.TrustKey{}
+	}
+	spec.Targets[targetName] = *targetKey
+	if snapshotKey != nil {
+		if spec.Snapshots == nil {
+			spec.Snapshots = map[string]apiv1.TrustKey{}
+		}
+		spec.Snapshots[targetName] = *snapshotKey
+	}
+	if err := c.Patch(context.TODO(), target, client.MergeFrom(signerKey)); err != nil {
+		return nil, nil, false, err
+	}
+
+	return targetKey, snapshotKey, true, nil
+}
+
+// InitHardwareRepository initializes the repository (gun) in the notary server with the root key in the signer's token,
+// and the keys returned by PrepareHardwareRepository. The repository should be locked by the session
+func InitHardwareRepository(c client.Client, signer apiv1.Signer, notary *trust.NotaryServer, gun string, targetKey, snapshotKey *apiv1.TrustKey) error {
+	// another session may have initialized the repository before the lock
+	exists, err := notary.RepositoryExists(gun)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("repository %s is initialized by another session, try again", gun)
+	}
+
+	keys := &trust.RepositoryKeys{}
+	if keys.Targets, err = trust.ParsePrivateKey(targetKey.ID, []byte(targetKey.Key), targetKey.PassPhrase, trust.TrustRoleTarget); err != nil {
+		return err
+	}
+	if snapshotKey != nil {
+		if keys.Snapshot, err = trust.ParsePrivateKey(snapshotKey.ID, []byte(snapshotKey.Key), snapshotKey.PassPhrase, trust.TrustRoleSnapshot); err != nil {
+			return err
+		}
+	}
+
+	root, err := openHardwareKey(c, signer)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+	keys.Root = root
+
+	log.Info("initialize repository with hardware root key", "gun", gun, "targetKeyId", targetKey.ID)
+	return notary.InitRepository(gun, keys)
+}
+
+// loadOrGenerateKey returns the key of the target in keys, or generates a new ECDSA key.
+// New keys are encrypted by the passphrase, or by a new passphrase if it is empty
+func loadOrGenerateKey(keys map[string]apiv1.TrustKey, targetName, passphrase string, role trust.RoleType, gun string) (*apiv1.TrustKey, crypto.Signer, error) {
+	if key, ok := keys[targetName]; ok {
+		signer, err := trust.ParsePrivateKey(key.ID, []byte(key.Key), key.PassPhrase, role)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &key, signer, nil
+	}
+
+	if len(passphrase) == 0 {
+		phrase := trust.NewTrustPass()
+		phrase.AssignNewTargetPass()
+		passphrase = phrase[trust.DctEnvKeyTarget]
+	}
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	id, err := trust.KeyID(priv.Public())
+	if err != nil {
+		return nil, nil, err
+	}
+	contents, err := trust.EncryptPrivateKey(priv, passphrase, role, gun)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := metav1.Now()
+	return &apiv1.TrustKey{
+		ID:         id + trust.KeyFileExt,
+		Key:        string(contents),
+		PassPhrase: passphrase,
+		CreatedAt:  &now,
+	}, priv, nil
+}
+
+// openHardwareKey logs in to the signer's token with the PIN in the PIN secret
+func openHardwareKey(c client.Client, signer apiv1.Signer) (hsm.Key, error) {
+	src := signer.SignerSpec().HardwareKey
+	if src == nil {
+		return nil, fmt.Errorf("%s %s has no hardware key", signer.SignerKind(), signer.GetName())
+	}
+
+	namespace := signerSecretNamespace(signer)
+	secret := &corev1.Secret{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: src.PinSecret.Name, Namespace: namespace}, secret); err != nil {
+		return nil, err
+	}
+	pin, ok := secret.Data[valueOrDefault(src.PinSecret.Key, DefaultPinKey)]
+	if !ok {
+		return nil, fmt.Errorf("there is no PIN in secret %s/%s", namespace, src.PinSecret.Name)
+	}
+
+	return hsm.Open(&hsm.Config{
+		ModulePath: src.ModulePath,
+		Slot:       src.Slot,
+		TokenLabel: src.TokenLabel,
+		KeyLabel:   src.Label,
+		Pin:        string(pin),
+	})
+}
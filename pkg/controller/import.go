@@ -0,0 +1,147 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	apiv1 "github.com/tmax-cloud/image-signing-operator/api/v1"
+	"github.com/tmax-cloud/image-signing-operator/pkg/trust"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	DefaultRootPassphraseKey   = "root_passphrase"
+	DefaultTargetPassphraseKey = "repository_passphrase"
+)
+
+// ImportKeys creates the signer key with existing keys in the signer's key secret instead of generating a new root key
+func ImportKeys(c client.Client, signer apiv1.Signer, scheme *runtime.Scheme) (*apiv1.TrustKey, error) {
+	src := signer.SignerSpec().KeySecret
+
+	secret, err := getKeySecret(c, signer, src)
+	if err != nil {
+		return nil, err
+	}
+
+	rootPass := string(secret.Data[valueOrDefault(src.RootPassphraseKey, DefaultRootPassphraseKey)])
+	rootKey, err := findTrustKey(secret.Data, rootPass, trust.TrustRoleRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	targets, snapshots, err := readTargetKeys(secret, src)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("import keys", "secret", secret.Namespace+"/"+src.Name, "rootKeyId", rootKey.ID, "targets", len(targets), "snapshots", len(snapshots))
+	if err := createSignerKey(c, signer, scheme, apiv1.SignerKeySpec{
+		Root:      *rootKey,
+		Targets:   targets,
+		Snapshots: snapshots,
+	}); err != nil {
+		return nil, err
+	}
+
+	return rootKey, nil
+}
+
+// signerSecretNamespace is the namespace of secrets of the signer.
+// Secrets are only read in the operator's namespace, or in the namespace of NamespaceImageSigner
+func signerSecretNamespace(signer apiv1.Signer) string {
+	if namespace := signer.GetNamespace(); len(namespace) > 0 {
+		return namespace
+	}
+	return os.Getenv("OPERATOR_NAMESPACE")
+}
+
+func getKeySecret(c client.Client, signer apiv1.Signer, src *apiv1.KeySecretSource) (*corev1.Secret, error) {
+	namespace := signerSecretNamespace(signer)
+	secret := &corev1.Secret{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: src.Name, Namespace: namespace}, secret); err != nil {
+		return nil, err
+	}
+
+	return secret, nil
+}
+
+// readTargetKeys reads target keys of src.Targets and snapshot keys of src.Snapshots in the secret
+func readTargetKeys(secret *corev1.Secret, src *apiv1.KeySecretSource) (map[string]apiv1.TrustKey, map[string]apiv1.TrustKey, error) {
+	targetPass := string(secret.Data[valueOrDefault(src.TargetPassphraseKey, DefaultTargetPassphraseKey)])
+
+	targets, err := readKeyFiles(secret, src.Targets, targetPass, trust.TrustRoleTarget)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	snapshots, err := readKeyFiles(secret, src.Snapshots, targetPass, trust.TrustRoleSnapshot)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return targets, snapshots, nil
+}
+
+// readKeyFiles reads key files of the targets, which is {target name: key file}
+func readKeyFiles(secret *corev1.Secret, files map[string]string, passphrase string, role trust.RoleType) (map[string]apiv1.TrustKey, error) {
+	keys := map[string]apiv1.TrustKey{}
+	for targetName, id := range files {
+		contents, ok := secret.Data[id]
+		if !ok {
+			return nil, fmt.Errorf("%s key file %s of target %s is not found in secret %s/%s", role, id, targetName, secret.Namespace, secret.Name)
+		}
+		key, err := readKeyFile(id, contents, passphrase, role)
+		if err != nil {
+			return nil, err
+		}
+		keys[targetName] = *key
+	}
+
+	return keys, nil
+}
+
+// findTrustKey finds the only key file of the role, in the same way as readTrustKey
+func findTrustKey(files map[string][]byte, passphrase string, role trust.RoleType) (*apiv1.TrustKey, error) {
+	names := []string{}
+	for name, contents := range files {
+		if strings.HasSuffix(name, trust.KeyFileExt) && trust.HasRole(contents, role) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%s key file not found", role)
+	}
+	if len(names) > 1 {
+		return nil, fmt.Errorf("there are multiple %s key files: %s", role, strings.Join(names, ", "))
+	}
+
+	return readKeyFile(names[0], files[names[0]], passphrase, role)
+}
+
+// readKeyFile validates the key file and returns it as TrustKey
+func readKeyFile(id string, contents []byte, passphrase string, role trust.RoleType) (*apiv1.TrustKey, error) {
+	if _, err := trust.ParsePrivateKey(id, contents, passphrase, role); err != nil {
+		return nil, err
+	}
+
+	return &apiv1.TrustKey{
+		ID:         id,
+		Key:        string(contents),
+		PassPhrase: passphrase,
+	}, nil
+}
+
+func valueOrDefault(value, defaultValue string) string {
+	if len(value) == 0 {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"regexp"
+	"strings"
+)
+
+// DebugLevel is the verbosity of logs of full command output, which is off unless debug logs are enabled
+const DebugLevel = 1
+
+const redacted = "[REDACTED]"
+
+var (
+	// privateKeyRegexp matches PEM blocks of private keys, e.g., output of 'cat' of key files
+	privateKeyRegexp = regexp.MustCompile(`(?s)-----BEGIN [A-Z ]*PRIVATE KEY-----.*?-----END [A-Z ]*PRIVATE KEY-----`)
+	// passphraseRegexp matches assignments of passphrases, e.g., DOCKER_CONTENT_TRUST_ROOT_PASSPHRASE='...'
+	passphraseRegexp = regexp.MustCompile(`(_PASSPHRASE=)('[^']*'|\S+)`)
+)
+
+// isPassphraseEnv returns true if the environment variable is a passphrase of keys
+func isPassphraseEnv(name string) bool {
+	return strings.HasSuffix(name, "_PASSPHRASE")
+}
+
+// Redact removes private keys, passphrase assignments and the secrets from the text
+func Redact(text string, secrets ...string) string {
+	text = privateKeyRegexp.ReplaceAllString(text, redacted)
+	text = passphraseRegexp.ReplaceAllString(text, "${1}"+redacted)
+	for _, secret := range secrets {
+		if len(secret) > 0 {
+			text = strings.Replace(text, secret, redacted, -1)
+		}
+	}
+
+	return text
+}
+
+// logOutput logs the command is done, with its redacted output at debug level
+func (c *SigningController) logOutput(msg string, out *ExecResult, keysAndValues ...interface{}) {
+	c.Log.Info(msg, keysAndValues...)
+
+	if debug := c.Log.V(DebugLevel); debug.Enabled() {
+		debug.Info(msg+" output", append(keysAndValues,
+			"stdout", Redact(out.Outbuf.String(), c.secrets...),
+			"stderr", Redact(out.Errbuf.String(), c.secrets...),
+		)...)
+	}
+}
@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	apiv1 "github.com/tmax-cloud/image-signing-operator/api/v1"
+	"github.com/tmax-cloud/image-signing-operator/pkg/trust"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	NotaryCAKey = "ca.crt"
+)
+
+// GetNotaryServer returns the notary server of the request, or of the signer if the request does not override it.
+// It returns nil if neither has the notary server
+func GetNotaryServer(c client.Client, signer apiv1.Signer, signReq *apiv1.ImageSignRequest) (*trust.NotaryServer, error) {
+	// secrets of the request are in its namespace, and secrets of the signer are where its key secrets are
+	src := signReq.Spec.RegistryLogin.Notary
+	namespace := signReq.Namespace
+	if src == nil {
+		src = signer.SignerSpec().Notary
+		namespace = signerSecretNamespace(signer)
+	}
+	if src == nil {
+		return nil, nil
+	}
+
+	server := &trust.NotaryServer{URL: src.URL}
+
+	if len(src.CASecret) > 0 {
+		secret := &corev1.Secret{}
+		if err := c.Get(context.TODO(), types.NamespacedName{Name: src.CASecret, Namespace: namespace}, secret); err != nil {
+			return nil, err
+		}
+		ca, ok := secret.Data[NotaryCAKey]
+		if !ok {
+			return nil, fmt.Errorf("there is no %s in secret %s/%s", NotaryCAKey, namespace, src.CASecret)
+		}
+		server.CA = ca
+	}
+
+	if len(src.AuthSecret) > 0 {
+		secret := &corev1.Secret{}
+		if err := c.Get(context.TODO(), types.NamespacedName{Name: src.AuthSecret, Namespace: namespace}, secret); err != nil {
+			return nil, err
+		}
+		server.Username = string(secret.Data[corev1.BasicAuthUsernameKey])
+		server.Password = string(secret.Data[corev1.BasicAuthPasswordKey])
+	}
+
+	return server, nil
+}
@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"os"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	apiv1 "github.com/tmax-cloud/image-signing-operator/api/v1"
+)
+
+func TestGetNotaryServer(t *testing.T) {
+	if err := os.Setenv("OPERATOR_NAMESPACE", "registry-system"); err != nil {
+		t.Fatal(err)
+	}
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	// each namespace has secrets of the same names, with the namespace as the user name
+	var objs []runtime.Object
+	for _, ns := range []string{"registry-system", "team", "dev"} {
+		objs = append(objs, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "notary-auth", Namespace: ns},
+			Data:       map[string][]byte{corev1.BasicAuthUsernameKey: []byte(ns)},
+		})
+	}
+	cli := fake.NewFakeClientWithScheme(scheme, objs...)
+	notary := &apiv1.NotaryServer{URL: "https://notary:4443", AuthSecret: "notary-auth"}
+
+	tc := map[string]struct {
+		signer   apiv1.Signer
+		request  *apiv1.NotaryServer
+		expected string
+	}{
+		"imageSigner": {
+			signer:   &apiv1.ImageSigner{Spec: apiv1.ImageSignerSpec{Notary: notary}},
+			expected: "registry-system",
+		},
+		"namespaceImageSigner": {
+			signer:   &apiv1.NamespaceImageSigner{ObjectMeta: metav1.ObjectMeta{Namespace: "team"}, Spec: apiv1.ImageSignerSpec{Notary: notary}},
+			expected: "team",
+		},
+		"request": {
+			signer:   &apiv1.ImageSigner{Spec: apiv1.ImageSignerSpec{Notary: notary}},
+			request:  notary,
+			expected: "dev",
+		},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			signReq := &apiv1.ImageSignRequest{ObjectMeta: metav1.ObjectMeta{Namespace: "dev"}}
+			signReq.Spec.RegistryLogin.Notary = c.request
+
+			server, err := GetNotaryServer(cli, c.signer, signReq)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if server.Username != c.expected {
+				t.Fatalf("expected the secret in %s, got the secret in %s", c.expected, server.Username)
+			}
+		})
+	}
+}
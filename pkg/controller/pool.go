@@ -0,0 +1,364 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	apiv1 "github.com/tmax-cloud/image-signing-operator/api/v1"
+	"github.com/tmax-cloud/image-signing-operator/internal/schemes"
+	"github.com/tmax-cloud/image-signing-operator/internal/utils"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// PoolLabel is a label of pooled pods, whose value identifies the pool
+	PoolLabel = "image-signing.tmax.io/pool"
+	// DefaultWorkerMaxUses is the number of sessions after which a pooled pod is replaced
+	DefaultWorkerMaxUses = 10
+	// DefaultWorkerIdleTTL is a duration after which pods of a pool which is not leased are removed
+	DefaultWorkerIdleTTL = 10 * time.Minute
+
+	// evictInterval is an interval to remove idle pools
+	evictInterval = time.Minute
+
+	// wipeWorkerCommand removes keys, passphrases, credentials, certs and images of a session
+	wipeWorkerCommand = "rm -rf " + BaseDir + " " + SessionEnvFile + " " + DockerConfigDir + "/" + DockerConfigFile + " " + TLSDir +
+		" && docker image prune -a -f > /dev/null"
+)
+
+// WorkerPool keeps pre-started dind pods of signers which have a worker pool.
+// Pods are pooled per signer, namespace and pod template. They are removed when the pool is idle for its TTL,
+// when the worker pool of the signer is changed or removed, and when the operator stops
+type WorkerPool struct {
+	client client.Client
+	scheme *runtime.Scheme
+	// ready is closed when pods left by a previous operator are removed
+	ready chan struct{}
+
+	mu    sync.Mutex
+	pools map[string]*pool
+}
+
+type pool struct {
+	// signer identifies the signer of the pool
+	signer string
+	// spec is the worker pool of the signer when the pool is created
+	spec     apiv1.WorkerPoolSpec
+	idle     []*worker
+	creating int
+	lastUsed time.Time
+}
+
+func (pl *pool) maxUses() int {
+	if pl.spec.MaxUses > 0 {
+		return pl.spec.MaxUses
+	}
+	return DefaultWorkerMaxUses
+}
+
+func (pl *pool) idleTTL() time.Duration {
+	if pl.spec.IdleTTLSeconds > 0 {
+		return time.Duration(pl.spec.IdleTTLSeconds) * time.Second
+	}
+	return DefaultWorkerIdleTTL
+}
+
+type worker struct {
+	pod  *corev1.Pod
+	uses int
+	// pool is the pool the pod is created for. The pod is not returned to a drained pool
+	pool *pool
+}
+
+// workerTemplate has what cannot be changed after a pod starts
+type workerTemplate struct {
+	signer     apiv1.Signer
+	namespace  string
+	pvc        string
+	certSecret string
+	certs      *schemes.SecretFiles
+	args       []string
+}
+
+func (t *workerTemplate) key() string {
+	return strings.Join([]string{
+		signerID(t.signer), t.namespace, t.pvc, t.certSecret, t.certs.String(), strings.Join(t.args, " "),
+	}, "|")
+}
+
+func signerID(signer apiv1.Signer) string {
+	return path.Join(signer.SignerKind(), signer.GetNamespace(), signer.GetName())
+}
+
+// label identifies the pool in a label value
+func (t *workerTemplate) label() string {
+	sum := sha256.Sum256([]byte(t.key()))
+	return hex.EncodeToString(sum[:])[:32]
+}
+
+func (t *workerTemplate) spec() apiv1.WorkerPoolSpec {
+	if spec := t.signer.SignerSpec().WorkerPool; spec != nil {
+		return *spec
+	}
+	return apiv1.WorkerPoolSpec{}
+}
+
+// NewWorkerPool creates an empty pool. Pods are created when signers lease them first.
+// The pool should be added to the manager, pods are not leased until it starts
+func NewWorkerPool(c client.Client, scheme *runtime.Scheme) *WorkerPool {
+	return &WorkerPool{
+		client: c,
+		scheme: scheme,
+		ready:  make(chan struct{}),
+		pools:  map[string]*pool{},
+	}
+}
+
+// Start removes pooled pods left by a previous operator, removes idle pools periodically,
+// and removes the pods of this operator when it stops
+func (p *WorkerPool) Start(stop <-chan struct{}) error {
+	if err := p.deleteAll(); err != nil {
+		log.Error(err, "cannot delete pooled pods")
+	}
+	close(p.ready)
+
+	ticker := time.NewTicker(evictInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			p.evictIdle(now)
+			continue
+		case <-stop:
+		}
+		break
+	}
+
+	p.mu.Lock()
+	p.pools = map[string]*pool{}
+	p.mu.Unlock()
+
+	return p.deleteAll()
+}
+
+// Sync drains the pools of the signer which are created with another worker pool spec, e.g., resized or removed
+func (p *WorkerPool) Sync(signer apiv1.Signer) {
+	spec := apiv1.WorkerPoolSpec{}
+	if signer.SignerSpec().WorkerPool != nil {
+		spec = *signer.SignerSpec().WorkerPool
+	}
+	id := signerID(signer)
+	p.drain(func(pl *pool) bool {
+		return pl.signer == id && pl.spec != spec
+	})
+}
+
+// Drain removes the pools of the signer, e.g., if it is deleted. Leased pods are removed when they are released
+func (p *WorkerPool) Drain(signer apiv1.Signer) {
+	id := signerID(signer)
+	p.drain(func(pl *pool) bool {
+		return pl.signer == id
+	})
+}
+
+// evictIdle removes the pools which are not leased for their idle TTL
+func (p *WorkerPool) evictIdle(now time.Time) {
+	p.drain(func(pl *pool) bool {
+		return pl.creating == 0 && now.Sub(pl.lastUsed) > pl.idleTTL()
+	})
+}
+
+// drain removes the matched pools and deletes their idle pods
+func (p *WorkerPool) drain(match func(pl *pool) bool) {
+	idle := []*worker{}
+	p.mu.Lock()
+	for key, pl := range p.pools {
+		if match(pl) {
+			delete(p.pools, key)
+			idle = append(idle, pl.idle...)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, w := range idle {
+		p.delete(w)
+	}
+}
+
+// lease returns an idle pod of the template, or a new pod if there is no idle pod.
+// The pool is filled up to its size in the background
+func (p *WorkerPool) lease(t *workerTemplate) (*worker, error) {
+	<-p.ready
+	key := t.key()
+
+	for {
+		p.mu.Lock()
+		pl := p.getPool(key, t)
+		pl.lastUsed = time.Now()
+		if len(pl.idle) == 0 {
+			p.mu.Unlock()
+			break
+		}
+		w := pl.idle[0]
+		pl.idle = pl.idle[1:]
+		p.mu.Unlock()
+
+		// the pod may be deleted, e.g., with its signer
+		if err := p.client.Get(context.TODO(), client.ObjectKey{Name: w.pod.Name, Namespace: w.pod.Namespace}, w.pod); err == nil &&
+			w.pod.Status.Phase == corev1.PodRunning && w.pod.DeletionTimestamp == nil {
+			go p.fill(t)
+			return w, nil
+		}
+		p.delete(w)
+	}
+
+	p.mu.Lock()
+	pl := p.getPool(key, t)
+	p.mu.Unlock()
+	w, err := p.create(t, pl)
+	if err != nil {
+		return nil, err
+	}
+	go p.fill(t)
+
+	return w, nil
+}
+
+// release returns the pod to the pool, or deletes it if it is used up or failed. It returns true if the pod is deleted
+func (p *WorkerPool) release(t *workerTemplate, w *worker, failed bool) bool {
+	w.uses++
+
+	p.mu.Lock()
+	pl := p.pools[t.key()]
+	recycle := failed || pl == nil || pl != w.pool || w.uses >= pl.maxUses() || len(pl.idle)+pl.creating >= pl.spec.Size
+	if !recycle {
+		pl.idle = append(pl.idle, w)
+	}
+	p.mu.Unlock()
+
+	if recycle {
+		p.delete(w)
+		go p.fill(t)
+	}
+
+	return recycle
+}
+
+// fill creates pods until the pool has idle pods as many as its size
+func (p *WorkerPool) fill(t *workerTemplate) {
+	key := t.key()
+
+	p.mu.Lock()
+	pl := p.getPool(key, t)
+	n := pl.spec.Size - len(pl.idle) - pl.creating
+	if n <= 0 {
+		p.mu.Unlock()
+		return
+	}
+	pl.creating += n
+	p.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			w, err := p.create(t, pl)
+
+			p.mu.Lock()
+			pl.creating--
+			drained := p.pools[key] != pl
+			if err == nil && !drained {
+				pl.idle = append(pl.idle, w)
+			}
+			p.mu.Unlock()
+
+			if err != nil {
+				log.Error(err, "cannot create pooled pod", "pool", key)
+			} else if drained {
+				p.delete(w)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (p *WorkerPool) create(t *workerTemplate, pl *pool) (*worker, error) {
+	pod := schemes.NewDindPod(
+		t.namespace,
+		"image-signing-pool-"+t.signer.GetName()+"-"+utils.RandomString(10),
+		"docker-cli",
+		"",
+		schemes.WithPvc(t.pvc),
+		schemes.WithCertSecret(t.certSecret),
+		schemes.WithSecretFiles(certsVolume, t.certs),
+		schemes.WithLifeCycle(nil),
+		schemes.WithArgs(t.args),
+		schemes.WithLabels(map[string]string{PoolLabel: t.label()}),
+	)
+
+	// pods are deleted with their signer, unless a namespaced signer is used in another namespace
+	if len(t.signer.GetNamespace()) == 0 || t.signer.GetNamespace() == t.namespace {
+		if err := controllerutil.SetOwnerReference(t.signer, pod, p.scheme); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.client.Create(context.TODO(), pod); err != nil {
+		return nil, err
+	}
+
+	w := &worker{pod: pod, pool: pl}
+	if err := waitForRunning(p.client, pod); err != nil {
+		p.delete(w)
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (p *WorkerPool) delete(w *worker) {
+	if err := p.client.Delete(context.TODO(), w.pod); err != nil && !errors.IsNotFound(err) {
+		log.Error(err, "cannot delete pooled pod", "pod/namespace", w.pod.Name+"/"+w.pod.Namespace)
+	}
+}
+
+func (p *WorkerPool) deleteAll() error {
+	pods := &corev1.PodList{}
+	if err := p.client.List(context.TODO(), pods, client.HasLabels{PoolLabel}); err != nil {
+		return err
+	}
+
+	for i := range pods.Items {
+		p.delete(&worker{pod: &pods.Items[i]})
+	}
+
+	return nil
+}
+
+// getPool returns the pool of the template. A pool created with another spec of the signer is replaced,
+// and its pods are removed when they are released
+func (p *WorkerPool) getPool(key string, t *workerTemplate) *pool {
+	spec := t.spec()
+	pl, ok := p.pools[key]
+	if !ok || pl.spec != spec {
+		if ok {
+			for _, w := range pl.idle {
+				go p.delete(w)
+			}
+		}
+		pl = &pool{signer: signerID(t.signer), spec: spec, lastUsed: time.Now()}
+		p.pools[key] = pl
+	}
+	return pl
+}
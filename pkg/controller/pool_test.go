@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	apiv1 "github.com/tmax-cloud/image-signing-operator/api/v1"
+)
+
+func newTestPool(t *testing.T) *WorkerPool {
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return NewWorkerPool(fake.NewFakeClientWithScheme(scheme), scheme)
+}
+
+func newTestTemplate(name string, spec *apiv1.WorkerPoolSpec) *workerTemplate {
+	signer := &apiv1.ImageSigner{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       apiv1.ImageSignerSpec{WorkerPool: spec},
+	}
+	return &workerTemplate{signer: signer, namespace: "team"}
+}
+
+// addIdle adds running pods to the pool of the template, without waiting for them
+func addIdle(t *testing.T, p *WorkerPool, tmpl *workerTemplate, n int) *pool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pl := p.getPool(tmpl.key(), tmpl)
+	for i := 0; i < n; i++ {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%d", tmpl.signer.GetName(), len(pl.idle)),
+			Namespace: tmpl.namespace,
+		}}
+		if err := p.client.Create(context.TODO(), pod); err != nil {
+			t.Fatal(err)
+		}
+		pl.idle = append(pl.idle, &worker{pod: pod, pool: pl})
+	}
+	return pl
+}
+
+func countPods(t *testing.T, p *WorkerPool) int {
+	pods := &corev1.PodList{}
+	if err := p.client.List(context.TODO(), pods, client.InNamespace("team")); err != nil {
+		t.Fatal(err)
+	}
+	return len(pods.Items)
+}
+
+func TestWorkerPoolEvictIdle(t *testing.T) {
+	p := newTestPool(t)
+	short := newTestTemplate("short", &apiv1.WorkerPoolSpec{Size: 2, IdleTTLSeconds: 60})
+	long := newTestTemplate("long", &apiv1.WorkerPoolSpec{Size: 1})
+	addIdle(t, p, short, 2)
+	addIdle(t, p, long, 1)
+
+	now := time.Now()
+	p.evictIdle(now)
+	if len(p.pools) != 2 || countPods(t, p) != 3 {
+		t.Fatalf("expected pools not to be evicted yet, got %d pools, %d pods", len(p.pools), countPods(t, p))
+	}
+
+	p.evictIdle(now.Add(2 * time.Minute))
+	if _, ok := p.pools[short.key()]; ok || countPods(t, p) != 1 {
+		t.Fatalf("expected the pool with a short TTL to be evicted, got %d pools, %d pods", len(p.pools), countPods(t, p))
+	}
+
+	p.evictIdle(now.Add(DefaultWorkerIdleTTL + time.Minute))
+	if len(p.pools) != 0 || countPods(t, p) != 0 {
+		t.Fatalf("expected all pools to be evicted, got %d pools, %d pods", len(p.pools), countPods(t, p))
+	}
+}
+
+func TestWorkerPoolEvictIdleCreating(t *testing.T) {
+	p := newTestPool(t)
+	tmpl := newTestTemplate("signer", &apiv1.WorkerPoolSpec{Size: 2})
+	pl := addIdle(t, p, tmpl, 1)
+	pl.creating = 1
+
+	p.evictIdle(time.Now().Add(2 * DefaultWorkerIdleTTL))
+	if len(p.pools) != 1 {
+		t.Fatal("expected a pool which is creating pods not to be evicted")
+	}
+}
+
+func TestWorkerPoolSync(t *testing.T) {
+	p := newTestPool(t)
+	tmpl := newTestTemplate("signer", &apiv1.WorkerPoolSpec{Size: 2})
+	other := newTestTemplate("other", &apiv1.WorkerPoolSpec{Size: 1})
+	addIdle(t, p, tmpl, 2)
+	addIdle(t, p, other, 1)
+
+	// unchanged
+	p.Sync(tmpl.signer)
+	if len(p.pools) != 2 || countPods(t, p) != 3 {
+		t.Fatalf("expected pools not to be drained, got %d pools, %d pods", len(p.pools), countPods(t, p))
+	}
+
+	// resized
+	tmpl.signer.SignerSpec().WorkerPool = &apiv1.WorkerPoolSpec{Size: 1}
+	p.Sync(tmpl.signer)
+	if _, ok := p.pools[tmpl.key()]; ok || countPods(t, p) != 1 {
+		t.Fatalf("expected the resized pool to be drained, got %d pools, %d pods", len(p.pools), countPods(t, p))
+	}
+
+	// removed
+	addIdle(t, p, tmpl, 1)
+	tmpl.signer.SignerSpec().WorkerPool = nil
+	p.Sync(tmpl.signer)
+	if _, ok := p.pools[tmpl.key()]; ok || countPods(t, p) != 1 {
+		t.Fatalf("expected the removed pool to be drained, got %d pools, %d pods", len(p.pools), countPods(t, p))
+	}
+	if _, ok := p.pools[other.key()]; !ok {
+		t.Fatal("expected the pool of another signer not to be drained")
+	}
+}
+
+func TestWorkerPoolDrain(t *testing.T) {
+	p := newTestPool(t)
+	tmpl := newTestTemplate("signer", &apiv1.WorkerPoolSpec{Size: 2})
+	other := newTestTemplate("other", &apiv1.WorkerPoolSpec{Size: 1})
+	addIdle(t, p, tmpl, 2)
+	otherTmpl := *tmpl
+	otherTmpl.namespace = "dev"
+	addIdle(t, p, &otherTmpl, 0)
+	addIdle(t, p, other, 1)
+
+	// only the name of a deleted signer is known
+	p.Drain(&apiv1.ImageSigner{ObjectMeta: metav1.ObjectMeta{Name: "signer"}})
+	if len(p.pools) != 1 || countPods(t, p) != 1 {
+		t.Fatalf("expected the pools of the signer to be drained, got %d pools, %d pods", len(p.pools), countPods(t, p))
+	}
+}
+
+func TestWorkerPoolReleaseDrained(t *testing.T) {
+	p := newTestPool(t)
+	tmpl := newTestTemplate("signer", &apiv1.WorkerPoolSpec{Size: 2})
+	pl := addIdle(t, p, tmpl, 2)
+
+	// lease a pod, and remove the worker pool while it is leased
+	w := pl.idle[0]
+	pl.idle = pl.idle[1:]
+	tmpl.signer.SignerSpec().WorkerPool = nil
+	p.Sync(tmpl.signer)
+
+	if !p.release(tmpl, w, false) {
+		t.Fatal("expected the pod of a drained pool to be recycled")
+	}
+	if countPods(t, p) != 0 {
+		t.Fatalf("expected pods to be deleted, got %d pods", countPods(t, p))
+	}
+}
+
+func TestWorkerPoolRelease(t *testing.T) {
+	p := newTestPool(t)
+	tmpl := newTestTemplate("signer", &apiv1.WorkerPoolSpec{Size: 2})
+	pl := addIdle(t, p, tmpl, 2)
+
+	w := pl.idle[0]
+	pl.idle = pl.idle[1:]
+	if p.release(tmpl, w, false) {
+		t.Fatal("expected the pod to be returned to the pool")
+	}
+	if len(pl.idle) != 2 || w.uses != 1 {
+		t.Fatalf("expected 2 idle pods and 1 use, got %d, %d", len(pl.idle), w.uses)
+	}
+}
@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	apiv1 "github.com/tmax-cloud/image-signing-operator/api/v1"
+	"github.com/tmax-cloud/image-signing-operator/internal/schemes"
+	"github.com/tmax-cloud/image-signing-operator/pkg/registry"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	DefaultRegistryCAKey = "ca.crt"
+
+	// CertsDir is a directory of registry certs of docker daemon, as /etc/docker/certs.d/<host>/
+	CertsDir       = "/etc/docker/certs.d"
+	certsVolume    = "registry-certs"
+	certsDirCA     = "ca.crt"
+	certsDirCert   = "client.cert"
+	certsDirKey    = "client.key"
+	insecureOption = "--insecure-registry"
+)
+
+// GetRegistryTLS returns the TLS configuration of the request's registry, or nil if the request does not have it
+func GetRegistryTLS(c client.Client, signReq *apiv1.ImageSignRequest) (*registry.TLS, error) {
+	src := signReq.Spec.RegistryLogin.TLS
+	if src == nil {
+		return nil, nil
+	}
+
+	t := &registry.TLS{
+		InsecureSkipVerify: src.InsecureSkipVerify,
+		ServerName:         src.ServerName,
+	}
+	if len(src.SecretName) == 0 {
+		return t, nil
+	}
+
+	secret := &corev1.Secret{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: src.SecretName, Namespace: signReq.Namespace}, secret); err != nil {
+		return nil, err
+	}
+
+	t.CA = secret.Data[valueOrDefault(src.CAKey, DefaultRegistryCAKey)]
+	t.Cert = secret.Data[valueOrDefault(src.CertKey, corev1.TLSCertKey)]
+	t.Key = secret.Data[valueOrDefault(src.KeyKey, corev1.TLSPrivateKeyKey)]
+	if (len(t.Cert) > 0) != (len(t.Key) > 0) {
+		return nil, fmt.Errorf("secret %s/%s should have both client certificate and key", signReq.Namespace, src.SecretName)
+	}
+
+	return t, nil
+}
+
+// RegistryCertsFiles returns the files of the request's TLS secret for docker daemon in /etc/docker/certs.d/<host>,
+// or nil if the secret has neither CA bundle nor client certificate. The secret is mounted in the pod,
+// so that the client key is not in commands of the pod
+func RegistryCertsFiles(signReq *apiv1.ImageSignRequest, host string, t *registry.TLS) *schemes.SecretFiles {
+	src := signReq.Spec.RegistryLogin.TLS
+	if src == nil || len(src.SecretName) == 0 || t == nil {
+		return nil
+	}
+
+	items := map[string]string{}
+	if len(t.CA) > 0 {
+		items[valueOrDefault(src.CAKey, DefaultRegistryCAKey)] = certsDirCA
+	}
+	if len(t.Cert) > 0 {
+		items[valueOrDefault(src.CertKey, corev1.TLSCertKey)] = certsDirCert
+		items[valueOrDefault(src.KeyKey, corev1.TLSPrivateKeyKey)] = certsDirKey
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	return &schemes.SecretFiles{
+		SecretName: src.SecretName,
+		Dir:        path.Join(CertsDir, host),
+		Items:      items,
+	}
+}
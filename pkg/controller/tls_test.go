@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	apiv1 "github.com/tmax-cloud/image-signing-operator/api/v1"
+	"github.com/tmax-cloud/image-signing-operator/internal/schemes"
+	"github.com/tmax-cloud/image-signing-operator/pkg/registry"
+)
+
+func TestRegistryCertsFiles(t *testing.T) {
+	tc := map[string]struct {
+		src      *apiv1.RegistryTLS
+		tls      *registry.TLS
+		expected *schemes.SecretFiles
+	}{
+		"noTLS": {},
+		"noSecret": {
+			src: &apiv1.RegistryTLS{InsecureSkipVerify: true},
+			tls: &registry.TLS{InsecureSkipVerify: true},
+		},
+		"ca": {
+			src: &apiv1.RegistryTLS{SecretName: "certs"},
+			tls: &registry.TLS{CA: []byte("ca")},
+			expected: &schemes.SecretFiles{SecretName: "certs", Dir: "/etc/docker/certs.d/myreg:5000", Items: map[string]string{
+				"ca.crt": "ca.crt",
+			}},
+		},
+		"clientCert": {
+			src: &apiv1.RegistryTLS{SecretName: "certs", CertKey: "client.pem", KeyKey: "client-key.pem"},
+			tls: &registry.TLS{CA: []byte("ca"), Cert: []byte("cert"), Key: []byte("key")},
+			expected: &schemes.SecretFiles{SecretName: "certs", Dir: "/etc/docker/certs.d/myreg:5000", Items: map[string]string{
+				"ca.crt":         "ca.crt",
+				"client.pem":     "client.cert",
+				"client-key.pem": "client.key",
+			}},
+		},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			signReq := &apiv1.ImageSignRequest{}
+			signReq.Spec.RegistryLogin.TLS = c.src
+
+			files := RegistryCertsFiles(signReq, "myreg:5000", c.tls)
+			if !reflect.DeepEqual(files, c.expected) {
+				t.Errorf("expected %+v, got %+v", c.expected, files)
+			}
+		})
+	}
+}
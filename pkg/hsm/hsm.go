@@ -0,0 +1,48 @@
+package hsm
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+)
+
+// ErrNotSupported is returned if the operator is built without 'pkcs11' tag
+var ErrNotSupported = errors.New("PKCS#11 is not supported, the operator should be built with 'pkcs11' tag")
+
+// Config is a location of a key pair in a PKCS#11 token
+type Config struct {
+	ModulePath string
+	// Slot is used if it is not nil, otherwise the token is found by TokenLabel
+	Slot       *uint
+	TokenLabel string
+	KeyLabel   string
+	Pin        string
+}
+
+// Key is a private key in a token, which can be used as crypto.Signer.
+// It should be closed to log out from the token
+type Key interface {
+	crypto.Signer
+	io.Closer
+}
+
+// Open logs in to the token and finds the key pair of the label
+func Open(cfg *Config) (Key, error) {
+	return open(cfg)
+}
+
+// PublicKeyPEM encodes the public key as a PEM block with the role header, as notary does
+func PublicKeyPEM(pub crypto.PublicKey, role string) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{
+		Type:    "PUBLIC KEY",
+		Headers: map[string]string{"role": role},
+		Bytes:   der,
+	})), nil
+}
@@ -0,0 +1,223 @@
+// +build pkcs11
+
+package hsm
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+)
+
+var (
+	oidP256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}
+	oidP384 = asn1.ObjectIdentifier{1, 3, 132, 0, 34}
+)
+
+type pkcs11Key struct {
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+	priv    pkcs11.ObjectHandle
+	pub     *ecdsa.PublicKey
+
+	// sessions are not safe for concurrent use
+	lock sync.Mutex
+}
+
+func open(cfg *Config) (Key, error) {
+	ctx := pkcs11.New(cfg.ModulePath)
+	if ctx == nil {
+		return nil, fmt.Errorf("cannot load PKCS#11 module %s", cfg.ModulePath)
+	}
+	if err := ctx.Initialize(); err != nil {
+		ctx.Destroy()
+		return nil, err
+	}
+
+	key := &pkcs11Key{ctx: ctx}
+	if err := key.login(cfg); err != nil {
+		ctx.Finalize()
+		ctx.Destroy()
+		return nil, err
+	}
+
+	if err := key.findKeyPair(cfg.KeyLabel); err != nil {
+		key.Close()
+		return nil, err
+	}
+
+	return key, nil
+}
+
+func (k *pkcs11Key) login(cfg *Config) error {
+	slot, err := findSlot(k.ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	session, err := k.ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION)
+	if err != nil {
+		return err
+	}
+	k.session = session
+
+	if err := k.ctx.Login(session, pkcs11.CKU_USER, cfg.Pin); err != nil {
+		_ = k.ctx.CloseSession(session)
+		return fmt.Errorf("cannot log in to the token: %v", err)
+	}
+
+	return nil
+}
+
+func findSlot(ctx *pkcs11.Ctx, cfg *Config) (uint, error) {
+	if cfg.Slot != nil {
+		return *cfg.Slot, nil
+	}
+
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return 0, err
+	}
+	for _, slot := range slots {
+		info, err := ctx.GetTokenInfo(slot)
+		if err != nil {
+			continue
+		}
+		if info.Label == cfg.TokenLabel {
+			return slot, nil
+		}
+	}
+
+	return 0, fmt.Errorf("token %q is not found", cfg.TokenLabel)
+}
+
+func (k *pkcs11Key) findKeyPair(label string) error {
+	priv, err := k.findObject(pkcs11.CKO_PRIVATE_KEY, label)
+	if err != nil {
+		return err
+	}
+	k.priv = priv
+
+	pubObj, err := k.findObject(pkcs11.CKO_PUBLIC_KEY, label)
+	if err != nil {
+		return err
+	}
+
+	attrs, err := k.ctx.GetAttributeValue(k.session, pubObj, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+	})
+	if err != nil {
+		return fmt.Errorf("cannot read public key %q (only ECDSA keys are supported): %v", label, err)
+	}
+
+	pub, err := parseECPublicKey(attrs[0].Value, attrs[1].Value)
+	if err != nil {
+		return err
+	}
+	k.pub = pub
+
+	return nil
+}
+
+func (k *pkcs11Key) findObject(class uint, label string) (pkcs11.ObjectHandle, error) {
+	if err := k.ctx.FindObjectsInit(k.session, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	}); err != nil {
+		return 0, err
+	}
+	objs, _, err := k.ctx.FindObjects(k.session, 2)
+	if finalErr := k.ctx.FindObjectsFinal(k.session); err == nil {
+		err = finalErr
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	switch len(objs) {
+	case 0:
+		return 0, fmt.Errorf("key %q is not found in the token", label)
+	case 1:
+		return objs[0], nil
+	default:
+		return 0, fmt.Errorf("there are multiple keys of label %q in the token", label)
+	}
+}
+
+func parseECPublicKey(params, point []byte) (*ecdsa.PublicKey, error) {
+	var oid asn1.ObjectIdentifier
+	if _, err := asn1.Unmarshal(params, &oid); err != nil {
+		return nil, err
+	}
+
+	var curve elliptic.Curve
+	switch {
+	case oid.Equal(oidP256):
+		curve = elliptic.P256()
+	case oid.Equal(oidP384):
+		curve = elliptic.P384()
+	default:
+		return nil, fmt.Errorf("unsupported curve %v", oid)
+	}
+
+	// CKA_EC_POINT is a DER encoded OCTET STRING of the uncompressed point
+	var raw []byte
+	if _, err := asn1.Unmarshal(point, &raw); err != nil {
+		return nil, err
+	}
+	x, y := elliptic.Unmarshal(curve, raw)
+	if x == nil {
+		return nil, fmt.Errorf("EC point is malformed")
+	}
+
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+func (k *pkcs11Key) Public() crypto.PublicKey {
+	return k.pub
+}
+
+// Sign signs the digest in the token and returns ASN.1 encoded signature, as ecdsa.PrivateKey does
+func (k *pkcs11Key) Sign(_ io.Reader, digest []byte, _ crypto.SignerOpts) ([]byte, error) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	if err := k.ctx.SignInit(k.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil)}, k.priv); err != nil {
+		return nil, err
+	}
+	sig, err := k.ctx.Sign(k.session, digest)
+	if err != nil {
+		return nil, err
+	}
+	if len(sig)%2 != 0 {
+		return nil, fmt.Errorf("signature is malformed")
+	}
+
+	// CKM_ECDSA returns r || s
+	half := len(sig) / 2
+	return asn1.Marshal(struct {
+		R, S *big.Int
+	}{
+		R: new(big.Int).SetBytes(sig[:half]),
+		S: new(big.Int).SetBytes(sig[half:]),
+	})
+}
+
+func (k *pkcs11Key) Close() error {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	_ = k.ctx.Logout(k.session)
+	_ = k.ctx.CloseSession(k.session)
+	err := k.ctx.Finalize()
+	k.ctx.Destroy()
+
+	return err
+}
@@ -0,0 +1,174 @@
+// +build pkcs11
+
+package hsm
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/miekg/pkcs11"
+)
+
+const (
+	testTokenLabel = "image-signer"
+	testKeyLabel   = "root"
+	testPin        = "1234"
+	testSOPin      = "5678"
+)
+
+// newSoftHSMToken initializes a SoftHSM token with an ECDSA key pair. SOFTHSM2_MODULE is the path of libsofthsm2.so
+func newSoftHSMToken(t *testing.T) *Config {
+	module := os.Getenv("SOFTHSM2_MODULE")
+	if len(module) == 0 {
+		t.Skip("SOFTHSM2_MODULE is not set")
+	}
+
+	dir, err := ioutil.TempDir("", "softhsm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := filepath.Join(dir, "softhsm2.conf")
+	if err := ioutil.WriteFile(conf, []byte(fmt.Sprintf("directories.tokendir = %s\nobjectstore.backend = file\n", dir)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Setenv("SOFTHSM2_CONF", conf); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := pkcs11.New(module)
+	if ctx == nil {
+		t.Fatalf("cannot load %s", module)
+	}
+	defer ctx.Destroy()
+	if err := ctx.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Finalize()
+
+	slots, err := ctx.GetSlotList(false)
+	if err != nil || len(slots) == 0 {
+		t.Fatalf("no slot: %v", err)
+	}
+	if err := ctx.InitToken(slots[0], testSOPin, testTokenLabel); err != nil {
+		t.Fatal(err)
+	}
+
+	// The token is moved to a new slot after it is initialized
+	cfg := &Config{ModulePath: module, TokenLabel: testTokenLabel, KeyLabel: testKeyLabel, Pin: testPin}
+	slot, err := findSlot(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.CloseSession(session)
+
+	if err := ctx.Login(session, pkcs11.CKU_SO, testSOPin); err != nil {
+		t.Fatal(err)
+	}
+	if err := ctx.InitPIN(session, testPin); err != nil {
+		t.Fatal(err)
+	}
+	_ = ctx.Logout(session)
+	if err := ctx.Login(session, pkcs11.CKU_USER, testPin); err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Logout(session)
+
+	params, _ := asn1.Marshal(oidP256)
+	if _, _, err := ctx.GenerateKeyPair(session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_EC_KEY_PAIR_GEN, nil)},
+		[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, params),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, testKeyLabel),
+		},
+		[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, testKeyLabel),
+		}); err != nil {
+		t.Fatal(err)
+	}
+
+	return cfg
+}
+
+func TestSoftHSMSign(t *testing.T) {
+	cfg := newSoftHSMToken(t)
+
+	key, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer key.Close()
+
+	pub, ok := key.Public().(*ecdsa.PublicKey)
+	if !ok {
+		t.Fatalf("expected ECDSA public key, got %T", key.Public())
+	}
+
+	digest := sha256.Sum256([]byte("root.json"))
+	sig, err := key.Sign(nil, digest[:], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rs struct {
+		R, S *big.Int
+	}
+	if _, err := asn1.Unmarshal(sig, &rs); err != nil {
+		t.Fatal(err)
+	}
+	if !ecdsa.Verify(pub, digest[:], rs.R, rs.S) {
+		t.Errorf("signature of the token is not valid")
+	}
+
+	// Root certificates of repositories are self-signed by the key in the token
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "registry.example.com/ns/app"}}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cert.CheckSignatureFrom(cert); err != nil {
+		t.Errorf("certificate signed by the token is not valid: %v", err)
+	}
+
+	if _, err := PublicKeyPEM(key.Public(), "root"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSoftHSMOpenError(t *testing.T) {
+	cfg := newSoftHSMToken(t)
+
+	wrongPin := *cfg
+	wrongPin.Pin = "0000"
+	if _, err := Open(&wrongPin); err == nil {
+		t.Errorf("expected error for the wrong PIN")
+	}
+
+	noKey := *cfg
+	noKey.KeyLabel = "targets"
+	if _, err := Open(&noKey); err == nil {
+		t.Errorf("expected error for the missing key")
+	}
+}
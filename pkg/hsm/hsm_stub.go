@@ -0,0 +1,7 @@
+// +build !pkcs11
+
+package hsm
+
+func open(_ *Config) (Key, error) {
+	return nil, ErrNotSupported
+}
@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	apiv1 "github.com/tmax-cloud/image-signing-operator/api/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var log logr.Logger = ctrl.Log.WithName("metrics")
+
+var (
+	signersDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "signers"),
+		"Number of signers",
+		[]string{"kind"}, nil,
+	)
+	targetKeysDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "target_keys"),
+		"Number of target keys of the signer",
+		[]string{"signer"}, nil,
+	)
+	keyAgeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "root_key_age_seconds"),
+		"Age of the root key of the signer, since it is generated. Imported root keys are not collected",
+		[]string{"signer"}, nil,
+	)
+)
+
+// InventoryCollector collects signers and their keys when metrics are scraped
+type InventoryCollector struct {
+	client client.Client
+	now    func() time.Time
+}
+
+// NewInventoryCollector creates a collector which reads signers and keys with the client
+func NewInventoryCollector(c client.Client) *InventoryCollector {
+	return &InventoryCollector{client: c, now: time.Now}
+}
+
+func (i *InventoryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- signersDesc
+	ch <- targetKeysDesc
+	ch <- keyAgeDesc
+}
+
+func (i *InventoryCollector) Collect(ch chan<- prometheus.Metric) {
+	signers := &apiv1.ImageSignerList{}
+	if err := i.client.List(context.TODO(), signers); err != nil {
+		log.Error(err, "cannot list image signers")
+	} else {
+		ch <- prometheus.MustNewConstMetric(signersDesc, prometheus.GaugeValue, float64(len(signers.Items)), apiv1.SignerKindImageSigner)
+	}
+
+	nsSigners := &apiv1.NamespaceImageSignerList{}
+	if err := i.client.List(context.TODO(), nsSigners); err != nil {
+		log.Error(err, "cannot list namespace image signers")
+	} else {
+		ch <- prometheus.MustNewConstMetric(signersDesc, prometheus.GaugeValue, float64(len(nsSigners.Items)), apiv1.SignerKindNamespaceImageSigner)
+	}
+
+	keys := &apiv1.SignerKeyList{}
+	if err := i.client.List(context.TODO(), keys); err != nil {
+		log.Error(err, "cannot list signer keys")
+	} else {
+		for idx := range keys.Items {
+			i.collectKey(ch, &keys.Items[idx])
+		}
+	}
+
+	nsKeys := &apiv1.NamespaceSignerKeyList{}
+	if err := i.client.List(context.TODO(), nsKeys); err != nil {
+		log.Error(err, "cannot list namespace signer keys")
+	} else {
+		for idx := range nsKeys.Items {
+			i.collectKey(ch, &nsKeys.Items[idx])
+		}
+	}
+}
+
+// collectKey collects metrics of the key, which has the same name as its signer
+func (i *InventoryCollector) collectKey(ch chan<- prometheus.Metric, key apiv1.Key) {
+	signer := key.GetName()
+	if len(key.GetNamespace()) > 0 {
+		signer = key.GetNamespace() + "/" + signer
+	}
+
+	ch <- prometheus.MustNewConstMetric(targetKeysDesc, prometheus.GaugeValue, float64(len(key.KeySpec().Targets)), signer)
+	// the creation time of an imported key is unknown
+	if createdAt := key.KeySpec().Root.CreatedAt; createdAt != nil {
+		ch <- prometheus.MustNewConstMetric(keyAgeDesc, prometheus.GaugeValue, i.now().Sub(createdAt.Time).Seconds(), signer)
+	}
+}
+
+// RegisterQueue registers gauges of the signing queue
+func RegisterQueue(registry prometheus.Registerer, waiting, running func() int) error {
+	if err := registry.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "queue_depth",
+		Help:      "Number of sign requests waiting for a signing session",
+	}, func() float64 { return float64(waiting()) })); err != nil {
+		return err
+	}
+
+	return registry.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "running_sessions",
+		Help:      "Number of running signing sessions",
+	}, func() float64 { return float64(running()) }))
+}
@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	apiv1 "github.com/tmax-cloud/image-signing-operator/api/v1"
+)
+
+func TestInventoryCollector(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := apiv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	generated := metav1.NewTime(now.Add(-time.Hour))
+	c := fake.NewFakeClientWithScheme(scheme,
+		&apiv1.ImageSigner{ObjectMeta: metav1.ObjectMeta{Name: "signer"}},
+		&apiv1.NamespaceImageSigner{ObjectMeta: metav1.ObjectMeta{Name: "team-signer", Namespace: "team"}},
+		// the signer key is created long after its root key, e.g., restored from a backup
+		&apiv1.SignerKey{
+			ObjectMeta: metav1.ObjectMeta{Name: "signer", CreationTimestamp: metav1.NewTime(now.Add(-time.Minute))},
+			Spec: apiv1.SignerKeySpec{
+				Root:    apiv1.TrustKey{ID: "root", CreatedAt: &generated},
+				Targets: map[string]apiv1.TrustKey{"myreg/team/app": {ID: "target"}},
+			},
+		},
+		// imported root key
+		&apiv1.NamespaceSignerKey{
+			ObjectMeta: metav1.ObjectMeta{Name: "team-signer", Namespace: "team", CreationTimestamp: metav1.NewTime(now.Add(-time.Minute))},
+			Spec:       apiv1.SignerKeySpec{Root: apiv1.TrustKey{ID: "root"}},
+		},
+	)
+
+	collector := NewInventoryCollector(c)
+	collector.now = func() time.Time { return now }
+
+	expected := `
+# HELP image_signing_root_key_age_seconds Age of the root key of the signer, since it is generated. Imported root keys are not collected
+# TYPE image_signing_root_key_age_seconds gauge
+image_signing_root_key_age_seconds{signer="signer"} 3600
+# HELP image_signing_signers Number of signers
+# TYPE image_signing_signers gauge
+image_signing_signers{kind="ImageSigner"} 1
+image_signing_signers{kind="NamespaceImageSigner"} 1
+# HELP image_signing_target_keys Number of target keys of the signer
+# TYPE image_signing_target_keys gauge
+image_signing_target_keys{signer="signer"} 1
+image_signing_target_keys{signer="team/team-signer"} 0
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"path"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	apiv1 "github.com/tmax-cloud/image-signing-operator/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const namespace = "image_signing"
+
+const (
+	// Steps of a signing session
+	StepLoad      = "load"
+	StepTag       = "tag"
+	StepAddSigner = "add_signer"
+	StepSign      = "sign"
+
+	// RoleDelegation is a role label of delegation keys
+	RoleDelegation = "delegation"
+
+	// ReasonError is a reason label of failures whose reason is an error message
+	ReasonError = "Error"
+)
+
+var (
+	// SignRequests counts completed sign requests by signer, result and failure reason
+	SignRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sign_requests_total",
+		Help:      "Number of completed image sign requests",
+	}, []string{"signer", "result", "reason"})
+
+	// SignDuration is a duration of completed sign requests, including the time they wait for approval and a session
+	SignDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "sign_duration_seconds",
+		Help:      "Duration of signing an image, from the creation of the request to its result",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 10),
+	}, []string{"signer", "result"})
+
+	// WorkerStartupDuration is a duration until a signing pod is ready for a session
+	WorkerStartupDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "worker_startup_duration_seconds",
+		Help:      "Duration until a signing pod is ready for a session",
+		Buckets:   prometheus.ExponentialBuckets(0.25, 2, 10),
+	}, []string{"pooled"})
+
+	// StepDuration is a duration of each step of a signing session
+	StepDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "step_duration_seconds",
+		Help:      "Duration of docker commands in a signing session",
+		Buckets:   prometheus.ExponentialBuckets(0.25, 2, 10),
+	}, []string{"step"})
+
+	// KeyGenerations counts generated keys by signer and role
+	KeyGenerations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "key_generations_total",
+		Help:      "Number of generated trust keys",
+	}, []string{"signer", "role"})
+)
+
+// knownReasons are reasons which are used as label values. Other reasons are error messages
+var knownReasons = map[string]bool{
+	apiv1.ResponseReasonAccessDenied:        true,
+	apiv1.ResponseReasonRejected:            true,
+	apiv1.ResponseReasonNoTargetKey:         true,
+	apiv1.ResponseReasonNoSnapshotKey:       true,
+	apiv1.ResponseReasonNotaryUnavailable:   true,
+	apiv1.ResponseReasonNoCredential:        true,
+	apiv1.ResponseReasonRegistryUnavailable: true,
+}
+
+func init() {
+	metrics.Registry.MustRegister(
+		SignRequests,
+		SignDuration,
+		WorkerStartupDuration,
+		StepDuration,
+		KeyGenerations,
+	)
+}
+
+// SignerLabel returns a label value of the signer, name for ImageSigner and namespace/name for NamespaceImageSigner
+func SignerLabel(signer apiv1.Signer) string {
+	return path.Join(signer.GetNamespace(), signer.GetName())
+}
+
+// RequestSignerLabel returns a label value of the request's signer
+func RequestSignerLabel(signReq *apiv1.ImageSignRequest) string {
+	key := signReq.SignerObjectKey()
+	return path.Join(key.Namespace, key.Name)
+}
+
+// ObserveResponse records the result of the request, if it is completed
+func ObserveResponse(signReq *apiv1.ImageSignRequest) {
+	observeResponse(signReq, time.Now())
+}
+
+func observeResponse(signReq *apiv1.ImageSignRequest, now time.Time) {
+	res := signReq.Status.ImageSignResponse
+	if res == nil || (res.Result != apiv1.ResponseResultSuccess && res.Result != apiv1.ResponseResultFail) {
+		return
+	}
+
+	reason := ""
+	if res.Result == apiv1.ResponseResultFail {
+		reason = ReasonError
+		if knownReasons[res.Reason] {
+			reason = res.Reason
+		}
+	}
+
+	signer := RequestSignerLabel(signReq)
+	SignRequests.WithLabelValues(signer, string(res.Result), reason).Inc()
+	SignDuration.WithLabelValues(signer, string(res.Result)).Observe(now.Sub(signReq.CreationTimestamp.Time).Seconds())
+}
+
+// ObserveStep records the duration of the step since start
+func ObserveStep(step string, start time.Time) {
+	StepDuration.WithLabelValues(step).Observe(time.Since(start).Seconds())
+}
+
+// ObserveWorkerStartup records the duration until a pod is ready since start
+func ObserveWorkerStartup(pooled bool, start time.Time) {
+	label := "false"
+	if pooled {
+		label = "true"
+	}
+	WorkerStartupDuration.WithLabelValues(label).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	apiv1 "github.com/tmax-cloud/image-signing-operator/api/v1"
+)
+
+func TestObserveResponse(t *testing.T) {
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	newRequest := func(name string, result apiv1.ResponseResult) *apiv1.ImageSignRequest {
+		signReq := &apiv1.ImageSignRequest{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "team", CreationTimestamp: metav1.NewTime(now.Add(-3 * time.Second))},
+			Spec:       apiv1.ImageSignRequestSpec{Signer: "signer"},
+		}
+		if len(result) > 0 {
+			signReq.Status.ImageSignResponse = &apiv1.ImageSignResponse{Result: result}
+		}
+		return signReq
+	}
+
+	// the duration includes the time before the last reconcile, e.g., waiting for a session
+	observeResponse(newRequest("done", apiv1.ResponseResultSuccess), now)
+	// requests which are not completed are not observed
+	observeResponse(newRequest("queued", apiv1.ResponseResultQueued), now)
+	observeResponse(newRequest("new", ""), now)
+
+	expected := `
+# HELP image_signing_sign_duration_seconds Duration of signing an image, from the creation of the request to its result
+# TYPE image_signing_sign_duration_seconds histogram
+image_signing_sign_duration_seconds_bucket{result="Success",signer="signer",le="1"} 0
+image_signing_sign_duration_seconds_bucket{result="Success",signer="signer",le="2"} 0
+image_signing_sign_duration_seconds_bucket{result="Success",signer="signer",le="4"} 1
+image_signing_sign_duration_seconds_bucket{result="Success",signer="signer",le="8"} 1
+image_signing_sign_duration_seconds_bucket{result="Success",signer="signer",le="16"} 1
+image_signing_sign_duration_seconds_bucket{result="Success",signer="signer",le="32"} 1
+image_signing_sign_duration_seconds_bucket{result="Success",signer="signer",le="64"} 1
+image_signing_sign_duration_seconds_bucket{result="Success",signer="signer",le="128"} 1
+image_signing_sign_duration_seconds_bucket{result="Success",signer="signer",le="256"} 1
+image_signing_sign_duration_seconds_bucket{result="Success",signer="signer",le="512"} 1
+image_signing_sign_duration_seconds_bucket{result="Success",signer="signer",le="+Inf"} 1
+image_signing_sign_duration_seconds_sum{result="Success",signer="signer"} 3
+image_signing_sign_duration_seconds_count{result="Success",signer="signer"} 1
+`
+	if err := testutil.CollectAndCompare(SignDuration, strings.NewReader(expected)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,161 @@
+package reference
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// DefaultTag is used if the reference has neither tag nor digest
+	DefaultTag = "latest"
+
+	maxNameLength = 255
+
+	// DefaultDomain is the domain of repositories without a domain, as docker does
+	DefaultDomain = "docker.io"
+	// officialRepositoryPrefix is the path prefix of docker hub repositories which have a single component
+	officialRepositoryPrefix = "library"
+)
+
+// defaultDomainAliases are other domains of docker hub, which docker normalizes to DefaultDomain
+var defaultDomainAliases = []string{"index.docker.io", "registry-1.docker.io"}
+
+var (
+	// domain is host[:port], e.g., myreg:5000, registry.example.com, [::1]:5000
+	domainRegexp = regexp.MustCompile(`^(?:[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?)*|\[[a-fA-F0-9:]+\])(?::[0-9]+)?$`)
+	// path component, e.g., library, my_team, app-1
+	componentRegexp = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|[-]*)[a-z0-9]+)*$`)
+	tagRegexp       = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+	digestRegexp    = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]{32,}$`)
+)
+
+// Reference is an image reference, [domain[:port]/]path[:tag][@digest]
+type Reference struct {
+	// Domain is host[:port] of the registry. It is empty if the reference does not have it
+	Domain string
+	// Path is a repository path in the registry, e.g., library/alpine
+	Path string
+	// Tag is DefaultTag if the reference has neither tag nor digest
+	Tag    string
+	Digest string
+}
+
+// Parse parses the image reference. If it has neither tag nor digest, DefaultTag is used
+func Parse(s string) (*Reference, error) {
+	ref, err := parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ref.Tag) == 0 && len(ref.Digest) == 0 {
+		ref.Tag = DefaultTag
+	}
+
+	return ref, nil
+}
+
+// ParseRepository parses the repository, which should have neither tag nor digest.
+// The repository is normalized as docker does (e.g., alpine -> docker.io/library/alpine), so that its name is
+// the same as the name of the trust data, and always has a domain
+func ParseRepository(s string) (*Reference, error) {
+	ref, err := parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ref.Tag) > 0 || len(ref.Digest) > 0 {
+		return nil, fmt.Errorf("repository %s should not have tag or digest", s)
+	}
+
+	ref.normalize()
+	return ref, nil
+}
+
+// normalize sets DefaultDomain to references of docker hub, and prefixes their official repositories
+func (r *Reference) normalize() {
+	for _, alias := range defaultDomainAliases {
+		if r.Domain == alias {
+			r.Domain = DefaultDomain
+		}
+	}
+	if len(r.Domain) == 0 {
+		r.Domain = DefaultDomain
+	}
+	if r.Domain == DefaultDomain && !strings.Contains(r.Path, "/") {
+		r.Path = officialRepositoryPrefix + "/" + r.Path
+	}
+}
+
+func parse(s string) (*Reference, error) {
+	if len(s) == 0 {
+		return nil, fmt.Errorf("image reference is empty")
+	}
+
+	ref := &Reference{}
+	remainder := s
+
+	if i := strings.Index(remainder, "@"); i >= 0 {
+		ref.Digest = remainder[i+1:]
+		remainder = remainder[:i]
+		if !digestRegexp.MatchString(ref.Digest) {
+			return nil, fmt.Errorf("image reference %s has invalid digest", s)
+		}
+	}
+
+	// tag is after the last colon, only if the colon is after the last slash (not a port)
+	if i := strings.LastIndex(remainder, ":"); i >= 0 && i > strings.LastIndex(remainder, "/") {
+		ref.Tag = remainder[i+1:]
+		remainder = remainder[:i]
+		if !tagRegexp.MatchString(ref.Tag) {
+			return nil, fmt.Errorf("image reference %s has invalid tag", s)
+		}
+	}
+
+	// the first component is a domain if it looks like a host, as docker does
+	if i := strings.Index(remainder, "/"); i >= 0 {
+		first := remainder[:i]
+		if strings.ContainsAny(first, ".:") || first == "localhost" || strings.ToLower(first) != first {
+			ref.Domain = first
+			remainder = remainder[i+1:]
+			if !domainRegexp.MatchString(ref.Domain) {
+				return nil, fmt.Errorf("image reference %s has invalid domain", s)
+			}
+		}
+	}
+
+	ref.Path = remainder
+	if len(ref.Path) == 0 {
+		return nil, fmt.Errorf("image reference %s has no repository", s)
+	}
+	for _, component := range strings.Split(ref.Path, "/") {
+		if !componentRegexp.MatchString(component) {
+			return nil, fmt.Errorf("image reference %s has invalid repository %s", s, ref.Path)
+		}
+	}
+	if len(ref.Name()) > maxNameLength {
+		return nil, fmt.Errorf("repository name of %s is longer than %d characters", s, maxNameLength)
+	}
+
+	return ref, nil
+}
+
+// Name returns [domain/]path
+func (r *Reference) Name() string {
+	if len(r.Domain) == 0 {
+		return r.Path
+	}
+	return r.Domain + "/" + r.Path
+}
+
+// String returns [domain/]path[:tag][@digest]
+func (r *Reference) String() string {
+	s := r.Name()
+	if len(r.Tag) > 0 {
+		s += ":" + r.Tag
+	}
+	if len(r.Digest) > 0 {
+		s += "@" + r.Digest
+	}
+	return s
+}
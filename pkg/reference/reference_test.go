@@ -0,0 +1,103 @@
+package reference
+
+import (
+	"testing"
+)
+
+const testDigest = "sha256:4c1e997385b8fb4ad4d1d3c7e5af7ff3f882e4d0d7b8a6a0d4f2c9c77d3f4f5e"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		ref       string
+		expected  Reference
+		expectErr bool
+	}{
+		{name: "name only", ref: "alpine", expected: Reference{Path: "alpine", Tag: DefaultTag}},
+		{name: "name and tag", ref: "alpine:3", expected: Reference{Path: "alpine", Tag: "3"}},
+		{name: "path", ref: "library/alpine:3.12", expected: Reference{Path: "library/alpine", Tag: "3.12"}},
+		{name: "domain", ref: "docker.io/library/alpine", expected: Reference{Domain: "docker.io", Path: "library/alpine", Tag: DefaultTag}},
+		{name: "domain with port", ref: "myreg:5000/app:1.0", expected: Reference{Domain: "myreg:5000", Path: "app", Tag: "1.0"}},
+		{name: "domain with port without tag", ref: "myreg:5000/team/app", expected: Reference{Domain: "myreg:5000", Path: "team/app", Tag: DefaultTag}},
+		{name: "localhost", ref: "localhost/app:v1", expected: Reference{Domain: "localhost", Path: "app", Tag: "v1"}},
+		{name: "ipv6", ref: "[::1]:5000/app:v1", expected: Reference{Domain: "[::1]:5000", Path: "app", Tag: "v1"}},
+		{name: "digest", ref: "app@" + testDigest, expected: Reference{Path: "app", Digest: testDigest}},
+		{name: "tag and digest", ref: "myreg:5000/app:1.0@" + testDigest, expected: Reference{Domain: "myreg:5000", Path: "app", Tag: "1.0", Digest: testDigest}},
+		{name: "separators", ref: "my-reg.example.com/my_team/app-1.x__y:v1_2-3", expected: Reference{Domain: "my-reg.example.com", Path: "my_team/app-1.x__y", Tag: "v1_2-3"}},
+		{name: "empty", ref: "", expectErr: true},
+		{name: "uppercase path", ref: "myreg/App", expectErr: true},
+		{name: "empty tag", ref: "app:", expectErr: true},
+		{name: "invalid tag", ref: "app:-v1", expectErr: true},
+		{name: "invalid digest", ref: "app@sha256:1234", expectErr: true},
+		{name: "no path", ref: "myreg:5000/", expectErr: true},
+		{name: "invalid domain", ref: "my_reg.com/app", expectErr: true},
+		{name: "double slash", ref: "myreg.com//app", expectErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ref, err := Parse(tc.ref)
+			if tc.expectErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", ref)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if *ref != tc.expected {
+				t.Fatalf("expected %+v, got %+v", tc.expected, *ref)
+			}
+		})
+	}
+}
+
+func TestParseRepository(t *testing.T) {
+	tests := []struct {
+		name      string
+		repo      string
+		expected  string
+		expectErr bool
+	}{
+		{name: "docker hub", repo: "docker.io/myteam/app", expected: "docker.io/myteam/app"},
+		{name: "port", repo: "myreg:5000/app", expected: "myreg:5000/app"},
+		{name: "no domain", repo: "myteam/app", expected: "docker.io/myteam/app"},
+		{name: "official", repo: "alpine", expected: "docker.io/library/alpine"},
+		{name: "official with domain", repo: "docker.io/alpine", expected: "docker.io/library/alpine"},
+		{name: "docker hub alias", repo: "index.docker.io/myteam/app", expected: "docker.io/myteam/app"},
+		{name: "single component", repo: "myreg:5000/app", expected: "myreg:5000/app"},
+		{name: "tag", repo: "myreg:5000/app:1.0", expectErr: true},
+		{name: "digest", repo: "myreg:5000/app@" + testDigest, expectErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ref, err := ParseRepository(tc.repo)
+			if tc.expectErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", ref)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ref.Name() != tc.expected {
+				t.Fatalf("expected %s, got %s", tc.expected, ref.Name())
+			}
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	for _, s := range []string{"alpine:latest", "myreg:5000/team/app:1.0", "app@" + testDigest, "myreg:5000/app:1.0@" + testDigest} {
+		ref, err := Parse(s)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if ref.String() != s {
+			t.Fatalf("expected %s, got %s", s, ref.String())
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package registry
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	apiv1 "github.com/tmax-cloud/image-signing-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DockerHubHost is used for references without a domain
+	DockerHubHost = "docker.io"
+	// DockerHubConfigKey is the key of docker hub in docker config files
+	DockerHubConfigKey = "https://index.docker.io/v1/"
+
+	DefaultServiceAccount = "default"
+)
+
+var dockerHubAliases = []string{DockerHubHost, "index.docker.io", "registry-1.docker.io", "registry.hub.docker.com"}
+
+// Credential is a credential of a registry host
+type Credential struct {
+	Host     string
+	Username string
+	Password string
+}
+
+// DockerConfig returns the docker config.json which has the credential
+func (c *Credential) DockerConfig() ([]byte, error) {
+	key := c.Host
+	if isDockerHub(key) {
+		key = DockerHubConfigKey
+	}
+
+	return json.Marshal(&dockerConfig{Auths: map[string]dockerAuth{
+		key: {Auth: base64.StdEncoding.EncodeToString([]byte(c.Username + ":" + c.Password))},
+	}})
+}
+
+// CredentialSource finds the credential of a registry host.
+// It returns nil without error if it does not have the credential of the host
+type CredentialSource interface {
+	Credential(host string) (*Credential, error)
+	// String describes the source for errors
+	String() string
+}
+
+// CredentialChain finds the credential from the sources in order
+type CredentialChain []CredentialSource
+
+// Resolve returns the credential of the first source which has the credential of the host.
+// It returns nil without error if no source has the credential, so that the image is pushed anonymously
+func (chain CredentialChain) Resolve(host string) (*Credential, error) {
+	for _, src := range chain {
+		cred, err := src.Credential(host)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get credential from %s: %v", src, err)
+		}
+		if cred != nil {
+			return cred, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// NewCredentialChain returns the credential sources of the request, in the order of
+// the request's secret, the Registry object of the registry login and the imagePullSecrets of the default service account.
+// Every source is in the request's namespace, so that a request cannot use the login of a Registry in another namespace
+func NewCredentialChain(c client.Client, signReq *apiv1.ImageSignRequest) CredentialChain {
+	chain := CredentialChain{}
+
+	secretName := signReq.Spec.CredentialsSecret
+	if len(secretName) == 0 {
+		secretName = signReq.Spec.RegistryLogin.DcjSecretName
+	}
+	if len(secretName) > 0 {
+		chain = append(chain, &SecretSource{client: c, name: secretName, namespace: signReq.Namespace})
+	}
+
+	login := signReq.Spec.RegistryLogin
+	if len(signReq.Spec.Repository) == 0 && len(login.Name) > 0 && login.Namespace == signReq.Namespace {
+		chain = append(chain, &RegistrySource{client: c, name: login.Name, namespace: login.Namespace})
+	}
+
+	chain = append(chain, &ServiceAccountSource{client: c, name: DefaultServiceAccount, namespace: signReq.Namespace})
+
+	return chain
+}
+
+// SecretSource is a kubernetes.io/dockerconfigjson, kubernetes.io/dockercfg or kubernetes.io/basic-auth secret.
+// Basic auth secret is used for any host
+type SecretSource struct {
+	client          client.Client
+	name, namespace string
+}
+
+func (s *SecretSource) String() string {
+	return fmt.Sprintf("secret %s/%s", s.namespace, s.name)
+}
+
+func (s *SecretSource) Credential(host string) (*Credential, error) {
+	secret := &corev1.Secret{}
+	if err := s.client.Get(context.TODO(), types.NamespacedName{Name: s.name, Namespace: s.namespace}, secret); err != nil {
+		return nil, err
+	}
+
+	return credentialFromSecret(secret, host)
+}
+
+// RegistrySource is a login id and password of tmax Registry
+type RegistrySource struct {
+	client          client.Client
+	name, namespace string
+}
+
+func (r *RegistrySource) String() string {
+	return fmt.Sprintf("registry %s/%s", r.namespace, r.name)
+}
+
+func (r *RegistrySource) Credential(host string) (*Credential, error) {
+	reg, err := getRegistry(r.client, r.name, r.namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	regCtl := &RegCtl{client: r.client, reg: reg}
+	if !sameHost(regCtl.GetEndpoint(), host) || len(reg.Spec.LoginId) == 0 {
+		return nil, nil
+	}
+
+	return &Credential{Host: host, Username: reg.Spec.LoginId, Password: reg.Spec.LoginPassword}, nil
+}
+
+// ServiceAccountSource is imagePullSecrets of a service account
+type ServiceAccountSource struct {
+	client          client.Client
+	name, namespace string
+}
+
+func (s *ServiceAccountSource) String() string {
+	return fmt.Sprintf("imagePullSecrets of service account %s/%s", s.namespace, s.name)
+}
+
+func (s *ServiceAccountSource) Credential(host string) (*Credential, error) {
+	sa := &corev1.ServiceAccount{}
+	if err := s.client.Get(context.TODO(), types.NamespacedName{Name: s.name, Namespace: s.namespace}, sa); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	for _, ref := range sa.ImagePullSecrets {
+		secret := &corev1.Secret{}
+		if err := s.client.Get(context.TODO(), types.NamespacedName{Name: ref.Name, Namespace: s.namespace}, secret); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		// basic auth secrets are not image pull secrets
+		if secret.Type == corev1.SecretTypeBasicAuth {
+			continue
+		}
+		cred, err := credentialFromSecret(secret, host)
+		if err != nil {
+			return nil, err
+		}
+		if cred != nil {
+			return cred, nil
+		}
+	}
+
+	return nil, nil
+}
+
+type dockerConfig struct {
+	Auths map[string]dockerAuth `json:"auths"`
+}
+
+type dockerAuth struct {
+	Auth     string `json:"auth,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+func credentialFromSecret(secret *corev1.Secret, host string) (*Credential, error) {
+	var auths map[string]dockerAuth
+
+	switch secret.Type {
+	case corev1.SecretTypeBasicAuth:
+		return &Credential{
+			Host:     host,
+			Username: string(secret.Data[corev1.BasicAuthUsernameKey]),
+			Password: string(secret.Data[corev1.BasicAuthPasswordKey]),
+		}, nil
+	case corev1.SecretTypeDockerConfigJson:
+		config := &dockerConfig{}
+		if err := json.Unmarshal(secret.Data[corev1.DockerConfigJsonKey], config); err != nil {
+			return nil, fmt.Errorf("%s of secret %s/%s is malformed", corev1.DockerConfigJsonKey, secret.Namespace, secret.Name)
+		}
+		auths = config.Auths
+	case corev1.SecretTypeDockercfg:
+		if err := json.Unmarshal(secret.Data[corev1.DockerConfigKey], &auths); err != nil {
+			return nil, fmt.Errorf("%s of secret %s/%s is malformed", corev1.DockerConfigKey, secret.Namespace, secret.Name)
+		}
+	default:
+		return nil, fmt.Errorf("secret %s/%s has unsupported type %s", secret.Namespace, secret.Name, secret.Type)
+	}
+
+	for key, auth := range auths {
+		if !sameHost(key, host) {
+			continue
+		}
+
+		cred := &Credential{Host: host, Username: auth.Username, Password: auth.Password}
+		if len(auth.Auth) > 0 {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("auth of %s in secret %s/%s is malformed", key, secret.Namespace, secret.Name)
+			}
+			s := strings.SplitN(string(decoded), ":", 2)
+			if len(s) != 2 {
+				return nil, fmt.Errorf("auth of %s in secret %s/%s is malformed", key, secret.Namespace, secret.Name)
+			}
+			cred.Username, cred.Password = s[0], s[1]
+		}
+		return cred, nil
+	}
+
+	return nil, nil
+}
+
+// sameHost compares hosts of docker config keys, which may have scheme and path (e.g., https://index.docker.io/v1/)
+func sameHost(a, b string) bool {
+	a, b = normalizeHost(a), normalizeHost(b)
+	if isDockerHub(a) && isDockerHub(b) {
+		return true
+	}
+	return a == b
+}
+
+func normalizeHost(host string) string {
+	host = strings.TrimPrefix(strings.TrimPrefix(host, "https://"), "http://")
+	if i := strings.Index(host, "/"); i >= 0 {
+		host = host[:i]
+	}
+	return strings.ToLower(host)
+}
+
+func isDockerHub(host string) bool {
+	host = normalizeHost(host)
+	for _, alias := range dockerHubAliases {
+		if host == alias {
+			return true
+		}
+	}
+	return false
+}
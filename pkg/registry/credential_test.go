@@ -0,0 +1,79 @@
+package registry
+
+import (
+	"testing"
+
+	apiv1 "github.com/tmax-cloud/image-signing-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const testHost = "myreg.example.com"
+
+func testRegistry(namespace string) *apiv1.Registry {
+	return &apiv1.Registry{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "reg",
+			Namespace:   namespace,
+			Annotations: map[string]string{apiv1.RegistryLoginUrl: "https://" + testHost},
+		},
+		Spec: apiv1.RegistrySpec{LoginId: "admin", LoginPassword: "secret"},
+	}
+}
+
+func TestCredentialChain(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = apiv1.AddToScheme(scheme)
+
+	basicAuth := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "login", Namespace: "team"},
+		Type:       corev1.SecretTypeBasicAuth,
+		Data:       map[string][]byte{corev1.BasicAuthUsernameKey: []byte("bot"), corev1.BasicAuthPasswordKey: []byte("token")},
+	}
+
+	tc := map[string]struct {
+		objects  []runtime.Object
+		spec     apiv1.ImageSignRequestSpec
+		expected *Credential
+	}{
+		"secret": {
+			objects:  []runtime.Object{basicAuth},
+			spec:     apiv1.ImageSignRequestSpec{CredentialsSecret: "login"},
+			expected: &Credential{Host: testHost, Username: "bot", Password: "token"},
+		},
+		"registryInNamespace": {
+			objects:  []runtime.Object{testRegistry("team")},
+			spec:     apiv1.ImageSignRequestSpec{RegistryLogin: apiv1.RegistryLogin{Name: "reg", Namespace: "team"}},
+			expected: &Credential{Host: testHost, Username: "admin", Password: "secret"},
+		},
+		"registryInOtherNamespace": {
+			objects: []runtime.Object{testRegistry("other")},
+			spec:    apiv1.ImageSignRequestSpec{RegistryLogin: apiv1.RegistryLogin{Name: "reg", Namespace: "other"}},
+		},
+		"anonymous": {},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			signReq := &apiv1.ImageSignRequest{ObjectMeta: metav1.ObjectMeta{Name: "req", Namespace: "team"}, Spec: c.spec}
+			chain := NewCredentialChain(fake.NewFakeClientWithScheme(scheme, c.objects...), signReq)
+
+			cred, err := chain.Resolve(testHost)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.expected == nil {
+				if cred != nil {
+					t.Fatalf("expected no credential, got %+v", cred)
+				}
+				return
+			}
+			if cred == nil || *cred != *c.expected {
+				t.Errorf("expected %+v, got %+v", c.expected, cred)
+			}
+		})
+	}
+}
@@ -44,7 +44,9 @@ func NewRegCtl(c client.Client, regName, namespace string) *RegCtl {
 func (r *RegCtl) GetEndpoint() string {
 	for k, v := range r.reg.Annotations {
 		if k == apiv1.RegistryLoginUrl {
-			return strings.TrimLeft(v, "https://")
+			// login url is scheme://host[:port], but docker needs host[:port]
+			endpoint := strings.TrimPrefix(strings.TrimPrefix(v, "https://"), "http://")
+			return strings.TrimSuffix(endpoint, "/")
 		}
 	}
 
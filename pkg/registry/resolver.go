@@ -0,0 +1,71 @@
+package registry
+
+import (
+	"fmt"
+	"path"
+
+	apiv1 "github.com/tmax-cloud/image-signing-operator/api/v1"
+	"github.com/tmax-cloud/image-signing-operator/pkg/reference"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Resolver resolves the repository which signed images are pushed to
+type Resolver interface {
+	// Repository returns the fully-qualified repository of the image (e.g., myreg:5000/team/app)
+	Repository(imageName string) (string, error)
+}
+
+// NewResolver returns a resolver of the request.
+// Repository of the request is used as it is, otherwise the Registry of the registry login is used
+func NewResolver(c client.Client, signReq *apiv1.ImageSignRequest) (Resolver, error) {
+	if len(signReq.Spec.Repository) > 0 {
+		repo, err := reference.ParseRepository(signReq.Spec.Repository)
+		if err != nil {
+			return nil, err
+		}
+		return StaticRepository(repo.Name()), nil
+	}
+
+	login := signReq.Spec.RegistryLogin
+	if len(login.Name) == 0 || len(login.Namespace) == 0 {
+		return nil, fmt.Errorf("either repository or registry login should be given")
+	}
+
+	regCtl := NewRegCtl(c, login.Name, login.Namespace)
+	if regCtl == nil {
+		return nil, fmt.Errorf("cannot get registry %s/%s", login.Namespace, login.Name)
+	}
+
+	return regCtl, nil
+}
+
+// RepositoryHost returns the registry host of the image, which is docker hub if the repository has no domain
+func RepositoryHost(r Resolver, imageName string) (string, error) {
+	repository, err := r.Repository(imageName)
+	if err != nil {
+		return "", err
+	}
+	ref, err := reference.ParseRepository(repository)
+	if err != nil {
+		return "", err
+	}
+
+	return ref.Domain, nil
+}
+
+// StaticRepository is a fully-qualified repository of an external registry (e.g., Harbor, Docker Hub, registry:2)
+type StaticRepository string
+
+func (s StaticRepository) Repository(_ string) (string, error) {
+	return string(s), nil
+}
+
+// Repository returns the image in the registry's endpoint
+func (r *RegCtl) Repository(imageName string) (string, error) {
+	endpoint := r.GetEndpoint()
+	if len(endpoint) == 0 {
+		return "", fmt.Errorf("registry %s/%s has no %s annotation", r.reg.Namespace, r.reg.Name, apiv1.RegistryLoginUrl)
+	}
+
+	return path.Join(endpoint, imageName), nil
+}
@@ -0,0 +1,103 @@
+package registry
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	pingTimeout = 10 * time.Second
+)
+
+// TLS is TLS configuration of a registry
+type TLS struct {
+	// CA is a PEM encoded CA bundle. If it is empty, system CAs are used
+	CA []byte
+	// Cert and Key are PEM encoded client certificate and private key
+	Cert, Key          []byte
+	InsecureSkipVerify bool
+	ServerName         string
+}
+
+// Config returns tls.Config for the registry
+func (t *TLS) Config() (*tls.Config, error) {
+	config := &tls.Config{
+		InsecureSkipVerify: t.InsecureSkipVerify,
+		ServerName:         t.ServerName,
+	}
+
+	if len(t.CA) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(t.CA) {
+			return nil, fmt.Errorf("CA bundle of the registry is not valid")
+		}
+		config.RootCAs = pool
+	}
+
+	if len(t.Cert) > 0 || len(t.Key) > 0 {
+		cert, err := tls.X509KeyPair(t.Cert, t.Key)
+		if err != nil {
+			return nil, fmt.Errorf("client certificate of the registry is not valid: %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// HTTPClient returns a http client for the registry
+func (t *TLS) HTTPClient() (*http.Client, error) {
+	config := &tls.Config{}
+	if t != nil {
+		var err error
+		config, err = t.Config()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &http.Client{
+		Timeout:   pingTimeout,
+		Transport: &http.Transport{TLSClientConfig: config, Proxy: http.ProxyFromEnvironment},
+	}, nil
+}
+
+// Ping returns error if the registry API (/v2/) of the host is not reachable.
+// Insecure registries may be served by plain http, as docker does
+func Ping(host string, t *TLS) error {
+	cli, err := t.HTTPClient()
+	if err != nil {
+		return err
+	}
+
+	if isDockerHub(host) {
+		host = "registry-1.docker.io"
+	}
+
+	err = ping(cli, "https://"+host+"/v2/")
+	if err != nil && t != nil && t.InsecureSkipVerify {
+		if httpErr := ping(cli, "http://"+host+"/v2/"); httpErr == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+func ping(cli *http.Client, url string) error {
+	resp, err := cli.Get(url)
+	if err != nil {
+		return fmt.Errorf("registry %s is not reachable: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	// 401 means the registry requires authentication, which is checked by docker
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusUnauthorized {
+		return fmt.Errorf("registry %s is not available: %s", url, resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,202 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DefaultLockDuration is a duration after which a lock which is not renewed can be taken by another session,
+	// e.g., if the operator holding it is stopped
+	DefaultLockDuration = time.Minute
+
+	// RepositoryAnnotation is the repository of the lease
+	RepositoryAnnotation = "image-signing.tmax.io/repository"
+
+	leaseNamePrefix = "image-signing-repository-"
+)
+
+var log = ctrl.Log.WithName("scheduler")
+
+// RepositoryLocks are locks of repositories, backed by a Lease per repository,
+// so that only one session of any replica of the operator writes the trust data of a repository at a time
+type RepositoryLocks struct {
+	client    client.Client
+	namespace string
+	// identity is the operator's, e.g., the pod name
+	identity string
+	duration time.Duration
+	now      func() time.Time
+}
+
+// RepositoryLock is a held lock, which is renewed until it is unlocked
+type RepositoryLock struct {
+	locks *RepositoryLocks
+	key   types.NamespacedName
+	// holder is the holder identity of the lease
+	holder string
+	stop   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+// NewRepositoryLocks creates locks whose leases are in the namespace.
+// The client should not read from a cache, as leases are written by other replicas
+func NewRepositoryLocks(c client.Client, namespace, identity string) *RepositoryLocks {
+	return &RepositoryLocks{
+		client:    c,
+		namespace: namespace,
+		identity:  identity,
+		duration:  DefaultLockDuration,
+		now:       time.Now,
+	}
+}
+
+// Lock acquires the lease of the repository for the holder (e.g., a request).
+// It returns nil without error if another holder has the lease which is not expired
+func (r *RepositoryLocks) Lock(repository, holder string) (*RepositoryLock, error) {
+	key := types.NamespacedName{Name: leaseName(repository), Namespace: r.namespace}
+	holder = r.identity + "/" + holder
+	now := metav1.NewMicroTime(r.now())
+	seconds := int32(r.duration / time.Second)
+
+	lease := &coordinationv1.Lease{}
+	if err := r.client.Get(context.TODO(), key, lease); err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, err
+		}
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        key.Name,
+				Namespace:   key.Namespace,
+				Annotations: map[string]string{RepositoryAnnotation: repository},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &holder,
+				LeaseDurationSeconds: &seconds,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}
+		if err := r.client.Create(context.TODO(), lease); err != nil {
+			if errors.IsAlreadyExists(err) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		return r.start(key, holder), nil
+	}
+
+	if r.held(lease) {
+		return nil, nil
+	}
+
+	lease.Spec.HolderIdentity = &holder
+	lease.Spec.LeaseDurationSeconds = &seconds
+	lease.Spec.AcquireTime = &now
+	lease.Spec.RenewTime = &now
+	// the update fails if another holder takes the lease first
+	if err := r.client.Update(context.TODO(), lease); err != nil {
+		if errors.IsConflict(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return r.start(key, holder), nil
+}
+
+// held returns true if the lease has a holder, and it is renewed within its duration
+func (r *RepositoryLocks) held(lease *coordinationv1.Lease) bool {
+	spec := lease.Spec
+	if spec.HolderIdentity == nil || len(*spec.HolderIdentity) == 0 || spec.RenewTime == nil {
+		return false
+	}
+	duration := r.duration
+	if spec.LeaseDurationSeconds != nil {
+		duration = time.Duration(*spec.LeaseDurationSeconds) * time.Second
+	}
+	return r.now().Before(spec.RenewTime.Add(duration))
+}
+
+func (r *RepositoryLocks) start(key types.NamespacedName, holder string) *RepositoryLock {
+	l := &RepositoryLock{
+		locks:  r,
+		key:    key,
+		holder: holder,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go l.renew()
+	return l
+}
+
+// renew renews the lease until the lock is unlocked, or the lease is taken by another holder
+func (l *RepositoryLock) renew() {
+	defer close(l.done)
+
+	ticker := time.NewTicker(l.locks.duration / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			err := l.update(func(lease *coordinationv1.Lease) {
+				now := metav1.NewMicroTime(l.locks.now())
+				lease.Spec.RenewTime = &now
+			})
+			if err != nil {
+				log.Error(err, "cannot renew repository lock", "lease", l.key)
+				return
+			}
+		}
+	}
+}
+
+// Unlock stops renewing the lease and releases it. It is safe to call it more than once
+func (l *RepositoryLock) Unlock() {
+	l.once.Do(func() {
+		close(l.stop)
+		<-l.done
+
+		err := l.update(func(lease *coordinationv1.Lease) {
+			lease.Spec.HolderIdentity = nil
+			lease.Spec.RenewTime = nil
+		})
+		if err != nil {
+			// the lease expires after its duration
+			log.Error(err, "cannot release repository lock", "lease", l.key)
+		}
+	})
+}
+
+// update changes the lease, only if it is still held by the lock
+func (l *RepositoryLock) update(change func(lease *coordinationv1.Lease)) error {
+	lease := &coordinationv1.Lease{}
+	if err := l.locks.client.Get(context.TODO(), l.key, lease); err != nil {
+		return err
+	}
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != l.holder {
+		return errors.NewConflict(coordinationv1.Resource("leases"), l.key.Name, fmt.Errorf("the lease is held by another holder"))
+	}
+
+	change(lease)
+	return l.locks.client.Update(context.TODO(), lease)
+}
+
+// leaseName is a valid object name of the repository, which may have characters such as ':' and '/'
+func leaseName(repository string) string {
+	sum := sha256.Sum256([]byte(repository))
+	return leaseNamePrefix + hex.EncodeToString(sum[:])[:32]
+}
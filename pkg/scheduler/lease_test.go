@@ -0,0 +1,80 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newTestLocks(t *testing.T) (*RepositoryLocks, *RepositoryLocks) {
+	scheme := runtime.NewScheme()
+	if err := coordinationv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewFakeClientWithScheme(scheme)
+	return NewRepositoryLocks(c, "registry-system", "operator-1"), NewRepositoryLocks(c, "registry-system", "operator-2")
+}
+
+func TestRepositoryLocks(t *testing.T) {
+	replica1, replica2 := newTestLocks(t)
+
+	lock, err := replica1.Lock("myreg:5000/team/app", "team/req1")
+	if err != nil || lock == nil {
+		t.Fatalf("expected the lock, got %v, %v", lock, err)
+	}
+	if other, err := replica2.Lock("myreg:5000/team/app", "team/req2"); err != nil || other != nil {
+		t.Fatalf("expected the lock to be held by the other replica, got %v, %v", other, err)
+	}
+	if other, err := replica2.Lock("myreg:5000/team/other", "team/req3"); err != nil || other == nil {
+		t.Fatalf("expected the lock of another repository, got %v, %v", other, err)
+	} else {
+		other.Unlock()
+	}
+
+	lock.Unlock()
+	lock.Unlock()
+	other, err := replica2.Lock("myreg:5000/team/app", "team/req2")
+	if err != nil || other == nil {
+		t.Fatalf("expected the lock after unlock, got %v, %v", other, err)
+	}
+	other.Unlock()
+}
+
+func TestRepositoryLocksExpired(t *testing.T) {
+	replica1, replica2 := newTestLocks(t)
+
+	lock, err := replica1.Lock("docker.io/team/app", "team/req1")
+	if err != nil || lock == nil {
+		t.Fatalf("expected the lock, got %v, %v", lock, err)
+	}
+	// replica1 stops without unlocking
+	close(lock.stop)
+	<-lock.done
+
+	replica2.now = func() time.Time { return time.Now().Add(DefaultLockDuration + time.Second) }
+	other, err := replica2.Lock("docker.io/team/app", "team/req2")
+	if err != nil || other == nil {
+		t.Fatalf("expected the expired lock to be taken, got %v, %v", other, err)
+	}
+	defer other.Unlock()
+
+	lease := &coordinationv1.Lease{}
+	key := types.NamespacedName{Name: leaseName("docker.io/team/app"), Namespace: "registry-system"}
+	if err := replica2.client.Get(context.TODO(), key, lease); err != nil {
+		t.Fatal(err)
+	}
+	if holder := *lease.Spec.HolderIdentity; holder != "operator-2/team/req2" {
+		t.Errorf("expected the lease to be held by operator-2, got %s", holder)
+	}
+
+	// the stale lock does not release the lease of the new holder
+	lock.once.Do(func() {})
+	if err := lock.update(func(*coordinationv1.Lease) {}); err == nil {
+		t.Error("expected the stale lock not to update the lease")
+	}
+}
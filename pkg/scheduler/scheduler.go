@@ -0,0 +1,305 @@
+package scheduler
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// DefaultExpiry is a duration after which a waiting session which is not acquired again is removed from the queue
+	DefaultExpiry = time.Minute
+)
+
+// Session is a signing session of a request
+type Session struct {
+	Request types.NamespacedName
+	// Signer identifies the signer of the session, e.g., kind/namespace/name
+	Signer string
+	// Repository is the trust data (GUN) the session writes. Only one session can write a repository at a time,
+	// which is locked by Lease.LockRepository
+	Repository string
+}
+
+// Scheduler limits the number of concurrent signing sessions, globally and per signer.
+// Waiting sessions are served round-robin across namespaces, in order of arrival within a namespace.
+// Sessions whose repository is locked by another session of the scheduler wait until it is released
+type Scheduler struct {
+	// OnRelease is called with waiting requests when a session is released, so that they can try again
+	OnRelease func(waiting []types.NamespacedName)
+	// Locks lock repositories across replicas of the operator. If it is nil, repositories are locked only in the scheduler
+	Locks *RepositoryLocks
+
+	maxSessions  int
+	maxPerSigner int
+	expiry       time.Duration
+
+	mu      sync.Mutex
+	running map[types.NamespacedName]*Session
+	signers map[string]int
+	// repositories are locked repositories and their sessions
+	repositories map[string]types.NamespacedName
+	// queues are waiting sessions per namespace, served round-robin from namespaces[next]
+	queues     map[string][]*waiter
+	namespaces []string
+	next       int
+}
+
+type waiter struct {
+	session  Session
+	lastSeen time.Time
+}
+
+// Lease is a running session, which should be released when the session is done
+type Lease struct {
+	scheduler *Scheduler
+	session   Session
+	lock      *RepositoryLock
+	once      sync.Once
+}
+
+// New creates a scheduler. Zero limit means no limit
+func New(maxSessions, maxPerSigner int) *Scheduler {
+	return &Scheduler{
+		maxSessions:  maxSessions,
+		maxPerSigner: maxPerSigner,
+		expiry:       DefaultExpiry,
+		running:      map[types.NamespacedName]*Session{},
+		signers:      map[string]int{},
+		repositories: map[string]types.NamespacedName{},
+		queues:       map[string][]*waiter{},
+	}
+}
+
+// Acquire returns a lease if the session can start now.
+// Otherwise the session waits in the queue, and its 1-based position is returned.
+// Waiting sessions should call Acquire again, or they are removed from the queue after the expiry
+func (s *Scheduler) Acquire(session Session) (*Lease, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.expire(now)
+	s.enqueue(session, now)
+
+	available := s.maxSessions - len(s.running)
+	signers := map[string]int{}
+	for k, v := range s.signers {
+		signers[k] = v
+	}
+	repositories := map[string]bool{}
+	for k := range s.repositories {
+		repositories[k] = true
+	}
+
+	// sessions ahead of this session take the slots they can use, even if they have not tried again yet
+	for i, w := range s.order() {
+		eligible := (s.maxSessions <= 0 || available > 0) &&
+			(s.maxPerSigner <= 0 || signers[w.session.Signer] < s.maxPerSigner) &&
+			!repositories[w.session.Repository]
+
+		if w.session.Request == session.Request {
+			if !eligible {
+				return nil, i + 1
+			}
+			return s.start(w), 0
+		}
+
+		if eligible {
+			available--
+			signers[w.session.Signer]++
+			repositories[w.session.Repository] = true
+		}
+	}
+
+	// not reachable, the session is enqueued above
+	return nil, 0
+}
+
+// Forget removes the request from the queue, e.g., if it is deleted
+func (s *Scheduler) Forget(req types.NamespacedName) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.remove(req)
+}
+
+// Running returns the number of running sessions
+func (s *Scheduler) Running() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.running)
+}
+
+// Waiting returns the number of waiting sessions
+func (s *Scheduler) Waiting() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for _, queue := range s.queues {
+		n += len(queue)
+	}
+	return n
+}
+
+// LockRepository locks the repository of the session until the session is released.
+// It should be called right before the session writes the repository (e.g., after its pod is running),
+// so that other sessions of the repository do not wait for the start of the session.
+// It returns false if another session, possibly of another replica of the operator, has the lock
+func (l *Lease) LockRepository() (bool, error) {
+	s := l.scheduler
+	repository, request := l.session.Repository, l.session.Request
+
+	s.mu.Lock()
+	if holder, ok := s.repositories[repository]; ok {
+		s.mu.Unlock()
+		return holder == request, nil
+	}
+	s.repositories[repository] = request
+	s.mu.Unlock()
+
+	if s.Locks == nil {
+		return true, nil
+	}
+	lock, err := s.Locks.Lock(repository, request.String())
+	if err != nil || lock == nil {
+		s.mu.Lock()
+		delete(s.repositories, repository)
+		s.mu.Unlock()
+		return false, err
+	}
+	l.lock = lock
+	return true, nil
+}
+
+// Release ends the session. It is safe to call it more than once
+func (l *Lease) Release() {
+	l.once.Do(func() {
+		if l.lock != nil {
+			l.lock.Unlock()
+		}
+
+		s := l.scheduler
+		s.mu.Lock()
+		delete(s.running, l.session.Request)
+		if s.signers[l.session.Signer]--; s.signers[l.session.Signer] <= 0 {
+			delete(s.signers, l.session.Signer)
+		}
+		if s.repositories[l.session.Repository] == l.session.Request {
+			delete(s.repositories, l.session.Repository)
+		}
+		waiting := []types.NamespacedName{}
+		for _, w := range s.order() {
+			waiting = append(waiting, w.session.Request)
+		}
+		s.mu.Unlock()
+
+		if s.OnRelease != nil && len(waiting) > 0 {
+			s.OnRelease(waiting)
+		}
+	})
+}
+
+func (s *Scheduler) start(w *waiter) *Lease {
+	s.remove(w.session.Request)
+
+	// next namespace is served next time
+	for i, ns := range s.namespaces {
+		if ns == w.session.Request.Namespace {
+			s.next = i + 1
+			break
+		}
+	}
+	if s.next >= len(s.namespaces) {
+		s.next = 0
+	}
+
+	session := w.session
+	s.running[session.Request] = &session
+	s.signers[session.Signer]++
+
+	return &Lease{scheduler: s, session: session}
+}
+
+func (s *Scheduler) enqueue(session Session, now time.Time) {
+	ns := session.Request.Namespace
+	for _, w := range s.queues[ns] {
+		if w.session.Request == session.Request {
+			w.session = session
+			w.lastSeen = now
+			return
+		}
+	}
+
+	if _, ok := s.queues[ns]; !ok {
+		s.namespaces = append(s.namespaces, ns)
+	}
+	s.queues[ns] = append(s.queues[ns], &waiter{session: session, lastSeen: now})
+}
+
+func (s *Scheduler) remove(req types.NamespacedName) {
+	queue, ok := s.queues[req.Namespace]
+	if !ok {
+		return
+	}
+
+	for i, w := range queue {
+		if w.session.Request == req {
+			queue = append(queue[:i], queue[i+1:]...)
+			break
+		}
+	}
+	if len(queue) > 0 {
+		s.queues[req.Namespace] = queue
+		return
+	}
+
+	delete(s.queues, req.Namespace)
+	for i, ns := range s.namespaces {
+		if ns == req.Namespace {
+			s.namespaces = append(s.namespaces[:i], s.namespaces[i+1:]...)
+			if i < s.next {
+				s.next--
+			}
+			break
+		}
+	}
+	if s.next >= len(s.namespaces) {
+		s.next = 0
+	}
+}
+
+func (s *Scheduler) expire(now time.Time) {
+	expired := []types.NamespacedName{}
+	for _, queue := range s.queues {
+		for _, w := range queue {
+			if now.Sub(w.lastSeen) > s.expiry {
+				expired = append(expired, w.session.Request)
+			}
+		}
+	}
+	for _, req := range expired {
+		s.remove(req)
+	}
+}
+
+// order returns waiting sessions in the order to be served, taking one from each namespace in turn
+func (s *Scheduler) order() []*waiter {
+	order := []*waiter{}
+	for round := 0; ; round++ {
+		added := false
+		for i := range s.namespaces {
+			queue := s.queues[s.namespaces[(s.next+i)%len(s.namespaces)]]
+			if round < len(queue) {
+				order = append(order, queue[round])
+				added = true
+			}
+		}
+		if !added {
+			return order
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func session(namespace, name, signer, repository string) Session {
+	return Session{Request: types.NamespacedName{Namespace: namespace, Name: name}, Signer: signer, Repository: repository}
+}
+
+func TestAcquireLimit(t *testing.T) {
+	s := New(2, 0)
+
+	l1, _ := s.Acquire(session("a", "1", "signer", "repo1"))
+	l2, _ := s.Acquire(session("a", "2", "signer", "repo2"))
+	if l1 == nil || l2 == nil {
+		t.Fatal("expected two sessions to start")
+	}
+	if l, position := s.Acquire(session("a", "3", "signer", "repo3")); l != nil || position != 1 {
+		t.Fatalf("expected the third session to wait at position 1, got %v, %d", l, position)
+	}
+	if s.Running() != 2 || s.Waiting() != 1 {
+		t.Fatalf("expected 2 running and 1 waiting, got %d, %d", s.Running(), s.Waiting())
+	}
+
+	l1.Release()
+	l1.Release()
+	if l, _ := s.Acquire(session("a", "3", "signer", "repo3")); l == nil {
+		t.Fatal("expected the waiting session to start after release")
+	}
+	if s.Running() != 2 || s.Waiting() != 0 {
+		t.Fatalf("expected 2 running and 0 waiting, got %d, %d", s.Running(), s.Waiting())
+	}
+}
+
+func TestAcquirePerSigner(t *testing.T) {
+	s := New(4, 1)
+
+	if l, _ := s.Acquire(session("a", "1", "signer1", "repo1")); l == nil {
+		t.Fatal("expected the first session of signer1 to start")
+	}
+	if l, _ := s.Acquire(session("a", "2", "signer1", "repo2")); l != nil {
+		t.Fatal("expected the second session of signer1 to wait")
+	}
+	// the waiting session of signer1 does not block sessions of other signers
+	if l, _ := s.Acquire(session("a", "3", "signer2", "repo3")); l == nil {
+		t.Fatal("expected the session of signer2 to start")
+	}
+}
+
+func TestAcquireRoundRobin(t *testing.T) {
+	s := New(1, 0)
+	running, _ := s.Acquire(session("a", "0", "signer", "repo0"))
+
+	// namespace a has many waiting sessions, but b is served in turn
+	for _, sess := range []Session{
+		session("a", "1", "signer", "repo1"),
+		session("a", "2", "signer", "repo2"),
+		session("b", "1", "signer", "repo3"),
+	} {
+		if l, _ := s.Acquire(sess); l != nil {
+			t.Fatalf("expected %s to wait", sess.Request)
+		}
+	}
+	if _, position := s.Acquire(session("b", "1", "signer", "repo3")); position != 2 {
+		t.Fatalf("expected b/1 to be at position 2, got %d", position)
+	}
+
+	var released []types.NamespacedName
+	s.OnRelease = func(waiting []types.NamespacedName) { released = waiting }
+	running.Release()
+	if len(released) != 3 {
+		t.Fatalf("expected waiting sessions to be notified, got %v", released)
+	}
+
+	// b/1 cannot take the slot of a/1, which is ahead of it
+	if l, _ := s.Acquire(session("b", "1", "signer", "repo3")); l != nil {
+		t.Fatal("expected b/1 to wait for a/1")
+	}
+	l, _ := s.Acquire(session("a", "1", "signer", "repo1"))
+	if l == nil {
+		t.Fatal("expected a/1 to start")
+	}
+	l.Release()
+	if l, _ := s.Acquire(session("b", "1", "signer", "repo3")); l == nil {
+		t.Fatal("expected b/1 to start before a/2")
+	}
+}
+
+func TestLockRepository(t *testing.T) {
+	s := New(4, 0)
+
+	l1, _ := s.Acquire(session("a", "1", "signer", "repo"))
+	l2, _ := s.Acquire(session("a", "2", "signer", "repo"))
+	if l1 == nil || l2 == nil {
+		t.Fatal("expected sessions of the same repository to start before they lock it")
+	}
+
+	if locked, err := l1.LockRepository(); err != nil || !locked {
+		t.Fatalf("expected the repository to be locked, got %v, %v", locked, err)
+	}
+	if locked, _ := l1.LockRepository(); !locked {
+		t.Fatal("expected the lock to be held by the same session")
+	}
+	if locked, _ := l2.LockRepository(); locked {
+		t.Fatal("expected the repository to be locked by the other session")
+	}
+	l2.Release()
+
+	// sessions of the locked repository wait without taking a slot
+	if l, _ := s.Acquire(session("a", "3", "signer", "repo")); l != nil {
+		t.Fatal("expected the session of the locked repository to wait")
+	}
+	if l, _ := s.Acquire(session("a", "4", "signer", "other")); l == nil {
+		t.Fatal("expected the session of another repository to start")
+	}
+
+	l1.Release()
+	l3, _ := s.Acquire(session("a", "3", "signer", "repo"))
+	if l3 == nil {
+		t.Fatal("expected the session to start after the repository is unlocked")
+	}
+	if locked, _ := l3.LockRepository(); !locked {
+		t.Fatal("expected the repository to be locked after release")
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	s := New(1, 0)
+	s.expiry = time.Millisecond
+	s.Acquire(session("a", "0", "signer", "repo0"))
+	s.Acquire(session("a", "1", "signer", "repo1"))
+
+	time.Sleep(10 * time.Millisecond)
+	if _, position := s.Acquire(session("a", "2", "signer", "repo2")); position != 1 {
+		t.Fatalf("expected the session which did not try again to be removed, got position %d", position)
+	}
+
+	s.Forget(types.NamespacedName{Namespace: "a", Name: "2"})
+	if s.Waiting() != 0 {
+		t.Fatalf("expected no waiting session, got %d", s.Waiting())
+	}
+}
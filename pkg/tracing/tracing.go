@@ -0,0 +1,137 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// ServiceName is the service name of exported spans
+	ServiceName = "image-signing-operator"
+	// TracerName is the name of the tracer of the operator
+	TracerName = "github.com/tmax-cloud/image-signing-operator"
+)
+
+// Attributes of spans
+const (
+	AttributeRequestUID = attribute.Key("imagesignrequest.uid")
+	AttributeRequest    = attribute.Key("imagesignrequest.name")
+	AttributeSigner     = attribute.Key("imagesigner.name")
+	AttributePod        = attribute.Key("k8s.pod.name")
+	AttributeNamespace  = attribute.Key("k8s.namespace.name")
+	AttributeCommand    = attribute.Key("exec.command")
+	AttributePooled     = attribute.Key("worker.pooled")
+	AttributeUser       = attribute.Key("enduser.id")
+)
+
+// Setup exports spans via OTLP over HTTP to the endpoint (host:port).
+// If the endpoint is empty, tracing is disabled and spans are not recorded.
+// The returned function flushes and stops the exporter
+func Setup(ctx context.Context, endpoint string, insecure bool) (func(context.Context) error, error) {
+	if len(endpoint) == 0 {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint)}
+	if insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := NewProvider(sdktrace.WithBatcher(exporter))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return provider.Shutdown, nil
+}
+
+// NewProvider creates a tracer provider of the operator with the options, e.g., an exporter.
+// Tests can set a provider which syncs spans to an in-memory exporter with otel.SetTracerProvider
+func NewProvider(opts ...sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	res := resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(ServiceName))
+	return sdktrace.NewTracerProvider(append([]sdktrace.TracerProviderOption{sdktrace.WithResource(res)}, opts...)...)
+}
+
+type requestUIDKey struct{}
+
+// WithRequestUID returns a context whose spans have the UID of the ImageSignRequest
+func WithRequestUID(ctx context.Context, uid string) context.Context {
+	return context.WithValue(ctx, requestUIDKey{}, uid)
+}
+
+// Start starts a span, which has the request UID of the context if it exists
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if uid, ok := ctx.Value(requestUIDKey{}).(string); ok && len(uid) > 0 {
+		attrs = append(attrs, AttributeRequestUID.String(uid))
+	}
+
+	return otel.Tracer(TracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End ends the span, setting its status to error if err is not nil
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Middleware starts a span for each request of the api server. Spans are named by the route template
+// (e.g., /apis/{group}/{version}/namespaces/{namespace}/imagesigners/{name}), so that their names are not per object
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		route := req.URL.Path
+		if r := mux.CurrentRoute(req); r != nil {
+			if template, err := r.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+		ctx, span := Start(ctx, req.Method+" "+route,
+			semconv.HTTPMethodKey.String(req.Method),
+			semconv.HTTPRouteKey.String(route),
+			semconv.HTTPTargetKey.String(req.URL.Path),
+		)
+		defer span.End()
+
+		rw := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rw, req.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPStatusCodeKey.Int(rw.status))
+		span.SetStatus(semconv.SpanStatusFromHTTPStatusCode(rw.status))
+	})
+}
+
+// statusRecorder keeps the status code of the response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Flush supports streaming responses
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,107 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// setupExporter sets a provider which exports spans to the returned exporter when they end
+func setupExporter() (*tracetest.InMemoryExporter, func()) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := NewProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+
+	return exporter, func() { _ = provider.Shutdown(context.Background()) }
+}
+
+func attributeValue(attrs []attribute.KeyValue, key attribute.Key) (string, bool) {
+	for _, a := range attrs {
+		if a.Key == key {
+			return a.Value.Emit(), true
+		}
+	}
+	return "", false
+}
+
+func TestStart(t *testing.T) {
+	exporter, shutdown := setupExporter()
+	defer shutdown()
+
+	ctx, parent := Start(context.Background(), "reconcile")
+	ctx = WithRequestUID(ctx, "1234")
+	_, child := Start(ctx, "exec", AttributeCommand.String("docker trust sign"))
+	End(child, errors.New("exit code 1"))
+	End(parent, nil)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+
+	exec, reconcile := spans[0], spans[1]
+	if exec.Parent.SpanID() != reconcile.SpanContext.SpanID() {
+		t.Errorf("exec span is not a child of reconcile span")
+	}
+	if uid, ok := attributeValue(exec.Attributes, AttributeRequestUID); !ok || uid != "1234" {
+		t.Errorf("expected request uid 1234, got %q", uid)
+	}
+	if _, ok := attributeValue(reconcile.Attributes, AttributeRequestUID); ok {
+		t.Errorf("reconcile span should not have request uid of its child context")
+	}
+	if exec.Status.Code != codes.Error {
+		t.Errorf("expected error status, got %v", exec.Status.Code)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	exporter, shutdown := setupExporter()
+	defer shutdown()
+
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/apis/registry.tmax.io/v1", nil))
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	if spans[0].Name != "GET /apis/registry.tmax.io/v1" {
+		t.Errorf("unexpected span name %s", spans[0].Name)
+	}
+	if code, _ := attributeValue(spans[0].Attributes, "http.status_code"); code != "404" {
+		t.Errorf("expected status code 404, got %q", code)
+	}
+}
+
+func TestMiddlewareRoute(t *testing.T) {
+	exporter, shutdown := setupExporter()
+	defer shutdown()
+
+	router := mux.NewRouter()
+	router.Use(Middleware)
+	signers := router.PathPrefix("/apis/registry.tmax.io/v1/imagesigners").Subrouter()
+	signers.HandleFunc("/{name}/keys", func(http.ResponseWriter, *http.Request) {})
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/apis/registry.tmax.io/v1/imagesigners/signer/keys", nil))
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	if spans[0].Name != "GET /apis/registry.tmax.io/v1/imagesigners/{name}/keys" {
+		t.Errorf("expected span named by the route, got %s", spans[0].Name)
+	}
+	if target, _ := attributeValue(spans[0].Attributes, "http.target"); target != "/apis/registry.tmax.io/v1/imagesigners/signer/keys" {
+		t.Errorf("expected target of the request, got %q", target)
+	}
+}
@@ -0,0 +1,322 @@
+package trust
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"hash"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// KeyFileExt is an extension of private key files in /root/.docker/trust/private
+	KeyFileExt = ".key"
+
+	pemTypeEncrypted = "ENCRYPTED PRIVATE KEY"
+	pemTypePlain     = "PRIVATE KEY"
+	pemHeaderRole    = "role"
+	pemHeaderGun     = "gun"
+
+	// Parameters of keys encrypted by EncryptPrivateKey, which are the same as notary's
+	encryptIterations = 2048
+	encryptSaltLength = 8
+)
+
+var (
+	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES128CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES256CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidDESEDE3CBC     = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+)
+
+type encryptedPrivateKeyInfo struct {
+	Algo          pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// encryptedPBKDF2Params is pbkdf2Params without optional fields, which notary cannot parse
+type encryptedPBKDF2Params struct {
+	Salt           []byte
+	IterationCount int
+}
+
+// ParsePrivateKey parses a private key file of docker content trust.
+// The file must have "role" header of the given role, and id (file name) must be the canonical ID of the key
+// in the same form as the files in /root/.docker/trust/private (<key ID>.key)
+func ParsePrivateKey(id string, contents []byte, passphrase string, role RoleType) (crypto.Signer, error) {
+	block, _ := pem.Decode(contents)
+	if block == nil {
+		return nil, fmt.Errorf("key %s is not a PEM encoded key", id)
+	}
+
+	// notary writes "targets" for the repository key
+	if r := block.Headers[pemHeaderRole]; r != string(role) && r != pemRole(role) {
+		return nil, fmt.Errorf("key %s has role %q, not %q", id, r, role)
+	}
+
+	der := block.Bytes
+	switch block.Type {
+	case pemTypeEncrypted:
+		var err error
+		der, err = decryptPKCS8(block.Bytes, []byte(passphrase))
+		if err != nil {
+			return nil, fmt.Errorf("cannot decrypt key %s: %v", id, err)
+		}
+	case pemTypePlain:
+	default:
+		return nil, fmt.Errorf("key %s has unsupported PEM type %s", id, block.Type)
+	}
+
+	priv, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse key %s (wrong passphrase?): %v", id, err)
+	}
+	signer, ok := priv.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("key %s is not a signing key", id)
+	}
+
+	keyID, err := KeyID(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(id, KeyFileExt) != keyID {
+		return nil, fmt.Errorf("key %s does not match its key ID %s", id, keyID)
+	}
+
+	return signer, nil
+}
+
+// EncryptPrivateKey encodes the key as a key file of docker content trust, encrypted by the passphrase as notary does
+func EncryptPrivateKey(key crypto.Signer, passphrase string, role RoleType, gun string) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, encryptSaltLength)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(pbkdf2.Key([]byte(passphrase), salt, encryptIterations, 32, sha1.New))
+	if err != nil {
+		return nil, err
+	}
+
+	// Add PKCS#7 padding
+	pad := aes.BlockSize - len(der)%aes.BlockSize
+	data := append(der, bytes.Repeat([]byte{byte(pad)}, pad)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, data)
+
+	kdfParams, err := asn1.Marshal(encryptedPBKDF2Params{Salt: salt, IterationCount: encryptIterations})
+	if err != nil {
+		return nil, err
+	}
+	encParams, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+	params, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdfParams}},
+		EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: encParams}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := asn1.Marshal(encryptedPrivateKeyInfo{
+		Algo:          pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}},
+		EncryptedData: data,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	headers := map[string]string{pemHeaderRole: pemRole(role)}
+	if len(gun) > 0 {
+		headers[pemHeaderGun] = gun
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: pemTypeEncrypted, Headers: headers, Bytes: encrypted}), nil
+}
+
+// HasRole returns true if the role header of the key file is exactly the role.
+// Roles are not matched by prefix, e.g., "target" does not match a key of delegation "targetsigner"
+func HasRole(contents []byte, role RoleType) bool {
+	block, _ := pem.Decode(contents)
+	if block == nil {
+		return false
+	}
+	r := block.Headers[pemHeaderRole]
+	return r == string(role) || r == pemRole(role)
+}
+
+// pemRole is the role header of key files. notary writes "targets" for the repository key
+func pemRole(role RoleType) string {
+	if role == TrustRoleTarget {
+		return string(role) + "s"
+	}
+	return string(role)
+}
+
+// KeyID returns the canonical key ID of the public key, which is the same as notary's
+func KeyID(pub crypto.PublicKey) (string, error) {
+	key, err := newTUFKey(pub)
+	if err != nil {
+		return "", err
+	}
+	return key.id()
+}
+
+// tufKey is a public key in TUF metadata. Fields are ordered as canonical json
+type tufKey struct {
+	Type  string `json:"keytype"`
+	Value struct {
+		Private []byte `json:"private"`
+		Public  []byte `json:"public"`
+	} `json:"keyval"`
+}
+
+func newTUFKey(pub crypto.PublicKey) (*tufKey, error) {
+	key := &tufKey{}
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey:
+		der, err := x509.MarshalPKIXPublicKey(k)
+		if err != nil {
+			return nil, err
+		}
+		key.Value.Public = der
+		if _, ok := k.(*ecdsa.PublicKey); ok {
+			key.Type = "ecdsa"
+		} else {
+			key.Type = "rsa"
+		}
+	case ed25519.PublicKey:
+		key.Type = "ed25519"
+		key.Value.Public = k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", pub)
+	}
+
+	return key, nil
+}
+
+func (k *tufKey) id() (string, error) {
+	b, err := canonicalJSON(k)
+	if err != nil {
+		return "", err
+	}
+
+	digest := sha256.Sum256(b)
+	return hex.EncodeToString(digest[:]), nil
+}
+
+func decryptPKCS8(der, passphrase []byte) ([]byte, error) {
+	info := encryptedPrivateKeyInfo{}
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, err
+	}
+	if !info.Algo.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("only PBES2 encryption is supported")
+	}
+
+	params := pbes2Params{}
+	if _, err := asn1.Unmarshal(info.Algo.Parameters.FullBytes, &params); err != nil {
+		return nil, err
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, fmt.Errorf("only PBKDF2 key derivation is supported")
+	}
+
+	kdfParams := pbkdf2Params{}
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdfParams); err != nil {
+		return nil, err
+	}
+
+	var prf func() hash.Hash
+	switch {
+	case len(kdfParams.PRF.Algorithm) == 0, kdfParams.PRF.Algorithm.Equal(oidHMACWithSHA1):
+		prf = sha1.New
+	case kdfParams.PRF.Algorithm.Equal(oidHMACWithSHA256):
+		prf = sha256.New
+	default:
+		return nil, fmt.Errorf("unsupported PBKDF2 PRF %v", kdfParams.PRF.Algorithm)
+	}
+
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, err
+	}
+
+	var keyLen int
+	var newCipher func([]byte) (cipher.Block, error)
+	switch {
+	case params.EncryptionScheme.Algorithm.Equal(oidAES128CBC):
+		keyLen, newCipher = 16, aes.NewCipher
+	case params.EncryptionScheme.Algorithm.Equal(oidAES256CBC):
+		keyLen, newCipher = 32, aes.NewCipher
+	case params.EncryptionScheme.Algorithm.Equal(oidDESEDE3CBC):
+		keyLen, newCipher = 24, des.NewTripleDESCipher
+	default:
+		return nil, fmt.Errorf("unsupported encryption scheme %v", params.EncryptionScheme.Algorithm)
+	}
+
+	key := pbkdf2.Key(passphrase, kdfParams.Salt, kdfParams.IterationCount, keyLen, prf)
+	block, err := newCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != block.BlockSize() || len(info.EncryptedData) == 0 || len(info.EncryptedData)%block.BlockSize() != 0 {
+		return nil, fmt.Errorf("encrypted data is malformed")
+	}
+
+	data := make([]byte, len(info.EncryptedData))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(data, info.EncryptedData)
+
+	// Remove PKCS#7 padding
+	pad := int(data[len(data)-1])
+	if pad == 0 || pad > block.BlockSize() || pad > len(data) {
+		return nil, fmt.Errorf("incorrect passphrase")
+	}
+	for _, b := range data[len(data)-pad:] {
+		if int(b) != pad {
+			return nil, fmt.Errorf("incorrect passphrase")
+		}
+	}
+
+	return data[:len(data)-pad], nil
+}
@@ -0,0 +1,165 @@
+package trust
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Keys in testdata are generated by notary v0.7.0 (trustmanager.KeyFileStore) with the passphrase below.
+// <key ID>.pub is the public key of each key, as notary computes it
+const testPassphrase = "notary-passphrase"
+
+const (
+	testRootKeyID      = "97db9d7291e15b9ee9714b5e7a060e4ab350df24142f7ae37bccc2325fa4bb77"
+	testTargetKeyID    = "fadaa79c0f7f52720b6d2e43919405ff2a5abe9ff67ee1a8948c2dac640fe988"
+	testRSATargetKeyID = "b681837ca7090254ce878aa6aa1a60097f6f8e3fadfea13aedebd636e0c29a51"
+)
+
+func readTestFile(t *testing.T, name string) []byte {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	tc := map[string]struct {
+		id   string
+		role RoleType
+	}{
+		"ecdsaRoot":    {id: testRootKeyID, role: TrustRoleRoot},
+		"ecdsaTargets": {id: testTargetKeyID, role: TrustRoleTarget},
+		"rsaTargets":   {id: testRSATargetKeyID, role: TrustRoleTarget},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			signer, err := ParsePrivateKey(c.id+KeyFileExt, readTestFile(t, c.id+KeyFileExt), testPassphrase, c.role)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			block, _ := pem.Decode(readTestFile(t, c.id+".pub"))
+			expected, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(signer.Public(), expected) {
+				t.Errorf("decrypted key does not match the public key of notary")
+			}
+
+			keyID, err := KeyID(signer.Public())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if keyID != c.id {
+				t.Errorf("expected key ID %s, got %s", c.id, keyID)
+			}
+		})
+	}
+}
+
+func TestParsePlainPrivateKey(t *testing.T) {
+	signer, err := ParsePrivateKey(testRootKeyID, readTestFile(t, testRootKeyID+KeyFileExt), testPassphrase, TrustRoleRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := pem.EncodeToMemory(&pem.Block{Type: pemTypePlain, Headers: map[string]string{pemHeaderRole: "root"}, Bytes: der})
+
+	if _, err := ParsePrivateKey(testRootKeyID, plain, "", TrustRoleRoot); err != nil {
+		t.Errorf("expected plain key to be parsed, got %v", err)
+	}
+}
+
+func TestParsePrivateKeyError(t *testing.T) {
+	root := readTestFile(t, testRootKeyID+KeyFileExt)
+
+	tc := map[string]struct {
+		id         string
+		contents   []byte
+		passphrase string
+		role       RoleType
+		err        string
+	}{
+		"wrongPassphrase": {id: testRootKeyID, contents: root, passphrase: "wrong", role: TrustRoleRoot, err: "passphrase"},
+		"wrongRole":       {id: testRootKeyID, contents: root, passphrase: testPassphrase, role: TrustRoleTarget, err: "has role"},
+		"wrongID":         {id: testTargetKeyID, contents: root, passphrase: testPassphrase, role: TrustRoleRoot, err: "does not match"},
+		"notPEM":          {id: testRootKeyID, contents: []byte("not a key"), passphrase: testPassphrase, role: TrustRoleRoot, err: "not a PEM"},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParsePrivateKey(c.id, c.contents, c.passphrase, c.role)
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("expected error containing %q, got %v", c.err, err)
+			}
+		})
+	}
+}
+
+func TestEncryptPrivateKey(t *testing.T) {
+	signer, err := ParsePrivateKey(testTargetKeyID, readTestFile(t, testTargetKeyID+KeyFileExt), testPassphrase, TrustRoleTarget)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := EncryptPrivateKey(signer, "new-passphrase", TrustRoleTarget, "docker.io/library/busybox")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, _ := pem.Decode(contents)
+	if block.Type != pemTypeEncrypted || block.Headers["role"] != "targets" || block.Headers["gun"] != "docker.io/library/busybox" {
+		t.Errorf("unexpected key file %s %v", block.Type, block.Headers)
+	}
+
+	decrypted, err := ParsePrivateKey(testTargetKeyID, contents, "new-passphrase", TrustRoleTarget)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decrypted.Public(), signer.Public()) {
+		t.Errorf("decrypted key does not match the original key")
+	}
+
+	if _, err := ParsePrivateKey(testTargetKeyID, contents, testPassphrase, TrustRoleTarget); err == nil {
+		t.Errorf("expected error for the wrong passphrase")
+	}
+}
+
+func TestHasRole(t *testing.T) {
+	withRole := func(role string) []byte {
+		return pem.EncodeToMemory(&pem.Block{Type: pemTypeEncrypted, Headers: map[string]string{pemHeaderRole: role}, Bytes: []byte{0}})
+	}
+
+	tc := map[string]struct {
+		contents []byte
+		role     RoleType
+		expected bool
+	}{
+		"root":             {contents: readTestFile(t, testRootKeyID+KeyFileExt), role: TrustRoleRoot, expected: true},
+		"targets":          {contents: readTestFile(t, testTargetKeyID+KeyFileExt), role: TrustRoleTarget, expected: true},
+		"rootIsNotTarget":  {contents: readTestFile(t, testRootKeyID+KeyFileExt), role: TrustRoleTarget, expected: false},
+		"delegation":       {contents: withRole("releases"), role: "releases", expected: true},
+		"delegationPrefix": {contents: withRole("targetsigner"), role: TrustRoleTarget, expected: false},
+		"delegationSuffix": {contents: withRole("releases-2"), role: "releases", expected: false},
+		"notPEM":           {contents: []byte("role: targets"), role: TrustRoleTarget, expected: false},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			if got := HasRole(c.contents, c.role); got != c.expected {
+				t.Errorf("expected %v, got %v", c.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,293 @@
+package trust
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// DctEnvServer is an environment variable of the notary server URL
+	DctEnvServer = "DOCKER_CONTENT_TRUST_SERVER"
+
+	notaryHealthPath = "/_notary_server/health"
+	notaryTimeout    = 10 * time.Second
+
+	// notaryResponseLimit limits bodies of responses which are read
+	notaryResponseLimit = 1 << 20
+)
+
+// challengeParamRegexp matches parameters of WWW-Authenticate header (e.g., realm="https://auth.example.com/token")
+var challengeParamRegexp = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// NotaryServer is a notary server and credentials to access it
+type NotaryServer struct {
+	URL string
+	// CA is a PEM encoded CA bundle. If it is empty, system CAs are used
+	CA                 []byte
+	Username, Password string
+}
+
+// Host returns host[:port] of the server, which is the directory name of its certs in ~/.docker/tls
+func (n *NotaryServer) Host() (string, error) {
+	u, err := url.Parse(n.URL)
+	if err != nil {
+		return "", err
+	}
+	if len(u.Host) == 0 {
+		return "", fmt.Errorf("notary server URL %s has no host", n.URL)
+	}
+	return u.Host, nil
+}
+
+// CheckHealth returns error if the server is not reachable or not healthy
+func (n *NotaryServer) CheckHealth() error {
+	cli, err := n.httpClient()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(n.URL, "/")+notaryHealthPath, nil)
+	if err != nil {
+		return err
+	}
+	if len(n.Username) > 0 {
+		req.SetBasicAuth(n.Username, n.Password)
+	}
+
+	resp, err := cli.Do(req)
+	if err != nil {
+		return fmt.Errorf("notary server %s is not reachable: %v", n.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("notary server %s is not healthy: %s", n.URL, resp.Status)
+	}
+
+	return nil
+}
+
+// RepositoryExists returns true if the repository (gun) has trust data in the server
+func (n *NotaryServer) RepositoryExists(gun string) (bool, error) {
+	resp, err := n.do(gun, func() (*http.Request, error) {
+		return http.NewRequest(http.MethodGet, n.tufURL(gun, "root.json"), nil)
+	})
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("cannot get root of %s from notary server %s: %s", gun, n.URL, resp.Status)
+	}
+}
+
+// InitRepository publishes trust data of a new repository (gun), signed by the keys.
+// Timestamp role, and snapshot role if keys.Snapshot is nil, are signed by the server
+func (n *NotaryServer) InitRepository(gun string, keys *RepositoryKeys) error {
+	timestampKey, err := n.getServerKey(gun, tufRoleTimestamp)
+	if err != nil {
+		return err
+	}
+	var snapshotKey *tufKey
+	if keys.Snapshot == nil {
+		if snapshotKey, err = n.getServerKey(gun, tufRoleSnapshot); err != nil {
+			return err
+		}
+	}
+
+	metadata, err := newRepositoryMetadata(gun, keys, timestampKey, snapshotKey, time.Now())
+	if err != nil {
+		return err
+	}
+
+	// Each role is a file of 'files' form field, as notary client uploads them
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for role, b := range metadata {
+		part, err := writer.CreateFormFile("files", role)
+		if err != nil {
+			return err
+		}
+		if _, err := part.Write(b); err != nil {
+			return err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	resp, err := n.do(gun, func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodPost, n.tufURL(gun, ""), bytes.NewReader(body.Bytes()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, notaryResponseLimit))
+		return fmt.Errorf("cannot publish trust data of %s to notary server %s: %s %s", gun, n.URL, resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	return nil
+}
+
+// getServerKey gets the public key of the role managed by the server, which is created if it does not exist
+func (n *NotaryServer) getServerKey(gun, role string) (*tufKey, error) {
+	resp, err := n.do(gun, func() (*http.Request, error) {
+		return http.NewRequest(http.MethodGet, n.tufURL(gun, role+".key"), nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cannot get %s key of %s from notary server %s: %s", role, gun, n.URL, resp.Status)
+	}
+
+	key := &tufKey{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, notaryResponseLimit)).Decode(key); err != nil {
+		return nil, fmt.Errorf("%s key of %s is malformed: %v", role, gun, err)
+	}
+	return key, nil
+}
+
+func (n *NotaryServer) tufURL(gun, file string) string {
+	return fmt.Sprintf("%s/v2/%s/_trust/tuf/%s", strings.TrimSuffix(n.URL, "/"), gun, file)
+}
+
+func (n *NotaryServer) httpClient() (*http.Client, error) {
+	tlsConfig := &tls.Config{}
+	if len(n.CA) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(n.CA) {
+			return nil, fmt.Errorf("CA bundle of notary server %s is not valid", n.URL)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return &http.Client{
+		Timeout:   notaryTimeout,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment},
+	}, nil
+}
+
+// do sends the request to the trust data of the repository. If the server requires a token,
+// the token is issued by the token service of the challenge with the credentials, and the request is sent again
+func (n *NotaryServer) do(gun string, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	cli, err := n.httpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := newRequest()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := cli.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("notary server %s is not reachable: %v", n.URL, err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+	challenge := resp.Header.Get("Www-Authenticate")
+	resp.Body.Close()
+
+	token, err := n.token(cli, challenge, fmt.Sprintf("repository:%s:push,pull", gun))
+	if err != nil {
+		return nil, err
+	}
+
+	req, err = newRequest()
+	if err != nil {
+		return nil, err
+	}
+	if len(token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else {
+		req.SetBasicAuth(n.Username, n.Password)
+	}
+	resp, err = cli.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("notary server %s is not reachable: %v", n.URL, err)
+	}
+	return resp, nil
+}
+
+// token returns a token of the bearer challenge, or an empty token for basic challenges
+func (n *NotaryServer) token(cli *http.Client, challenge, scope string) (string, error) {
+	if len(n.Username) == 0 {
+		return "", fmt.Errorf("notary server %s requires authentication, but there is no credential", n.URL)
+	}
+	if !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+		return "", nil
+	}
+
+	params := map[string]string{}
+	for _, m := range challengeParamRegexp.FindAllStringSubmatch(challenge, -1) {
+		params[strings.ToLower(m[1])] = m[2]
+	}
+	realm, err := url.Parse(params["realm"])
+	if err != nil || len(realm.Host) == 0 {
+		return "", fmt.Errorf("challenge of notary server %s has invalid realm %q", n.URL, params["realm"])
+	}
+	query := realm.Query()
+	if service, ok := params["service"]; ok {
+		query.Set("service", service)
+	}
+	query.Set("scope", scope)
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(n.Username, n.Password)
+	resp, err := cli.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token service of notary server %s is not reachable: %v", n.URL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("cannot get token of notary server %s: %s", n.URL, resp.Status)
+	}
+
+	result := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, notaryResponseLimit)).Decode(&result); err != nil {
+		return "", err
+	}
+	if len(result.Token) > 0 {
+		return result.Token, nil
+	}
+	if len(result.AccessToken) > 0 {
+		return result.AccessToken, nil
+	}
+	return "", fmt.Errorf("token service of notary server %s returned no token", n.URL)
+}
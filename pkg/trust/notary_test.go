@@ -0,0 +1,136 @@
+package trust
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeNotary is a notary server which requires tokens of its token service
+type fakeNotary struct {
+	t         *testing.T
+	server    *httptest.Server
+	timestamp *tufKey
+	snapshot  *tufKey
+
+	published map[string][]byte
+}
+
+func newFakeNotary(t *testing.T) *fakeNotary {
+	n := &fakeNotary{
+		t:         t,
+		timestamp: newTestTUFKey(t, newTestKey(t).Public()),
+		snapshot:  newTestTUFKey(t, newTestKey(t).Public()),
+	}
+	n.server = httptest.NewTLSServer(http.HandlerFunc(n.serve))
+	return n
+}
+
+func (n *fakeNotary) serve(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/token" {
+		if user, pass, ok := req.BasicAuth(); !ok || user != "admin" || pass != "password" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if req.URL.Query().Get("scope") != "repository:"+testGun+":push,pull" || req.URL.Query().Get("service") != "notary" {
+			n.t.Errorf("unexpected token request %s", req.URL)
+		}
+		_, _ = w.Write([]byte(`{"token": "valid-token"}`))
+		return
+	}
+
+	if req.Header.Get("Authorization") != "Bearer valid-token" {
+		w.Header().Set("Www-Authenticate", `Bearer realm="`+n.server.URL+`/token",service="notary"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	prefix := "/v2/" + testGun + "/_trust/tuf/"
+	switch {
+	case req.Method == http.MethodGet && req.URL.Path == prefix+"root.json":
+		if root, ok := n.published[tufRoleRoot]; ok {
+			_, _ = w.Write(root)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	case req.Method == http.MethodGet && req.URL.Path == prefix+"timestamp.key":
+		_ = json.NewEncoder(w).Encode(n.timestamp)
+	case req.Method == http.MethodGet && req.URL.Path == prefix+"snapshot.key":
+		_ = json.NewEncoder(w).Encode(n.snapshot)
+	case req.Method == http.MethodPost && req.URL.Path == prefix:
+		if err := req.ParseMultipartForm(1 << 20); err != nil {
+			n.t.Error(err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		n.published = map[string][]byte{}
+		for _, f := range req.MultipartForm.File["files"] {
+			file, _ := f.Open()
+			n.published[f.Filename], _ = ioutil.ReadAll(file)
+			file.Close()
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (n *fakeNotary) notaryServer() *NotaryServer {
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: n.server.Certificate().Raw})
+	return &NotaryServer{URL: n.server.URL, CA: ca, Username: "admin", Password: "password"}
+}
+
+func TestInitRepository(t *testing.T) {
+	fake := newFakeNotary(t)
+	defer fake.server.Close()
+	notary := fake.notaryServer()
+
+	exists, err := notary.RepositoryExists(testGun)
+	if err != nil || exists {
+		t.Fatalf("expected no repository, got %v, %v", exists, err)
+	}
+
+	if err := notary.InitRepository(testGun, &RepositoryKeys{Root: newTestKey(t), Targets: newTestKey(t)}); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.published) != 2 || fake.published[tufRoleRoot] == nil || fake.published[tufRoleTargets] == nil {
+		t.Errorf("expected root and targets to be published, got %d files", len(fake.published))
+	}
+
+	// Root has the keys of the server
+	envelope := &signedEnvelope{}
+	_ = json.Unmarshal(fake.published[tufRoleRoot], envelope)
+	root := &signedRoot{}
+	if err := json.Unmarshal(envelope.Signed, root); err != nil {
+		t.Fatal(err)
+	}
+	for role, key := range map[string]*tufKey{tufRoleTimestamp: fake.timestamp, tufRoleSnapshot: fake.snapshot} {
+		if id, _ := key.id(); root.Roles[role].KeyIDs[0] != id {
+			t.Errorf("expected %s key of the server", role)
+		}
+	}
+
+	exists, err = notary.RepositoryExists(testGun)
+	if err != nil || !exists {
+		t.Errorf("expected repository to exist, got %v, %v", exists, err)
+	}
+}
+
+func TestInitRepositoryUnauthorized(t *testing.T) {
+	fake := newFakeNotary(t)
+	defer fake.server.Close()
+
+	notary := fake.notaryServer()
+	notary.Password = "wrong"
+	if _, err := notary.RepositoryExists(testGun); err == nil || !strings.Contains(err.Error(), "token") {
+		t.Errorf("expected token error, got %v", err)
+	}
+
+	notary.Username = ""
+	if _, err := notary.RepositoryExists(testGun); err == nil || !strings.Contains(err.Error(), "credential") {
+		t.Errorf("expected credential error, got %v", err)
+	}
+}
@@ -1,7 +1,9 @@
 package trust
 
 import (
+	"fmt"
 	"path"
+	"strings"
 
 	"github.com/tmax-cloud/image-signing-operator/internal/utils"
 )
@@ -11,13 +13,16 @@ const (
 	DctEnvKeyTarget = "DOCKER_CONTENT_TRUST_REPOSITORY_PASSPHRASE"
 	TrustRoleRoot   = RoleType("root")
 	TrustRoleTarget = RoleType("target")
+	// TrustRoleSnapshot is unlocked by the repository passphrase, as docker does
+	TrustRoleSnapshot = RoleType("snapshot")
 )
 
 type RoleType string
 
 var RoleMap = map[RoleType]string{
-	TrustRoleRoot:   DctEnvKeyRoot,
-	TrustRoleTarget: DctEnvKeyTarget,
+	TrustRoleRoot:     DctEnvKeyRoot,
+	TrustRoleTarget:   DctEnvKeyTarget,
+	TrustRoleSnapshot: DctEnvKeyTarget,
 }
 
 type TrustPass map[string]string
@@ -36,6 +41,30 @@ func (t TrustPass) AssignNewTargetPass() {
 	t[DctEnvKeyTarget] = utils.RandomString(12)
 }
 
+// DelegationRolePrefix is a prefix of delegation roles, which are direct children of the targets role
+const DelegationRolePrefix = "targets/"
+
+// reservedRoles cannot be used as delegation names
+var reservedRoles = []string{"root", "targets", "snapshot", "timestamp"}
+
+// DelegationName returns the delegation name of the role (e.g., targets/releases -> releases)
+func DelegationName(role string) string {
+	return strings.TrimPrefix(role, DelegationRolePrefix)
+}
+
+// ValidateDelegationName returns error if the name cannot be used as a delegation
+func ValidateDelegationName(name string) error {
+	if len(name) == 0 || strings.Contains(name, "/") {
+		return fmt.Errorf("delegation name %q is invalid", name)
+	}
+	for _, r := range reservedRoles {
+		if name == r {
+			return fmt.Errorf("delegation name %q is reserved", name)
+		}
+	}
+	return nil
+}
+
 func BuildTargetName(regName, namespace, imageName string) string {
 	return path.Join(namespace, regName, imageName)
 }
@@ -0,0 +1,262 @@
+package trust
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// TUF roles of repository metadata
+const (
+	tufRoleRoot      = "root"
+	tufRoleTargets   = "targets"
+	tufRoleSnapshot  = "snapshot"
+	tufRoleTimestamp = "timestamp"
+
+	// Expiry of metadata of new repositories, which is the same as notary's
+	rootExpiry     = 10 * 365 * 24 * time.Hour
+	targetsExpiry  = 3 * 365 * 24 * time.Hour
+	snapshotExpiry = 3 * 365 * 24 * time.Hour
+
+	tufKeyTypeECDSAx509 = "ecdsa-x509"
+	tufSigMethodECDSA   = "ecdsa"
+)
+
+// RepositoryKeys are keys to initialize a repository
+type RepositoryKeys struct {
+	// Root signs the root certificate and the root role, e.g., a key in a hardware token
+	Root crypto.Signer
+	// Targets signs the targets role
+	Targets crypto.Signer
+	// Snapshot signs the snapshot role. If it is nil, the snapshot role is signed by the notary server
+	Snapshot crypto.Signer
+}
+
+// Fields of metadata are ordered as canonical json
+type rootRole struct {
+	KeyIDs    []string `json:"keyids"`
+	Threshold int      `json:"threshold"`
+}
+
+type signedRoot struct {
+	Type               string               `json:"_type"`
+	ConsistentSnapshot bool                 `json:"consistent_snapshot"`
+	Expires            time.Time            `json:"expires"`
+	Keys               map[string]*tufKey   `json:"keys"`
+	Roles              map[string]*rootRole `json:"roles"`
+	Version            int                  `json:"version"`
+}
+
+type signedTargets struct {
+	Type        string `json:"_type"`
+	Delegations struct {
+		Keys  map[string]*tufKey `json:"keys"`
+		Roles []struct{}         `json:"roles"`
+	} `json:"delegations"`
+	Expires time.Time           `json:"expires"`
+	Targets map[string]struct{} `json:"targets"`
+	Version int                 `json:"version"`
+}
+
+type fileMeta struct {
+	Hashes map[string][]byte `json:"hashes"`
+	Length int               `json:"length"`
+}
+
+type signedSnapshot struct {
+	Type    string               `json:"_type"`
+	Expires time.Time            `json:"expires"`
+	Meta    map[string]*fileMeta `json:"meta"`
+	Version int                  `json:"version"`
+}
+
+type signature struct {
+	KeyID     string `json:"keyid"`
+	Method    string `json:"method"`
+	Signature []byte `json:"sig"`
+}
+
+type signedEnvelope struct {
+	Signed     json.RawMessage `json:"signed"`
+	Signatures []signature     `json:"signatures"`
+}
+
+// newRepositoryMetadata creates metadata of the first version of the repository (gun), which are
+// root, targets and snapshot (if the snapshot key is given) roles, as notary creates them for 'notary init'.
+// timestampKey and serverSnapshotKey are public keys which the notary server manages
+func newRepositoryMetadata(gun string, keys *RepositoryKeys, timestampKey, serverSnapshotKey *tufKey, now time.Time) (map[string][]byte, error) {
+	now = now.UTC().Truncate(time.Second)
+
+	rootKey, err := newRootCertKey(gun, keys.Root, now)
+	if err != nil {
+		return nil, err
+	}
+	targetsKey, err := newTUFKey(keys.Targets.Public())
+	if err != nil {
+		return nil, err
+	}
+	snapshotKey := serverSnapshotKey
+	if keys.Snapshot != nil {
+		if snapshotKey, err = newTUFKey(keys.Snapshot.Public()); err != nil {
+			return nil, err
+		}
+	}
+	if snapshotKey == nil || timestampKey == nil {
+		return nil, fmt.Errorf("snapshot and timestamp keys are required")
+	}
+
+	root := &signedRoot{
+		Type:    "Root",
+		Expires: now.Add(rootExpiry),
+		Keys:    map[string]*tufKey{},
+		Roles:   map[string]*rootRole{},
+		Version: 1,
+	}
+	for role, key := range map[string]*tufKey{
+		tufRoleRoot:      rootKey,
+		tufRoleTargets:   targetsKey,
+		tufRoleSnapshot:  snapshotKey,
+		tufRoleTimestamp: timestampKey,
+	} {
+		id, err := key.id()
+		if err != nil {
+			return nil, err
+		}
+		root.Keys[id] = key
+		root.Roles[role] = &rootRole{KeyIDs: []string{id}, Threshold: 1}
+	}
+
+	targets := &signedTargets{
+		Type:    "Targets",
+		Expires: now.Add(targetsExpiry),
+		Targets: map[string]struct{}{},
+		Version: 1,
+	}
+	targets.Delegations.Keys = map[string]*tufKey{}
+	targets.Delegations.Roles = []struct{}{}
+
+	metadata := map[string][]byte{}
+	if metadata[tufRoleRoot], err = signMetadata(root, keys.Root, rootKey); err != nil {
+		return nil, fmt.Errorf("cannot sign root role: %v", err)
+	}
+	if metadata[tufRoleTargets], err = signMetadata(targets, keys.Targets, targetsKey); err != nil {
+		return nil, fmt.Errorf("cannot sign targets role: %v", err)
+	}
+
+	if keys.Snapshot != nil {
+		snapshot := &signedSnapshot{
+			Type:    "Snapshot",
+			Expires: now.Add(snapshotExpiry),
+			Meta: map[string]*fileMeta{
+				tufRoleRoot:    newFileMeta(metadata[tufRoleRoot]),
+				tufRoleTargets: newFileMeta(metadata[tufRoleTargets]),
+			},
+			Version: 1,
+		}
+		if metadata[tufRoleSnapshot], err = signMetadata(snapshot, keys.Snapshot, snapshotKey); err != nil {
+			return nil, fmt.Errorf("cannot sign snapshot role: %v", err)
+		}
+	}
+
+	return metadata, nil
+}
+
+// newRootCertKey creates a self-signed certificate of the root key, whose common name is the gun, as notary does
+func newRootCertKey(gun string, root crypto.Signer, now time.Time) (*tufKey, error) {
+	if _, ok := root.Public().(*ecdsa.PublicKey); !ok {
+		return nil, fmt.Errorf("only ECDSA root keys are supported")
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: gun},
+		NotBefore:             now,
+		NotAfter:              now.Add(rootExpiry),
+		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, root.Public(), root)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create root certificate: %v", err)
+	}
+
+	key := &tufKey{Type: tufKeyTypeECDSAx509}
+	key.Value.Public = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	return key, nil
+}
+
+// signMetadata signs canonical json of the metadata, and returns the signed envelope
+func signMetadata(signed interface{}, signer crypto.Signer, key *tufKey) ([]byte, error) {
+	payload, err := canonicalJSON(signed)
+	if err != nil {
+		return nil, err
+	}
+	id, err := key.id()
+	if err != nil {
+		return nil, err
+	}
+
+	pub, ok := signer.Public().(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("only ECDSA keys are supported")
+	}
+	digest := sha256.Sum256(payload)
+	asn1Sig, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+
+	// notary verifies r || s, each of which is padded to the size of the curve
+	var sig struct {
+		R, S *big.Int
+	}
+	if _, err := asn1.Unmarshal(asn1Sig, &sig); err != nil {
+		return nil, fmt.Errorf("signature is malformed: %v", err)
+	}
+	size := (pub.Curve.Params().BitSize + 7) / 8
+	raw := make([]byte, 2*size)
+	r, s := sig.R.Bytes(), sig.S.Bytes()
+	copy(raw[size-len(r):size], r)
+	copy(raw[2*size-len(s):], s)
+
+	return json.Marshal(&signedEnvelope{
+		Signed:     payload,
+		Signatures: []signature{{KeyID: id, Method: tufSigMethodECDSA, Signature: raw}},
+	})
+}
+
+func newFileMeta(b []byte) *fileMeta {
+	sum256 := sha256.Sum256(b)
+	sum512 := sha512.Sum512(b)
+	return &fileMeta{
+		Hashes: map[string][]byte{"sha256": sum256[:], "sha512": sum512[:]},
+		Length: len(b),
+	}
+}
+
+// canonicalJSON marshals v as canonical json, if its struct fields are sorted by their names
+func canonicalJSON(v interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
@@ -0,0 +1,172 @@
+package trust
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+)
+
+const testGun = "registry.example.com/ns/app"
+
+func newTestKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newTestTUFKey(t *testing.T, pub crypto.PublicKey) *tufKey {
+	key, err := newTUFKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// verifyMetadata verifies the signature of the role by the key of the root, and returns the signed payload
+func verifyMetadata(t *testing.T, metadata []byte, keys map[string]*tufKey, keyID string) map[string]interface{} {
+	envelope := &signedEnvelope{}
+	if err := json.Unmarshal(metadata, envelope); err != nil {
+		t.Fatal(err)
+	}
+	if len(envelope.Signatures) != 1 || envelope.Signatures[0].KeyID != keyID || envelope.Signatures[0].Method != tufSigMethodECDSA {
+		t.Fatalf("unexpected signatures %+v", envelope.Signatures)
+	}
+
+	key := keys[keyID]
+	var pub *ecdsa.PublicKey
+	if key.Type == tufKeyTypeECDSAx509 {
+		block, _ := pem.Decode(key.Value.Public)
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pub = cert.PublicKey.(*ecdsa.PublicKey)
+	} else {
+		parsed, err := x509.ParsePKIXPublicKey(key.Value.Public)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pub = parsed.(*ecdsa.PublicKey)
+	}
+
+	sig := envelope.Signatures[0].Signature
+	if len(sig) != 64 {
+		t.Fatalf("expected r || s of 64 bytes, got %d bytes", len(sig))
+	}
+	digest := sha256.Sum256(envelope.Signed)
+	if !ecdsa.Verify(pub, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+		t.Errorf("signature of key %s is not valid", keyID)
+	}
+
+	// Payload is canonical json, whose keys are sorted and has no spaces
+	payload := map[string]interface{}{}
+	if err := json.Unmarshal(envelope.Signed, &payload); err != nil {
+		t.Fatal(err)
+	}
+	canonical, err := canonicalJSON(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(canonical, envelope.Signed) {
+		t.Errorf("payload is not canonical json: %s", envelope.Signed)
+	}
+
+	return payload
+}
+
+func TestNewRepositoryMetadata(t *testing.T) {
+	root, targets, snapshot, timestamp := newTestKey(t), newTestKey(t), newTestKey(t), newTestKey(t)
+	timestampKey := newTestTUFKey(t, timestamp.Public())
+	timestampID, _ := timestampKey.id()
+	targetsID, _ := KeyID(targets.Public())
+	snapshotID, _ := KeyID(snapshot.Public())
+
+	for _, signerSnapshot := range []bool{false, true} {
+		keys := &RepositoryKeys{Root: root, Targets: targets}
+		serverSnapshotKey := newTestTUFKey(t, snapshot.Public())
+		if signerSnapshot {
+			keys.Snapshot, serverSnapshotKey = snapshot, nil
+		}
+
+		metadata, err := newRepositoryMetadata(testGun, keys, timestampKey, serverSnapshotKey, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Root is signed by its certificate, whose common name is the gun
+		envelope := &signedEnvelope{}
+		if err := json.Unmarshal(metadata[tufRoleRoot], envelope); err != nil {
+			t.Fatal(err)
+		}
+		signedRoot := &signedRoot{}
+		if err := json.Unmarshal(envelope.Signed, signedRoot); err != nil {
+			t.Fatal(err)
+		}
+		rootID := signedRoot.Roles[tufRoleRoot].KeyIDs[0]
+		rootCertKey := signedRoot.Keys[rootID]
+		block, _ := pem.Decode(rootCertKey.Value.Public)
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cert.Subject.CommonName != testGun || cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) != nil {
+			t.Errorf("root certificate should be self-signed for %s, got %s", testGun, cert.Subject.CommonName)
+		}
+		if id, _ := rootCertKey.id(); id != rootID {
+			t.Errorf("expected root key ID %s, got %s", id, rootID)
+		}
+
+		payload := verifyMetadata(t, metadata[tufRoleRoot], signedRoot.Keys, rootID)
+		if payload["_type"] != "Root" {
+			t.Errorf("unexpected root %v", payload)
+		}
+		for role, id := range map[string]string{tufRoleTargets: targetsID, tufRoleSnapshot: snapshotID, tufRoleTimestamp: timestampID} {
+			if ids := signedRoot.Roles[role].KeyIDs; len(ids) != 1 || ids[0] != id {
+				t.Errorf("expected key %s of role %s, got %v", id, role, ids)
+			}
+		}
+
+		payload = verifyMetadata(t, metadata[tufRoleTargets], signedRoot.Keys, targetsID)
+		if payload["_type"] != "Targets" {
+			t.Errorf("unexpected targets %v", payload)
+		}
+
+		if _, ok := metadata[tufRoleSnapshot]; ok != signerSnapshot {
+			t.Errorf("expected snapshot %v, got %v", signerSnapshot, ok)
+		}
+		if signerSnapshot {
+			verifyMetadata(t, metadata[tufRoleSnapshot], signedRoot.Keys, snapshotID)
+
+			envelope := &signedEnvelope{}
+			_ = json.Unmarshal(metadata[tufRoleSnapshot], envelope)
+			snapshot := &signedSnapshot{}
+			if err := json.Unmarshal(envelope.Signed, snapshot); err != nil {
+				t.Fatal(err)
+			}
+			for _, role := range []string{tufRoleRoot, tufRoleTargets} {
+				sum := sha256.Sum256(metadata[role])
+				if meta := snapshot.Meta[role]; meta.Length != len(metadata[role]) || !bytes.Equal(meta.Hashes["sha256"], sum[:]) {
+					t.Errorf("snapshot has wrong meta of %s", role)
+				}
+			}
+		}
+	}
+}
+
+func TestNewRepositoryMetadataError(t *testing.T) {
+	keys := &RepositoryKeys{Root: newTestKey(t), Targets: newTestKey(t)}
+	if _, err := newRepositoryMetadata(testGun, keys, nil, nil, time.Now()); err == nil {
+		t.Errorf("expected error without server keys")
+	}
+}